@@ -0,0 +1,223 @@
+package nagle
+
+import (
+	"bytes"
+	"io"
+)
+
+// WithAckGating switches to the original Nagle rule: a write made while nothing is in
+// flight is sent at once, keeping first-byte latency low on idle connections, while
+// writes made before the previous flush is acknowledged with Ack are held back and
+// sent together. Full buffers are still flushed, and the flush timeout still applies
+// as a safety net for acknowledgements that never come.
+func WithAckGating() Option {
+	return func(o *options) {
+		o.ackGating = true
+	}
+}
+
+// WithAutoAck is WithAckGating with a flush acknowledged as soon as the underlying
+// Write returns. The write that finds the pipe idle is made without the wrapper lock
+// held, so the writes made meanwhile are buffered and sent together right after it,
+// all by the goroutine that started the flush.
+func WithAutoAck() Option {
+	return func(o *options) {
+		o.ackGating = true
+		o.autoAck = true
+	}
+}
+
+// Ack acknowledges the data in flight under WithAckGating and sends whatever was
+// buffered while waiting for it, which then becomes the data in flight.
+func (nw *NagleWriter) Ack() error {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	if nw.closed {
+		return ErrClosed
+	}
+
+	nw.waitLeaderLocked()
+	nw.inFlight = false
+	if nw.corked {
+		return nil
+	}
+	_, err := nw.flushLocked(FlushTriggerAck)
+	return err
+}
+
+// markFlushedLocked records a flush of n bytes: it starts the minimum flush interval,
+// postpones the next keepalive and marks the data as in flight under WithAckGating.
+func (nw *NagleWriter) markFlushedLocked(n int) {
+	if n == 0 {
+		return
+	}
+	nw.markSentLocked()
+	if nw.minInterval > 0 {
+		nw.lastFlush = nw.clock.Now()
+	}
+	if nw.ackGating && !nw.autoAck {
+		nw.inFlight = true
+	}
+}
+
+// gatedFlushLocked sends the buffer if nothing is in flight, reporting whether it did.
+func (nw *NagleWriter) gatedFlushLocked() (bool, error) {
+	if !nw.ackGating || nw.inFlight || nw.corked || nw.paused {
+		return false, nil
+	}
+	if nw.autoAck && nw.async == nil {
+		return true, nw.leadFlushLocked(FlushTriggerIdle)
+	}
+	_, err := nw.flushLocked(FlushTriggerIdle)
+	return true, err
+}
+
+// leadFlushLocked writes the buffer with the lock released, then keeps writing what
+// was buffered meanwhile: until the buffer is empty under WithAutoAck, or while a
+// trigger is due under WithDoubleBuffer. Other flushes are skipped while it runs, and
+// the calls that need the buffer sent wait for it with waitLeaderLocked.
+func (nw *NagleWriter) leadFlushLocked(trigger FlushTrigger) error {
+	done := make(chan struct{})
+	nw.leaderDone = done
+	if nw.autoAck {
+		nw.inFlight = true
+	}
+	defer func() {
+		if nw.autoAck {
+			nw.inFlight = false
+		}
+		nw.leaderDone = nil
+		close(done)
+	}()
+
+	for {
+		out := nw.encoded
+		raw := len(out) == 0
+		if raw {
+			if nw.strict {
+				nw.checkFlushLocked()
+			}
+			out = nw.swapBufferLocked()
+			if nw.transform != nil {
+				var err error
+				if out, err = nw.transform(out); err != nil {
+					// Nothing was buffered meanwhile, so the batch goes back as it was.
+					nw.buffer.Write(nw.flushing.Bytes())
+					nw.flushing.Reset()
+					return flushFailed(err)
+				}
+			}
+		}
+		nw.encoded = nil
+		nw.pendingWrites = 0
+
+		start := nw.flushStartLocked()
+		queued := nw.queuedLocked()
+		nw.mutex.Unlock()
+		n, err := nw.w.Write(out)
+		nw.mutex.Lock()
+		if err == nil && n < len(out) {
+			err = io.ErrShortWrite
+		}
+		if n == len(out) {
+			nw.partial = false
+		}
+
+		nw.checkFatal(err)
+		nw.markFlushedLocked(n)
+		nw.stats.record(trigger, int64(n), queued)
+		nw.queueFlushEventLocked(trigger, out, n, start, err)
+		if n < len(out) {
+			// Put back what was not written ahead of the data buffered meanwhile.
+			nw.requeueLocked(out[n:], raw && nw.transform == nil)
+		}
+		nw.flushing.Reset()
+		if err != nil {
+			return flushFailed(err)
+		}
+
+		nw.expireLocked()
+		var due bool
+		if trigger, due = nw.nextLeadTriggerLocked(); !due {
+			break
+		}
+	}
+
+	if nw.pendingLocked() == 0 {
+		nw.disarmTimerLocked()
+	} else {
+		nw.armTimerLocked(nw.flushAt.Sub(nw.clock.Now()))
+	}
+	return nil
+}
+
+// nextLeadTriggerLocked reports whether the data buffered during a write made by
+// leadFlushLocked is to be written right away, and the trigger to report it under.
+func (nw *NagleWriter) nextLeadTriggerLocked() (FlushTrigger, bool) {
+	switch {
+	case nw.pendingLocked() == 0:
+		return 0, false
+	case nw.autoAck:
+		return FlushTriggerAck, true
+	case nw.corked:
+		return 0, false
+	case nw.sizeFlushDueLocked(0):
+		return FlushTriggerSize, !nw.postponeFlushLocked(FlushTriggerSize)
+	case nw.maxWrites > 0 && nw.pendingWrites >= nw.maxWrites:
+		return FlushTriggerCount, !nw.postponeFlushLocked(FlushTriggerCount)
+	case !nw.clock.Now().Before(nw.flushAt):
+		return FlushTriggerTimeout, !nw.postponeFlushLocked(FlushTriggerTimeout)
+	}
+	return 0, false
+}
+
+// swapBufferLocked swaps the buffer with the spare one and returns the bytes it held,
+// which stay valid until the spare buffer is reset. A segment buffer is copied instead.
+func (nw *NagleWriter) swapBufferLocked() []byte {
+	if nw.flushing == nil {
+		nw.flushing = newBatchBuffer(nw.bufferSize, nw.prealloc)
+	}
+	buf, ok := nw.buffer.(*bytes.Buffer)
+	if !ok {
+		nw.flushing.Write(nw.buffer.Bytes())
+		nw.buffer.Reset()
+		return nw.flushing.Bytes()
+	}
+	nw.buffer, nw.flushing = nw.flushing, buf
+	return buf.Bytes()
+}
+
+// requeueLocked puts unwritten output back in front of the pending data. Raw bytes go
+// back into the buffer; transformed output is kept apart so it is not transformed twice.
+func (nw *NagleWriter) requeueLocked(rest []byte, raw bool) {
+	if nw.pendingWrites == 0 {
+		// The unwritten bytes are the tail of a write still to be sent.
+		nw.pendingWrites = 1
+	}
+	if !raw {
+		nw.encoded = append([]byte(nil), rest...)
+		nw.partial = true
+		return
+	}
+	pending := append(append([]byte(nil), rest...), nw.buffer.Bytes()...)
+	nw.buffer.Reset()
+	nw.buffer.Write(pending)
+	nw.partial = true
+}
+
+// waitLeaderLocked waits, with the lock released, until no write started by
+// leadFlushLocked is running, and then for the queue of WithAsyncFlush to drain,
+// so the caller may write to the underlying writer.
+func (nw *NagleWriter) waitLeaderLocked() {
+	for nw.leaderDone != nil {
+		done := nw.leaderDone
+		nw.mutex.Unlock()
+		<-done
+		nw.mutex.Lock()
+	}
+	if nw.async != nil {
+		// The writer goroutine does not take the lock, so it can drain with the lock held.
+		nw.async.pending.Wait()
+	}
+}
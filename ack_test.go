@@ -0,0 +1,99 @@
+package nagle
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// GatedReadWriteCloser records writes like RecordingReadWriteCloser, blocking each of
+// them until release is closed.
+type GatedReadWriteCloser struct {
+	mutex   sync.Mutex
+	writes  []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *GatedReadWriteCloser) Read(p []byte) (int, error) {
+	return 0, nil
+}
+
+func (m *GatedReadWriteCloser) Write(p []byte) (int, error) {
+	select {
+	case m.started <- struct{}{}:
+	default:
+	}
+	<-m.release
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.writes = append(m.writes, string(p))
+	return len(p), nil
+}
+
+func (m *GatedReadWriteCloser) Close() error {
+	return nil
+}
+
+func (m *GatedReadWriteCloser) Writes() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.writes...)
+}
+
+func TestNagleWrapper_WithAckGating(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithAckGating())
+	defer nagleWrapper.Close()
+
+	// Nothing is in flight, so the first write goes out at once
+	nagleWrapper.Write([]byte("a"))
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "a" {
+		t.Fatalf("expected an immediate write of 'a', but got: %q", mockRWC.writes)
+	}
+
+	// Until it is acknowledged the next writes are held back
+	nagleWrapper.Write([]byte("b"))
+	nagleWrapper.Write([]byte("c"))
+	if len(mockRWC.writes) != 1 {
+		t.Fatalf("expected writes to be held back, but got: %q", mockRWC.writes)
+	}
+	nagleWrapper.Ack()
+	if len(mockRWC.writes) != 2 || mockRWC.writes[1] != "bc" {
+		t.Fatalf("expected a write of 'bc' after the ack, but got: %q", mockRWC.writes)
+	}
+
+	// Acknowledging with nothing buffered leaves the pipe idle
+	nagleWrapper.Ack()
+	nagleWrapper.Write([]byte("d"))
+	if len(mockRWC.writes) != 3 || mockRWC.writes[2] != "d" {
+		t.Fatalf("expected an immediate write of 'd', but got: %q", mockRWC.writes)
+	}
+
+	if stats := nagleWrapper.Stats(); stats.IdleFlushes != 2 || stats.AckFlushes != 1 {
+		t.Fatalf("expected 2 idle and 1 ack flushes, but got: %+v", stats)
+	}
+}
+
+func TestNagleWrapper_WithAutoAck(t *testing.T) {
+	mockRWC := &GatedReadWriteCloser{started: make(chan struct{}, 1), release: make(chan struct{})}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithAutoAck())
+	defer nagleWrapper.Close()
+
+	done := make(chan struct{})
+	go func() {
+		nagleWrapper.Write([]byte("a"))
+		close(done)
+	}()
+	<-mockRWC.started
+
+	// Writes made while the first one is in flight return without waiting for it
+	nagleWrapper.Write([]byte("b"))
+	nagleWrapper.Write([]byte("c"))
+	close(mockRWC.release)
+	<-done
+
+	if writes := mockRWC.Writes(); len(writes) != 2 || writes[0] != "a" || writes[1] != "bc" {
+		t.Fatalf("expected writes 'a' and 'bc', but got: %q", writes)
+	}
+}
@@ -0,0 +1,62 @@
+package nagle
+
+import "time"
+
+// adaptiveGapWeight is the inverse weight given to each new sample of the inter-write interval.
+const adaptiveGapWeight = 8
+
+// adaptiveTimeout derives a flush timeout from a moving average of the interval between writes.
+type adaptiveTimeout struct {
+	min       time.Duration
+	max       time.Duration
+	lastWrite time.Time
+	avgGap    time.Duration
+	samples   int
+}
+
+func (a *adaptiveTimeout) enabled() bool {
+	return a.max > 0
+}
+
+func (a *adaptiveTimeout) observe(now time.Time) {
+	if !a.lastWrite.IsZero() {
+		gap := now.Sub(a.lastWrite)
+		if a.samples == 0 {
+			a.avgGap = gap
+		} else {
+			a.avgGap += (gap - a.avgGap) / adaptiveGapWeight
+		}
+		a.samples++
+	}
+	a.lastWrite = now
+}
+
+// timeout waits for roughly two average intervals, so a write that is part of
+// a burst is likely to be joined by the next one before the flush fires.
+func (a *adaptiveTimeout) timeout(fallback time.Duration) time.Duration {
+	if a.samples == 0 {
+		return fallback
+	}
+	d := 2 * a.avgGap
+	if d < a.min {
+		d = a.min
+	}
+	if d > a.max {
+		d = a.max
+	}
+	return d
+}
+
+func (nw *NagleWriter) observeWriteLocked() {
+	if nw.adaptive.enabled() {
+		nw.adaptive.observe(nw.clock.Now())
+	}
+}
+
+// currentFlushTimeoutLocked returns the flush timeout to arm the timer with.
+func (nw *NagleWriter) currentFlushTimeoutLocked() time.Duration {
+	if nw.adaptive.enabled() {
+		return nw.adaptive.timeout(nw.flushTimeout)
+	}
+	return nw.flushTimeout
+}
@@ -0,0 +1,48 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeout(t *testing.T) {
+	a := adaptiveTimeout{min: time.Millisecond, max: 100 * time.Millisecond}
+	if d := a.timeout(50 * time.Millisecond); d != 50*time.Millisecond {
+		t.Fatalf("expected fallback timeout before samples, got %v", d)
+	}
+
+	// Bursty writes shrink the timeout down to the minimum
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		a.observe(now)
+		now = now.Add(100 * time.Microsecond)
+	}
+	if d := a.timeout(50 * time.Millisecond); d != time.Millisecond {
+		t.Fatalf("expected minimum timeout under bursty traffic, got %v", d)
+	}
+
+	// Sparse writes grow it up to the maximum
+	for i := 0; i < 50; i++ {
+		now = now.Add(time.Second)
+		a.observe(now)
+	}
+	if d := a.timeout(50 * time.Millisecond); d != 100*time.Millisecond {
+		t.Fatalf("expected maximum timeout under sparse traffic, got %v", d)
+	}
+}
+
+func TestNagleWrapper_AdaptiveTimeout(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithAdaptiveTimeout(10*time.Millisecond, 20*time.Millisecond))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01"))
+	nagleWrapper.Write([]byte("23"))
+
+	// After the first interval is observed the timeout is bounded by max, not flushTimeout
+	time.Sleep(100 * time.Millisecond)
+	if stats := nagleWrapper.Stats(); stats.BytesFlushed != 4 {
+		t.Fatalf("expected 4 bytes flushed, got %d", stats.BytesFlushed)
+	}
+}
@@ -0,0 +1,159 @@
+package nagle
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// WithAsyncFlush hands flushed batches to a dedicated writer goroutine through a queue
+// holding up to depth batches, so Write returns as soon as its data is buffered and is
+// only held back once the queue is full. Flush and Close wait for the queue to drain and
+// return any error the goroutine hit; other errors are reported like those of timeout
+// flushes. Bytes the underlying writer fails to accept are dropped instead of retried,
+// since later batches may already be queued behind them. The goroutine is started by
+// the first flush and stops on Close. A depth below 1 is treated as 1.
+func WithAsyncFlush(depth int) Option {
+	return func(o *options) {
+		o.asyncDepth = max(depth, 1)
+	}
+}
+
+// asyncBatch is a flushed batch waiting in the queue of WithAsyncFlush.
+type asyncBatch struct {
+	buf     *bytes.Buffer
+	trigger FlushTrigger
+	start   flushStart
+	queued  time.Duration
+}
+
+// asyncFlusher feeds the writer goroutine of WithAsyncFlush. The goroutine keeps its
+// results under its own mutex, so it never needs the wrapper lock and a Write blocked
+// on a full queue while holding that lock cannot stall it.
+type asyncFlusher struct {
+	queue   chan asyncBatch
+	pending sync.WaitGroup
+	started bool
+
+	mutex sync.Mutex
+	stats Stats
+	err   error
+}
+
+func newAsyncFlusher(depth int) *asyncFlusher {
+	return &asyncFlusher{queue: make(chan asyncBatch, depth)}
+}
+
+// takeErr returns and clears the first error hit by the writer goroutine.
+func (a *asyncFlusher) takeErr() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	err := a.err
+	a.err = nil
+	return err
+}
+
+// addStats adds the counters of the writer goroutine and the queue depth to stats.
+func (a *asyncFlusher) addStats(stats *Stats) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	stats.add(a.stats)
+	stats.QueueDepth = len(a.queue)
+}
+
+// enqueueFlushLocked is flushLocked under WithAsyncFlush. The buffer is handed to the
+// writer goroutine and replaced by a fresh one; Flush and Close also wait for it to be written.
+func (nw *NagleWriter) enqueueFlushLocked(trigger FlushTrigger) (int, error) {
+	fresh := newBatchBuffer(nw.bufferSize, nw.prealloc)
+	batch, ok := nw.buffer.(*bytes.Buffer)
+	if !ok || nw.transform != nil {
+		out := nw.buffer.Bytes()
+		if nw.transform != nil {
+			var err error
+			if out, err = nw.transform(out); err != nil {
+				releaseFlushBuffer(fresh)
+				return 0, flushFailed(err)
+			}
+		}
+		fresh.Write(out)
+		nw.buffer.Reset()
+		batch = fresh
+	} else {
+		nw.buffer = fresh
+	}
+
+	n := batch.Len()
+	nw.pendingWrites = 0
+	nw.disarmTimerLocked()
+	nw.markFlushedLocked(n)
+
+	a := nw.async
+	if !a.started {
+		a.started = true
+		go nw.runAsyncFlush()
+	}
+	a.pending.Add(1)
+	a.queue <- asyncBatch{buf: batch, trigger: trigger, start: nw.flushStartLocked(), queued: nw.queuedLocked()}
+
+	if trigger != FlushTriggerExplicit && trigger != FlushTriggerClose {
+		return n, nil
+	}
+	a.pending.Wait()
+	if err := a.takeErr(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// runAsyncFlush is the writer goroutine of WithAsyncFlush.
+func (nw *NagleWriter) runAsyncFlush() {
+	a := nw.async
+	for batch := range a.queue {
+		out := batch.buf.Bytes()
+		event := flushEvent{info: FlushInfo{Trigger: batch.trigger}}
+		start := batch.start
+		if !start.at.IsZero() {
+			// The write begins now rather than when the batch was queued.
+			start.at = nw.clock.Now()
+		}
+		n, err := nw.w.Write(out)
+		if err == nil && n < len(out) {
+			err = io.ErrShortWrite
+		}
+		nw.checkFatal(err)
+		start.info(&event.info, nw.clock.Now())
+		if nw.flushHook != nil && n > 0 {
+			event.batch = append([]byte(nil), out[:n]...)
+		}
+		releaseFlushBuffer(batch.buf)
+
+		if err != nil {
+			err = flushFailed(err)
+		}
+		a.mutex.Lock()
+		a.stats.record(batch.trigger, int64(n), batch.queued)
+		if err != nil && a.err == nil {
+			a.err = err
+		}
+		a.mutex.Unlock()
+
+		event.info.Bytes, event.info.Err = n, err
+		if n > 0 || err != nil {
+			nw.reportFlush(event)
+		}
+		if err != nil && nw.onError != nil && batch.trigger != FlushTriggerExplicit && batch.trigger != FlushTriggerClose {
+			nw.onError(err)
+		}
+		a.pending.Done()
+	}
+}
+
+// stopAsyncFlushLocked stops the writer goroutine once the queue has drained.
+func (nw *NagleWriter) stopAsyncFlushLocked() {
+	if nw.async != nil && nw.async.started {
+		close(nw.async.queue)
+	}
+}
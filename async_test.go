@@ -0,0 +1,70 @@
+package nagle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithAsyncFlush(t *testing.T) {
+	mockRWC := &GatedReadWriteCloser{started: make(chan struct{}, 1), release: make(chan struct{})}
+	nagleWrapper := New(mockRWC, WithBufferSize(2), WithFlushTimeout(time.Hour), WithAsyncFlush(2))
+
+	// The first batch is picked up by the writer goroutine and gets stuck there
+	nagleWrapper.Write([]byte("ab"))
+	<-mockRWC.started
+
+	// Two more batches fit in the queue, so these writes return at once
+	nagleWrapper.Write([]byte("cd"))
+	nagleWrapper.Write([]byte("ef"))
+	if stats := nagleWrapper.Stats(); stats.QueueDepth != 2 {
+		t.Fatalf("expected a queue depth of 2, but got: %+v", stats)
+	}
+
+	// A further batch has to wait for room in the queue
+	done := make(chan struct{})
+	go func() {
+		nagleWrapper.Write([]byte("gh"))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("expected the write to block on the full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(mockRWC.release)
+	<-done
+	nagleWrapper.Write([]byte("i"))
+	if err := nagleWrapper.Close(); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	// Close drained the queue in order before flushing the rest
+	if writes := mockRWC.Writes(); len(writes) != 5 || writes[0] != "ab" || writes[3] != "gh" || writes[4] != "i" {
+		t.Fatalf("expected the batches in order, but got: %q", writes)
+	}
+	if stats := nagleWrapper.Stats(); stats.SizeFlushes != 4 || stats.CloseFlushes != 1 || stats.BytesFlushed != 9 || stats.QueueDepth != 0 {
+		t.Fatalf("expected 4 size flushes and 1 close flush, but got: %+v", stats)
+	}
+}
+
+func TestNagleWrapper_WithAsyncFlushError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	reported := make(chan error, 1)
+	nagleWrapper := New(&FailingReadWriteCloser{err: writeErr}, WithBufferSize(2), WithFlushTimeout(time.Hour),
+		WithAsyncFlush(1), WithOnError(func(err error) { reported <- err }))
+	defer nagleWrapper.Close()
+
+	if _, err := nagleWrapper.Write([]byte("ab")); err != nil {
+		t.Fatalf("expected the write to be queued, but got: %v", err)
+	}
+	if err := <-reported; !errors.Is(err, writeErr) || !errors.Is(err, ErrFlushFailed) {
+		t.Fatalf("expected %v wrapped in ErrFlushFailed, but got: %v", writeErr, err)
+	}
+
+	// The error also surfaces on the next call
+	if err := nagleWrapper.Flush(); !errors.Is(err, writeErr) {
+		t.Fatalf("expected %v, but got: %v", writeErr, err)
+	}
+}
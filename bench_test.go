@@ -0,0 +1,122 @@
+package nagle
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// discardReadWriteCloser is a ReadWriteCloser that throws writes away, so benchmarks
+// measure the wrapper rather than the destination.
+type discardReadWriteCloser struct{}
+
+func (discardReadWriteCloser) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (discardReadWriteCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (discardReadWriteCloser) Close() error                { return nil }
+
+func benchmarkWrite(b *testing.B, size int, opts ...Option) {
+	nagleWrapper := New(discardReadWriteCloser{}, append([]Option{WithFlushTimeout(time.Hour)}, opts...)...)
+	defer nagleWrapper.Close()
+	data := make([]byte, size)
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nagleWrapper.Write(data)
+	}
+}
+
+func BenchmarkWrite_16B(b *testing.B) {
+	benchmarkWrite(b, 16)
+}
+
+func BenchmarkWrite_128B(b *testing.B) {
+	benchmarkWrite(b, 128)
+}
+
+func BenchmarkWrite_1KiB(b *testing.B) {
+	benchmarkWrite(b, 1024)
+}
+
+func BenchmarkWrite_16B_Parallel(b *testing.B) {
+	nagleWrapper := New(discardReadWriteCloser{}, WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	b.SetBytes(16)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		data := make([]byte, 16)
+		for pb.Next() {
+			nagleWrapper.Write(data)
+		}
+	})
+}
+
+// BenchmarkWrite_16B_Producers is BenchmarkWrite_16B_Parallel with a Producer per
+// goroutine, which only takes the wrapper lock once per staged batch.
+func BenchmarkWrite_16B_Producers(b *testing.B) {
+	nagleWrapper := New(discardReadWriteCloser{}, WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	b.SetBytes(16)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		p := nagleWrapper.NewProducer()
+		defer p.Close()
+		data := make([]byte, 16)
+		for pb.Next() {
+			p.Write(data)
+		}
+	})
+}
+
+func BenchmarkWrite_16B_Observed(b *testing.B) {
+	benchmarkWrite(b, 16, WithFlushObserver(func(FlushInfo) {}))
+}
+
+func TestNagleWrapper_WriteZeroAllocs(t *testing.T) {
+	nagleWrapper := New(discardReadWriteCloser{}, WithBufferSize(1024), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+	data := make([]byte, 16)
+
+	// The first write creates the flush timer
+	nagleWrapper.Write(data)
+
+	if allocs := testing.AllocsPerRun(1000, func() { nagleWrapper.Write(data) }); allocs != 0 {
+		t.Fatalf("expected Write to not allocate, but got: %v allocs per write", allocs)
+	}
+	if allocs := testing.AllocsPerRun(1000, func() { nagleWrapper.WriteString("0123456789abcdef") }); allocs != 0 {
+		t.Fatalf("expected WriteString to not allocate, but got: %v allocs per write", allocs)
+	}
+	if allocs := testing.AllocsPerRun(1000, func() { nagleWrapper.WriteByte('x') }); allocs != 0 {
+		t.Fatalf("expected WriteByte to not allocate, but got: %v allocs per write", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { nagleWrapper.WriteAndFlush(data) }); allocs != 0 {
+		t.Fatalf("expected WriteAndFlush to not allocate, but got: %v allocs per write", allocs)
+	}
+}
+
+// benchmarkCopy copies 1MiB from a reader that hides io.WriterTo into dst.
+func benchmarkCopy(b *testing.B, dst func(*NagleWriter) io.Writer) {
+	nagleWriter := NewWriter(io.Discard, WithBufferSize(64<<10), WithFlushTimeout(time.Hour))
+	defer nagleWriter.Close()
+	data := make([]byte, 1<<20)
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.Copy(dst(nagleWriter), struct{ io.Reader }{bytes.NewReader(data)})
+	}
+}
+
+func BenchmarkCopy_ReadFrom(b *testing.B) {
+	benchmarkCopy(b, func(nw *NagleWriter) io.Writer { return nw })
+}
+
+func BenchmarkCopy_Write(b *testing.B) {
+	// Hiding ReadFrom makes io.Copy allocate a buffer and call Write
+	benchmarkCopy(b, func(nw *NagleWriter) io.Writer { return struct{ io.Writer }{nw} })
+}
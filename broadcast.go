@@ -0,0 +1,164 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrBroadcastOverrun is reported for a Broadcaster target whose queue was full when a
+// batch was flushed, which removes it so the others are not held back.
+var ErrBroadcastOverrun = errors.New("nagle: broadcast target fell behind")
+
+// Broadcaster is a NagleWriter whose flushed batches are sent to many underlying
+// writers. Each Write is buffered once, and every batch is queued to each target and
+// written by a goroutine of its own, so each target goes at its own pace. A target whose
+// write fails, or whose queue is full, is removed and reported without affecting the rest.
+type Broadcaster struct {
+	*NagleWriter
+	fanout *fanout
+}
+
+// fanout is the underlying writer of a Broadcaster.
+type fanout struct {
+	mutex   sync.Mutex
+	depth   int
+	targets map[io.Writer]*broadcastTarget
+	onError func(io.Writer, error)
+	wg      sync.WaitGroup
+}
+
+// broadcastTarget is a writer of a Broadcaster and its queue of batches.
+type broadcastTarget struct {
+	w     io.Writer
+	queue chan []byte
+}
+
+// NewBroadcaster creates a Broadcaster configured by opts whose targets queue up to
+// depth batches each. onError, when not nil, is called with each target that is removed
+// because its write failed or it fell behind, and the error. It must not block.
+func NewBroadcaster(depth int, onError func(w io.Writer, err error), opts ...Option) *Broadcaster {
+	f := &fanout{depth: max(depth, 1), targets: make(map[io.Writer]*broadcastTarget), onError: onError}
+	return &Broadcaster{NagleWriter: newWriter(f, nil, buildOptions(opts)), fanout: f}
+}
+
+// Add adds w to the targets. It receives the batches flushed from now on.
+func (b *Broadcaster) Add(w io.Writer) {
+	f := b.fanout
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.targets[w]; ok {
+		return
+	}
+	t := &broadcastTarget{w: w, queue: make(chan []byte, f.depth)}
+	f.targets[w] = t
+	f.wg.Add(1)
+	go f.run(t)
+}
+
+// Remove removes w from the targets once the batches already queued for it are written.
+func (b *Broadcaster) Remove(w io.Writer) {
+	b.fanout.remove(w)
+}
+
+// Targets returns the number of targets.
+func (b *Broadcaster) Targets() int {
+	f := b.fanout
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return len(f.targets)
+}
+
+// Close flushes the buffered data and waits until every target has written its queue.
+// The targets are not closed.
+func (b *Broadcaster) Close() error {
+	err := b.NagleWriter.Close()
+	f := b.fanout
+	f.mutex.Lock()
+	for w, t := range f.targets {
+		delete(f.targets, w)
+		close(t.queue)
+	}
+	f.mutex.Unlock()
+	f.wg.Wait()
+	return err
+}
+
+// Write queues a copy of p, shared by all targets, to each of them. It never fails:
+// targets that cannot take it are removed instead.
+func (f *fanout) Write(p []byte) (int, error) {
+	batch := append([]byte(nil), p...)
+
+	var overrun []*broadcastTarget
+	f.mutex.Lock()
+	for _, t := range f.targets {
+		select {
+		case t.queue <- batch:
+		default:
+			overrun = append(overrun, t)
+		}
+	}
+	f.mutex.Unlock()
+
+	for _, t := range overrun {
+		if f.removeTarget(t) {
+			f.report(t, ErrBroadcastOverrun)
+		}
+	}
+	return len(p), nil
+}
+
+// remove removes the target of w.
+func (f *fanout) remove(w io.Writer) {
+	f.mutex.Lock()
+	t := f.targets[w]
+	f.mutex.Unlock()
+
+	if t != nil {
+		f.removeTarget(t)
+	}
+}
+
+// removeTarget removes t and reports whether it was still a target.
+func (f *fanout) removeTarget(t *broadcastTarget) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.targets[t.w] != t {
+		return false
+	}
+	delete(f.targets, t.w)
+	close(t.queue)
+	return true
+}
+
+// report passes the failure of t to the error callback, if any.
+func (f *fanout) report(t *broadcastTarget, err error) {
+	if f.onError != nil {
+		f.onError(t.w, err)
+	}
+}
+
+// run writes the batches queued for t until its queue is closed. After a failed write
+// the rest of the queue is discarded.
+func (f *fanout) run(t *broadcastTarget) {
+	defer f.wg.Done()
+
+	failed := false
+	for batch := range t.queue {
+		if failed {
+			continue
+		}
+		n, err := t.w.Write(batch)
+		if err == nil && n < len(batch) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			failed = true
+			f.removeTarget(t)
+			f.report(t, err)
+		}
+	}
+}
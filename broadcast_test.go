@@ -0,0 +1,80 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBroadcaster(t *testing.T) {
+	writeErr := errors.New("write failed")
+	var mutex sync.Mutex
+	var failed []error
+	onError := func(w io.Writer, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		failed = append(failed, err)
+	}
+
+	b := NewBroadcaster(4, onError, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	first := &MockReadWriteCloser{}
+	second := &MockReadWriteCloser{}
+	b.Add(first)
+	b.Add(second)
+	b.Add(&FailingReadWriteCloser{err: writeErr})
+
+	b.Write([]byte("ab"))
+	b.Write([]byte("cd"))
+	b.Flush()
+	b.Write([]byte("ef"))
+	if err := b.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The dead target does not affect the healthy ones
+	if first.String() != "abcdef" || second.String() != "abcdef" {
+		t.Fatalf("expected 'abcdef' on both targets, but got: '%s' and '%s'", first.String(), second.String())
+	}
+	if len(failed) != 1 || !errors.Is(failed[0], writeErr) {
+		t.Fatalf("expected the failing target to be reported, but got: %v", failed)
+	}
+}
+
+func TestBroadcaster_Overrun(t *testing.T) {
+	var failed []io.Writer
+	b := NewBroadcaster(4, func(w io.Writer, err error) {
+		if errors.Is(err, ErrBroadcastOverrun) {
+			failed = append(failed, w)
+		}
+	}, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer b.Close()
+
+	blocking := NewBlockingReadWriteCloser()
+	defer close(blocking.release)
+	b.Add(blocking)
+	healthy := &MockReadWriteCloser{}
+	b.Add(healthy)
+
+	b.Write([]byte("a"))
+	b.Flush()
+	<-blocking.started
+	// Four batches wait in the queue of the stuck target, and the next overruns it
+	for _, batch := range []string{"b", "c", "d", "e", "f"} {
+		b.Write([]byte(batch))
+		b.Flush()
+		// Let the healthy target keep up, as a live peer would
+		for !strings.HasSuffix(healthy.String(), batch) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if len(failed) != 1 || failed[0] != blocking {
+		t.Fatalf("expected the stuck target to be removed, but got: %v", failed)
+	}
+	if b.Targets() != 1 {
+		t.Fatalf("expected 1 target left, but got: %d", b.Targets())
+	}
+}
@@ -0,0 +1,25 @@
+package nagle
+
+import "time"
+
+// WithLatencyBudget bounds how long any byte may wait in the buffer: the flush timer
+// fires at the latest d after the oldest buffered byte arrived, however many writes
+// follow it. The flush timeout, which starts over on every Write, still applies when
+// it is shorter, so a steady trickle of small writes can no longer hold data back
+// indefinitely. Setting a flush timeout of d or longer leaves the budget alone in
+// charge. Zero, the default, disables the bound.
+func WithLatencyBudget(d time.Duration) Option {
+	return func(o *options) {
+		o.latencyBudget = d
+	}
+}
+
+// budgetDelayLocked caps delay so the oldest buffered byte is flushed within the
+// WithLatencyBudget bound.
+func (nw *NagleWriter) budgetDelayLocked(delay time.Duration) time.Duration {
+	if nw.latencyBudget <= 0 || nw.oldest.IsZero() {
+		return delay
+	}
+	left := nw.oldest.Add(nw.latencyBudget).Sub(nw.clock.Now())
+	return max(min(delay, left), 0)
+}
@@ -0,0 +1,41 @@
+package nagle_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestNagleWriter_WithLatencyBudget(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(20*time.Millisecond),
+		nagle.WithClock(clock), nagle.WithLatencyBudget(50*time.Millisecond))
+	defer nagleWriter.Close()
+
+	// A trickle of writes keeps pushing the flush timeout back, but not the budget
+	for i := 0; i < 4; i++ {
+		nagleWriter.Write([]byte("a"))
+		clock.Advance(15 * time.Millisecond)
+	}
+	if out.String() != "aaaa" {
+		t.Fatalf("expected a flush within the budget, but got: '%s'", out.String())
+	}
+	if stats := nagleWriter.Stats(); stats.TimeoutFlushes != 1 {
+		t.Fatalf("expected 1 timeout flush, but got: %d", stats.TimeoutFlushes)
+	}
+
+	// The budget starts over with the next batch
+	nagleWriter.Write([]byte("b"))
+	clock.Advance(15 * time.Millisecond)
+	if out.String() != "aaaa" {
+		t.Fatalf("expected the next batch to wait, but got: '%s'", out.String())
+	}
+	clock.Advance(5 * time.Millisecond)
+	if out.String() != "aaaab" {
+		t.Fatalf("expected the flush timeout to apply, but got: '%s'", out.String())
+	}
+}
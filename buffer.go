@@ -0,0 +1,153 @@
+package nagle
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+)
+
+// maxPooledBufferSize keeps buffers that grew unusually large out of the pool.
+const maxPooledBufferSize = 64 << 10
+
+// bufferPool recycles contiguous buffers across wrappers, so servers creating one
+// wrapper per connection do not allocate a fresh buffer for each of them.
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// newBatchBuffer returns a pooled buffer with room for a batch of size bytes, or for
+// the prealloc bytes of WithPreallocatedBuffer when set.
+func newBatchBuffer(size, prealloc int) *bytes.Buffer {
+	buf := getBuffer()
+	if prealloc > 0 {
+		buf.Grow(prealloc)
+	} else if size <= maxPooledBufferSize {
+		// Growing the buffer up front keeps appends in Write from allocating.
+		buf.Grow(size)
+	}
+	return buf
+}
+
+// releaseFlushBuffer returns b to the pool when it came from there.
+func releaseFlushBuffer(b flushBuffer) {
+	if buf, ok := b.(*bytes.Buffer); ok && buf.Cap() <= maxPooledBufferSize {
+		buf.Reset()
+		bufferPool.Put(buf)
+	}
+}
+
+// flushBuffer holds the bytes awaiting flush.
+// *bytes.Buffer is the default implementation.
+type flushBuffer interface {
+	io.Writer
+	io.WriterTo
+	Bytes() []byte
+	Len() int
+	Reset()
+	Truncate(n int)
+}
+
+// segmentBuffer keeps each write as its own segment so a flush can hand all of them
+// to the kernel in a single writev call through net.Buffers.
+type segmentBuffer struct {
+	segments net.Buffers
+	size     int
+}
+
+func (b *segmentBuffer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b.segments = append(b.segments, append([]byte(nil), p...))
+	b.size += len(p)
+	return len(p), nil
+}
+
+// writeOwned queues p as a segment of its own without copying it.
+func (b *segmentBuffer) writeOwned(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	b.segments = append(b.segments, p)
+	b.size += len(p)
+}
+
+// WriteTo writes all segments to w. Bytes that are not written stay queued.
+func (b *segmentBuffer) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	var err error
+	if supportsWritev(w) {
+		n, err = b.segments.WriteTo(w)
+		b.size -= int(n)
+	} else {
+		// net.Buffers would skip the unwritten tail of a segment on a short write.
+		for len(b.segments) > 0 && err == nil {
+			var m int
+			m, err = w.Write(b.segments[0])
+			n += int64(m)
+			b.size -= m
+			if m < len(b.segments[0]) {
+				b.segments[0] = b.segments[0][m:]
+				break
+			}
+			b.segments = b.segments[1:]
+		}
+	}
+	if len(b.segments) == 0 {
+		b.segments = nil
+	}
+	if err == nil && b.size > 0 {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// Bytes returns a contiguous copy of the queued segments.
+func (b *segmentBuffer) Bytes() []byte {
+	out := make([]byte, 0, b.size)
+	for _, segment := range b.segments {
+		out = append(out, segment...)
+	}
+	return out
+}
+
+func (b *segmentBuffer) Len() int {
+	return b.size
+}
+
+// Truncate discards all but the first n queued bytes.
+func (b *segmentBuffer) Truncate(n int) {
+	kept := 0
+	for i, segment := range b.segments {
+		if kept+len(segment) >= n {
+			b.segments[i] = segment[:n-kept]
+			b.segments = b.segments[:i+1]
+			b.size = n
+			return
+		}
+		kept += len(segment)
+	}
+}
+
+func (b *segmentBuffer) Reset() {
+	b.segments = nil
+	b.size = 0
+}
+
+// supportsWritev reports whether net.Buffers.WriteTo uses a single vectored write for w.
+// For any other writer it issues one Write per segment, defeating coalescing.
+func supportsWritev(w io.Writer) bool {
+	switch w.(type) {
+	case *net.TCPConn, *net.UnixConn, *net.IPConn, *net.UDPConn:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,214 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSegmentBuffer_WriteTo(t *testing.T) {
+	var b segmentBuffer
+	b.Write([]byte("012"))
+	b.Write([]byte("3456"))
+	if b.Len() != 7 {
+		t.Fatalf("expected length 7, got %d", b.Len())
+	}
+
+	// A short write leaves the rest queued for the next flush
+	short := &ShortWriteReadWriteCloser{limit: 2}
+	n, err := b.WriteTo(short)
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected ErrShortWrite, but got: %v", err)
+	}
+	if n != 2 || b.Len() != 5 {
+		t.Fatalf("expected 2 bytes written and 5 queued, got %d and %d", n, b.Len())
+	}
+
+	var out bytes.Buffer
+	if _, err := b.WriteTo(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "23456" || b.Len() != 0 {
+		t.Fatalf("expected '23456' with nothing queued, got '%s' and %d", out.String(), b.Len())
+	}
+}
+
+func TestNagleConn_VectoredFlush(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nagleConn := NewConn(conn, WithBufferSize(100), WithFlushTimeout(time.Hour), WithVectoredFlush())
+	if _, ok := nagleConn.buffer.(*segmentBuffer); !ok {
+		t.Fatalf("expected segment buffer for TCP conn, got %T", nagleConn.buffer)
+	}
+
+	for i := 0; i < 10; i++ {
+		nagleConn.Write([]byte("0123456789"))
+	}
+	nagleConn.Close()
+
+	select {
+	case data := <-received:
+		if !bytes.Equal(data, bytes.Repeat([]byte("0123456789"), 10)) {
+			t.Fatalf("received data does not match, got '%s'", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("data was not received")
+	}
+}
+
+func TestNagleConn_VectoredFlushLayered(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A layer in front of the conn would get a Write per segment, so the buffer is contiguous
+	nagleConn := NewConn(conn, WithBufferSize(100), WithFlushTimeout(time.Hour), WithVectoredFlush(), WithWriteDeadline(time.Second))
+	if _, ok := nagleConn.buffer.(*bytes.Buffer); !ok {
+		t.Fatalf("expected contiguous buffer behind a layer, got %T", nagleConn.buffer)
+	}
+
+	for i := 0; i < 10; i++ {
+		nagleConn.Write([]byte("0123456789"))
+	}
+	nagleConn.Close()
+
+	select {
+	case data := <-received:
+		if !bytes.Equal(data, bytes.Repeat([]byte("0123456789"), 10)) {
+			t.Fatalf("received data does not match, got '%s'", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("data was not received")
+	}
+}
+
+func TestNew_VectoredFlushFallback(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithVectoredFlush())
+	defer nagleWrapper.Close()
+
+	if _, ok := nagleWrapper.buffer.(*bytes.Buffer); !ok {
+		t.Fatalf("expected contiguous buffer for non-socket writer, got %T", nagleWrapper.buffer)
+	}
+}
+
+func TestNagleWrapper_BufferReleasedOnClose(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithBufferSize(10), WithFlushTimeout(time.Hour))
+	nagleWrapper.Write([]byte("01234"))
+	nagleWrapper.Close()
+
+	if nagleWrapper.buffer != nil {
+		t.Fatal("expected buffer to be released on close")
+	}
+	if stats := nagleWrapper.Stats(); stats.Buffered != 0 {
+		t.Fatalf("expected 0 bytes buffered after close, got %d", stats.Buffered)
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		nw := New(&MockReadWriteCloser{}, WithBufferSize(1024), WithFlushTimeout(time.Hour))
+		nw.Write([]byte("0123456789"))
+		nw.Close()
+	}
+}
+
+func TestNagleConn_WriteOwned(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nagleConn := NewConn(conn, WithBufferSize(100), WithFlushTimeout(time.Hour), WithVectoredFlush())
+
+	// The slice is queued without being copied
+	owned := []byte("0123456789")
+	nagleConn.WriteOwned(owned)
+	nagleConn.Write([]byte("abc"))
+	segments := nagleConn.buffer.(*segmentBuffer).segments
+	if len(segments) != 2 || &segments[0][0] != &owned[0] {
+		t.Fatalf("expected the owned slice to be queued as is")
+	}
+	nagleConn.Close()
+
+	select {
+	case data := <-received:
+		if string(data) != "0123456789abc" {
+			t.Fatalf("received data does not match, got '%s'", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("data was not received")
+	}
+}
+
+func TestNagleWrapper_WriteOwnedFallback(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+
+	// Without writev the slice is copied like in Write
+	owned := []byte("0123")
+	if n, err := nagleWrapper.WriteOwned(owned); n != 4 || err != nil {
+		t.Fatalf("expected to write 4 bytes, but got: %d, %v", n, err)
+	}
+	copy(owned, "xxxx")
+	nagleWrapper.Close()
+	if mockRWC.String() != "0123" {
+		t.Fatalf("expected '0123', but got: '%s'", mockRWC.String())
+	}
+}
@@ -0,0 +1,30 @@
+package nagle
+
+import (
+	"bufio"
+	"unicode/utf8"
+)
+
+// The wrappers can stand in for a bufio.Writer in code that only needs to flush it.
+var _ interface{ Flush() error } = (*NagleWrapper)(nil)
+
+// WriteRune is like Write for the UTF-8 encoding of r, like bufio.Writer.WriteRune.
+func (nw *NagleWriter) WriteRune(r rune) (int, error) {
+	var buf [utf8.UTFMax]byte
+	return nw.Write(utf8.AppendRune(buf[:0], r))
+}
+
+// ReadWriter pairs a bufio.Reader reading from a wrapper with the wrapper itself, like
+// bufio.ReadWriter does with a bufio.Writer, for code written against the latter. The
+// writes are not buffered a second time: they go to the wrapper, and Flush and Close
+// are those of the wrapper, while reads go through Read, so WithFlushOnRead and the read
+// deadline apply.
+type ReadWriter struct {
+	*bufio.Reader
+	*NagleWriter
+}
+
+// NewReadWriter returns a ReadWriter backed by nw.
+func NewReadWriter(nw *NagleWrapper) *ReadWriter {
+	return &ReadWriter{Reader: bufio.NewReader(nw), NagleWriter: nw.NagleWriter}
+}
@@ -0,0 +1,41 @@
+package nagle
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_ReadWriter(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	rw := NewReadWriter(New(client, WithBufferSize(100), WithFlushTimeout(time.Hour)))
+	defer rw.Close()
+
+	go func() {
+		r := bufio.NewReader(server)
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		server.Write([]byte("echo: " + line))
+	}()
+
+	// Written like to a bufio.Writer: nothing is sent until Flush
+	rw.WriteString("hello ")
+	rw.WriteRune('é')
+	rw.WriteByte('\n')
+	var flusher interface{ Flush() error } = rw
+	if err := flusher.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if line != "echo: hello é\n" {
+		t.Fatalf("expected 'echo: hello é', but got: %q", line)
+	}
+}
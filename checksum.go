@@ -0,0 +1,51 @@
+package nagle
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// checksumSize is the size of the CRC32C checksum appended to each frame.
+const checksumSize = crc32.Size
+
+// ErrChecksumMismatch is returned by FrameReader when a frame does not match its checksum.
+var ErrChecksumMismatch = errors.New("nagle: checksum mismatch")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// WithChecksum appends a CRC32C checksum to every flushed frame, for links that may
+// corrupt data such as serial ports. It is computed after any transform and middlewares,
+// and WithFraming is implied with FramePrefixUint32 unless another prefix is chosen.
+// Have the FrameReader on the other end verify it with SetChecksum.
+func WithChecksum() Option {
+	return func(o *options) {
+		o.checksum = true
+	}
+}
+
+// checksumMiddleware appends the CRC32C checksum of each batch to it.
+func checksumMiddleware(batch []byte) ([]byte, error) {
+	out := make([]byte, 0, len(batch)+checksumSize)
+	out = append(out, batch...)
+	return binary.BigEndian.AppendUint32(out, crc32.Checksum(batch, castagnoli)), nil
+}
+
+// SetChecksum makes the reader verify and remove the checksum added to each frame by
+// a wrapper configured with WithChecksum. A frame that does not match its checksum
+// fails with ErrChecksumMismatch.
+func (fr *FrameReader) SetChecksum(enabled bool) {
+	fr.checksum = enabled
+}
+
+// verifyChecksum returns the payload of frame without its checksum.
+func verifyChecksum(frame []byte) ([]byte, error) {
+	if len(frame) < checksumSize {
+		return nil, ErrChecksumMismatch
+	}
+	payload, sum := frame[:len(frame)-checksumSize], frame[len(frame)-checksumSize:]
+	if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(sum) {
+		return nil, ErrChecksumMismatch
+	}
+	return payload, nil
+}
@@ -0,0 +1,101 @@
+package nagle_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestNagleWriter_FakeClock(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(10), nagle.WithFlushTimeout(50*time.Millisecond), nagle.WithClock(clock))
+	defer nagleWriter.Close()
+
+	// No timer is armed until there is data to flush
+	if clock.Timers() != 0 {
+		t.Fatalf("expected no armed timers, got %d", clock.Timers())
+	}
+
+	nagleWriter.Write([]byte("01"))
+	clock.Advance(40 * time.Millisecond)
+
+	// Each write pushes the flush deadline back
+	nagleWriter.Write([]byte("23"))
+	clock.Advance(40 * time.Millisecond)
+	if out.String() != "" {
+		t.Fatalf("expected buffer to be empty, but got: %s", out.String())
+	}
+
+	clock.Advance(10 * time.Millisecond)
+	if out.String() != "0123" {
+		t.Fatalf("expected buffer to contain '0123', but got: %s", out.String())
+	}
+	if clock.Timers() != 0 {
+		t.Fatalf("expected timer to be disarmed after flush, got %d", clock.Timers())
+	}
+}
+
+func TestNagleWriter_FakeClockFlushOrder(t *testing.T) {
+	var out bytes.Buffer
+	var batches []string
+	var triggers []nagle.FlushTrigger
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(4), nagle.WithFlushTimeout(50*time.Millisecond), nagle.WithClock(clock),
+		nagle.WithFlushHook(func(batch []byte, trigger nagle.FlushTrigger) {
+			batches = append(batches, string(batch))
+			triggers = append(triggers, trigger)
+		}))
+
+	// With a fake clock every flush happens at a known point, so the sequence is fixed
+	nagleWriter.Write([]byte("ab"))
+	clock.Advance(50 * time.Millisecond)
+	nagleWriter.Write([]byte("cdef"))
+	nagleWriter.Write([]byte("g"))
+	nagleWriter.Flush()
+	nagleWriter.Write([]byte("h"))
+	nagleWriter.Close()
+
+	want := []string{"ab", "cdef", "g", "h"}
+	wantTriggers := []nagle.FlushTrigger{nagle.FlushTriggerTimeout, nagle.FlushTriggerSize, nagle.FlushTriggerExplicit, nagle.FlushTriggerClose}
+	if len(batches) != len(want) {
+		t.Fatalf("expected batches %q, but got: %q", want, batches)
+	}
+	for i := range want {
+		if batches[i] != want[i] || triggers[i] != wantTriggers[i] {
+			t.Fatalf("expected batch %q by %v, but got: %q by %v", want[i], wantTriggers[i], batches[i], triggers[i])
+		}
+	}
+	if out.String() != "abcdefgh" {
+		t.Fatalf("expected 'abcdefgh', but got: %s", out.String())
+	}
+}
+
+func TestNagleWriter_FakeClockFlushInfo(t *testing.T) {
+	var infos []nagle.FlushInfo
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour), nagle.WithClock(clock),
+		nagle.WithFlushObserver(func(info nagle.FlushInfo) { infos = append(infos, info) }))
+	defer nagleWriter.Close()
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "write")
+	nagleWriter.WriteContext(ctx, []byte("01"))
+	clock.Advance(30 * time.Millisecond)
+	nagleWriter.Write([]byte("23"))
+	clock.Advance(20 * time.Millisecond)
+	nagleWriter.Flush()
+
+	// The queue time is that of the oldest byte
+	if len(infos) != 1 || infos[0].Queued != 50*time.Millisecond || !infos[0].Start.Equal(clock.Now()) {
+		t.Fatalf("expected a flush queued for 50ms, but got: %+v", infos)
+	}
+	if len(infos[0].Contexts) != 1 || infos[0].Contexts[0].Value(key{}) != "write" {
+		t.Fatalf("expected the context of the write, but got: %v", infos[0].Contexts)
+	}
+}
@@ -0,0 +1,81 @@
+package nagle
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// NagleConn wraps a net.Conn with Nagle's algorithm buffering logic.
+// It implements net.Conn, so it can be used wherever a net.Conn is expected.
+type NagleConn struct {
+	*NagleWrapper
+	noDelay bool
+}
+
+var (
+	_ net.Conn     = (*NagleConn)(nil)
+	_ syscall.Conn = (*NagleConn)(nil)
+)
+
+// NewConn creates a new net.Conn wrapper with Nagle's algorithm configured by opts.
+// Unless disabled with WithTCPNoDelay, TCP_NODELAY is set on TCP connections.
+func NewConn(conn net.Conn, opts ...Option) *NagleConn {
+	o := buildOptions(opts)
+	if nc, ok := reuseConn(conn); ok && o.noNest {
+		return nc
+	}
+	nc := &NagleConn{
+		NagleWrapper: newWrapper(conn, o),
+		noDelay:      o.tcpNoDelay,
+	}
+	if o.tcpNoDelay {
+		nc.closer = setNoDelay(conn, nc.closer)
+	}
+	if nc.reconnect != nil {
+		nc.reconnect.swap = nc.swapLocked
+	}
+	return nc
+}
+
+// LocalAddr returns the local network address of the underlying connection.
+func (nc *NagleConn) LocalAddr() net.Addr {
+	return nc.NetConn().LocalAddr()
+}
+
+// RemoteAddr returns the remote network address of the underlying connection.
+func (nc *NagleConn) RemoteAddr() net.Addr {
+	return nc.NetConn().RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the underlying connection.
+// The write deadline applies to flushes, including those triggered by the timer.
+func (nc *NagleConn) SetDeadline(t time.Time) error {
+	return nc.NetConn().SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (nc *NagleConn) SetReadDeadline(t time.Time) error {
+	return nc.NetConn().SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+// The deadline applies to flushes, including those triggered by the timer.
+func (nc *NagleConn) SetWriteDeadline(t time.Time) error {
+	return nc.NetConn().SetWriteDeadline(t)
+}
+
+// SyscallConn flushes any buffered data and returns the raw connection of the underlying
+// conn, so socket options such as SO_SNDBUF can be set through the wrapper. It returns
+// errors.ErrUnsupported when the underlying conn does not implement syscall.Conn.
+func (nc *NagleConn) SyscallConn() (syscall.RawConn, error) {
+	sc, ok := nc.NetConn().(syscall.Conn)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if err := nc.Flush(); err != nil {
+		return nil, err
+	}
+	return sc.SyscallConn()
+}
@@ -0,0 +1,175 @@
+package nagle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNagleConn_WriteAndRead(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client, WithBufferSize(10), WithFlushTimeout(20*time.Millisecond))
+	defer nagleConn.Close()
+
+	if nagleConn.LocalAddr() != client.LocalAddr() {
+		t.Fatalf("expected local address %v, got %v", client.LocalAddr(), nagleConn.LocalAddr())
+	}
+	if nagleConn.RemoteAddr() != client.RemoteAddr() {
+		t.Fatalf("expected remote address %v, got %v", client.RemoteAddr(), nagleConn.RemoteAddr())
+	}
+
+	// Small writes are coalesced into a single flush
+	nagleConn.Write([]byte("012"))
+	nagleConn.Write([]byte("34"))
+
+	buf := make([]byte, 10)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(buf[:n]) != "01234" {
+		t.Fatalf("expected to read '01234', but got: '%s'", string(buf[:n]))
+	}
+
+	go server.Write([]byte("reply"))
+	n, err = io.ReadFull(nagleConn, buf[:5])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(buf[:n]) != "reply" {
+		t.Fatalf("expected to read 'reply', but got: '%s'", string(buf[:n]))
+	}
+}
+
+func TestNagleConn_Deadlines(t *testing.T) {
+	client, server := net.Pipe()
+	nagleConn := NewConn(client, WithBufferSize(1), WithFlushTimeout(time.Hour))
+	defer nagleConn.Close()
+	defer server.Close()
+
+	if err := nagleConn.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := nagleConn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected ErrDeadlineExceeded, but got: %v", err)
+	}
+
+	// Nobody reads from the server side, so the size-triggered flush times out
+	if err := nagleConn.SetWriteDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := nagleConn.Write([]byte("0")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected ErrDeadlineExceeded, but got: %v", err)
+	}
+
+	if err := nagleConn.SetDeadline(time.Time{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNagleConn_CloseWithTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client, WithBufferSize(100), WithFlushTimeout(time.Hour))
+
+	// Nobody reads from the server side, so the final flush hangs
+	nagleConn.Write([]byte("stuck"))
+	if err := nagleConn.CloseWithTimeout(20 * time.Millisecond); !errors.Is(err, ErrFlushTimeout) {
+		t.Fatalf("expected ErrFlushTimeout, but got: %v", err)
+	}
+
+	if _, err := client.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected underlying conn to be closed, but got: %v", err)
+	}
+}
+
+// closeCountingConn counts the calls to Close of the conn it wraps.
+type closeCountingConn struct {
+	net.Conn
+	closes atomic.Int64
+}
+
+func (c *closeCountingConn) Close() error {
+	c.closes.Add(1)
+	return c.Conn.Close()
+}
+
+func TestNagleConn_CloseWithTimeoutClosesOnce(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := &closeCountingConn{Conn: client}
+	nagleConn := NewConn(conn, WithBufferSize(100), WithFlushTimeout(time.Hour))
+
+	nagleConn.Write([]byte("stuck"))
+	if err := nagleConn.CloseWithTimeout(20 * time.Millisecond); !errors.Is(err, ErrFlushTimeout) {
+		t.Fatalf("expected ErrFlushTimeout, but got: %v", err)
+	}
+
+	// Flush waits for the Close left running in the background
+	if err := nagleConn.Flush(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+	if closes := conn.closes.Load(); closes != 1 {
+		t.Fatalf("expected the conn to be closed once, but got: %d", closes)
+	}
+}
+
+func TestNagleConn_CloseContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client, WithBufferSize(100), WithFlushTimeout(time.Hour))
+
+	nagleConn.Write([]byte("data"))
+	go io.Copy(io.Discard, server)
+	if err := nagleConn.CloseContext(context.Background()); err != nil {
+		t.Fatalf("unexpected error on close: %v", err)
+	}
+}
+
+func TestNagleConn_SyscallConn(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer server.Close()
+	nagleConn := NewConn(conn, WithFlushTimeout(time.Hour))
+	defer nagleConn.Close()
+
+	// The buffered data is flushed before handing out the raw conn
+	nagleConn.Write([]byte("ab"))
+	raw, err := nagleConn.SyscallConn()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "ab" {
+		t.Fatalf("expected to read 'ab', but got: '%s', %v", buf, err)
+	}
+	if err := raw.Control(func(fd uintptr) {}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client, other := net.Pipe()
+	defer other.Close()
+	pipeConn := NewConn(client)
+	defer pipeConn.Close()
+	if _, err := pipeConn.SyscallConn(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected %v, but got: %v", errors.ErrUnsupported, err)
+	}
+}
@@ -0,0 +1,31 @@
+package nagle
+
+// Cork suspends size and timeout triggered flushes, like TCP_CORK, so several writes
+// can be released as a single underlying Write by Uncork. Explicit calls to Flush and
+// Close still send buffered data, and limits set with WithMaxPendingBytes or
+// WithMaxBufferSize are still enforced. Cork calls do not nest.
+func (nw *NagleWriter) Cork() {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	nw.corked = true
+}
+
+// Uncork resumes normal flushing and writes everything buffered while corked.
+func (nw *NagleWriter) Uncork() error {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed {
+		return ErrClosed
+	}
+
+	nw.corked = false
+	if err := nw.takeAsyncErrLocked(); err != nil {
+		return err
+	}
+
+	_, err := nw.flushLocked(FlushTriggerExplicit)
+	return err
+}
@@ -0,0 +1,46 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+// RecordingReadWriteCloser is a mock that records each underlying Write separately.
+type RecordingReadWriteCloser struct {
+	MockReadWriteCloser
+	writes []string
+}
+
+func (m *RecordingReadWriteCloser) Write(p []byte) (int, error) {
+	m.writes = append(m.writes, string(p))
+	return m.MockReadWriteCloser.Write(p)
+}
+
+func TestNagleWrapper_CorkUncork(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(10*time.Millisecond))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Cork()
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Write([]byte("4567"))
+	time.Sleep(50 * time.Millisecond)
+
+	// Neither size nor timeout triggers fire while corked
+	if len(mockRWC.writes) != 0 {
+		t.Fatalf("expected no writes while corked, got %q", mockRWC.writes)
+	}
+
+	if err := nagleWrapper.Uncork(); err != nil {
+		t.Fatalf("unexpected error on uncork: %v", err)
+	}
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "01234567" {
+		t.Fatalf("expected a single write of '01234567', got %q", mockRWC.writes)
+	}
+
+	// Normal size triggers resume after uncork
+	nagleWrapper.Write([]byte("89ab"))
+	if len(mockRWC.writes) != 2 {
+		t.Fatalf("expected 2 writes after uncork, got %q", mockRWC.writes)
+	}
+}
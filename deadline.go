@@ -0,0 +1,155 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// readDeadliner is implemented by streams with read deadlines of their own, such as *os.File.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// readResult is the outcome of a read made in the background.
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// deadlineReader puts a deadline on reads from a stream that has none. Each read runs in
+// a goroutine; a Read that outlives the deadline gives up waiting but leaves the read
+// running, and whatever it returns is delivered by the next Read.
+type deadlineReader struct {
+	src   io.Reader
+	clock Clock
+
+	// mutex protects deadline and changed, which is closed when the deadline moves.
+	mutex    sync.Mutex
+	deadline time.Time
+	changed  chan struct{}
+
+	// readMutex serializes Read calls and protects the fields below.
+	readMutex sync.Mutex
+	pending   chan readResult
+	leftover  []byte
+	err       error
+}
+
+func newDeadlineReader(src io.Reader, clock Clock) *deadlineReader {
+	return &deadlineReader{src: src, clock: clock, changed: make(chan struct{})}
+}
+
+func (r *deadlineReader) setDeadline(t time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deadline = t
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	r.readMutex.Lock()
+	defer r.readMutex.Unlock()
+
+	if len(r.leftover) > 0 || r.err != nil {
+		return r.deliverLocked(p)
+	}
+	if r.pending == nil {
+		pending := make(chan readResult, 1)
+		buf := make([]byte, len(p))
+		go func() {
+			n, err := r.src.Read(buf)
+			pending <- readResult{data: buf[:n], err: err}
+		}()
+		r.pending = pending
+	}
+
+	for {
+		r.mutex.Lock()
+		deadline, changed := r.deadline, r.changed
+		r.mutex.Unlock()
+
+		var timer Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := deadline.Sub(r.clock.Now())
+			if wait <= 0 {
+				select {
+				case res := <-r.pending:
+					return r.receiveLocked(p, res)
+				default:
+					return 0, os.ErrDeadlineExceeded
+				}
+			}
+			timer = r.clock.NewTimer(wait)
+			expired = timer.C()
+		}
+
+		select {
+		case res := <-r.pending:
+			stopTimer(timer)
+			return r.receiveLocked(p, res)
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		case <-changed:
+			stopTimer(timer)
+		}
+	}
+}
+
+func stopTimer(t Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+func (r *deadlineReader) receiveLocked(p []byte, res readResult) (int, error) {
+	r.pending = nil
+	r.leftover = res.data
+	r.err = res.err
+	return r.deliverLocked(p)
+}
+
+// deliverLocked copies the result of the last background read into p. Its error is
+// returned once all of its data has been consumed.
+func (r *deadlineReader) deliverLocked(p []byte) (int, error) {
+	n := copy(p, r.leftover)
+	r.leftover = r.leftover[n:]
+	if len(r.leftover) > 0 {
+		return n, nil
+	}
+	err := r.err
+	r.err = nil
+	return n, err
+}
+
+// SetReadDeadline sets the deadline for pending and future Read calls. A zero t means
+// Read does not time out. Streams with a SetReadDeadline method of their own, such as
+// *os.File, are given the deadline directly. On any other stream, reads made after the
+// first call to SetReadDeadline run in a background goroutine, and a Read still waiting
+// at the deadline fails with os.ErrDeadlineExceeded; the data that read eventually
+// receives is returned by the next one.
+func (nw *NagleWrapper) SetReadDeadline(t time.Time) error {
+	if r := nw.deadlines.Load(); r != nil {
+		r.setDeadline(t)
+		return nil
+	}
+	s := nw.source.Load()
+	if d, ok := s.rwc.(readDeadliner); ok {
+		if err := d.SetReadDeadline(t); !errors.Is(err, os.ErrNoDeadline) {
+			return err
+		}
+	}
+
+	src := io.Reader(s.rwc)
+	if s.reader != nil {
+		src = s.reader
+	}
+	nw.deadlines.CompareAndSwap(nil, newDeadlineReader(src, nw.clock))
+	nw.deadlines.Load().setDeadline(t)
+	return nil
+}
@@ -0,0 +1,62 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// PipeReadWriteCloser reads from a pipe, which has no deadlines of its own, and discards writes.
+type PipeReadWriteCloser struct {
+	*io.PipeReader
+}
+
+func (m PipeReadWriteCloser) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func TestNagleWrapper_SetReadDeadline(t *testing.T) {
+	pr, pw := io.Pipe()
+	nagleWrapper := New(PipeReadWriteCloser{pr})
+	defer nagleWrapper.Close()
+
+	if err := nagleWrapper.SetReadDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := make([]byte, 10)
+	if _, err := nagleWrapper.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected ErrDeadlineExceeded, but got: %v", err)
+	}
+
+	// The read that timed out is still pending and its data is not lost
+	nagleWrapper.SetReadDeadline(time.Time{})
+	go pw.Write([]byte("hello"))
+	n, err := nagleWrapper.Read(buf[:3])
+	if err != nil || string(buf[:n]) != "hel" {
+		t.Fatalf("expected to read 'hel', but got: '%s' (%v)", string(buf[:n]), err)
+	}
+	n, err = nagleWrapper.Read(buf)
+	if err != nil || string(buf[:n]) != "lo" {
+		t.Fatalf("expected to read 'lo', but got: '%s' (%v)", string(buf[:n]), err)
+	}
+
+	// Moving the deadline wakes up a blocked Read
+	done := make(chan error, 1)
+	go func() {
+		_, err := nagleWrapper.Read(buf)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	nagleWrapper.SetReadDeadline(time.Now())
+	if err := <-done; !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected ErrDeadlineExceeded, but got: %v", err)
+	}
+
+	pw.Close()
+	nagleWrapper.SetReadDeadline(time.Time{})
+	if _, err := nagleWrapper.Read(buf); err != io.EOF {
+		t.Fatalf("expected EOF, but got: %v", err)
+	}
+}
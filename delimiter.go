@@ -0,0 +1,43 @@
+package nagle
+
+import "bytes"
+
+// WithFlushDelimiter flushes as soon as the buffer holds delim, such as '\n' for line
+// oriented protocols. Data up to and including the last delimiter is sent, while a
+// trailing partial line keeps coalescing under the size and timeout triggers.
+func WithFlushDelimiter(delim []byte) Option {
+	return func(o *options) {
+		if len(delim) == 0 {
+			o.delimiter = nil
+			return
+		}
+		o.delimiter = append([]byte(nil), delim...)
+	}
+}
+
+// flushDelimitedLocked flushes the buffer up to its last delimiter if data appended
+// after the first before bytes completed one.
+func (nw *NagleWriter) flushDelimitedLocked(before int) error {
+	buf := nw.buffer.Bytes()
+	// A delimiter may straddle the previously buffered data and the new write.
+	start := before - len(nw.delimiter) + 1
+	if start < 0 {
+		start = 0
+	}
+	last := bytes.LastIndex(buf[start:], nw.delimiter)
+	if last < 0 {
+		return nil
+	}
+	cut := start + last + len(nw.delimiter)
+
+	// Hold back the partial line while the complete ones are flushed.
+	tail := append([]byte(nil), buf[cut:]...)
+	nw.buffer.Truncate(cut)
+	_, err := nw.flushLocked(FlushTriggerDelimiter)
+	nw.buffer.Write(tail)
+	if len(tail) > 0 && nw.pendingWrites == 0 {
+		// The partial line is the start of a write still being buffered.
+		nw.pendingWrites = 1
+	}
+	return err
+}
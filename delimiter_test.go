@@ -0,0 +1,43 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithFlushDelimiter(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithFlushDelimiter([]byte("\r\n")))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("HELLO"))
+	if len(mockRWC.writes) != 0 {
+		t.Fatalf("expected no writes for a partial line, got %q", mockRWC.writes)
+	}
+
+	// The delimiter straddles two writes; the partial line after it stays buffered
+	nagleWrapper.Write([]byte("\r"))
+	nagleWrapper.Write([]byte("\nWOR"))
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "HELLO\r\n" {
+		t.Fatalf("expected a single write of 'HELLO\\r\\n', got %q", mockRWC.writes)
+	}
+
+	nagleWrapper.Write([]byte("LD\r\nA\r\nB"))
+	if len(mockRWC.writes) != 2 || mockRWC.writes[1] != "WORLD\r\nA\r\n" {
+		t.Fatalf("expected second write of 'WORLD\\r\\nA\\r\\n', got %q", mockRWC.writes)
+	}
+	if stats := nagleWrapper.Stats(); stats.DelimiterFlushes != 2 || stats.Buffered != 1 {
+		t.Fatalf("expected 2 delimiter flushes and 1 byte buffered, got %+v", stats)
+	}
+}
+
+func TestSegmentBuffer_Truncate(t *testing.T) {
+	var b segmentBuffer
+	b.Write([]byte("012"))
+	b.Write([]byte("345"))
+	b.Write([]byte("678"))
+	b.Truncate(4)
+	if string(b.Bytes()) != "0123" || b.Len() != 4 {
+		t.Fatalf("expected '0123', got '%s' (%d)", b.Bytes(), b.Len())
+	}
+}
@@ -0,0 +1,52 @@
+package nagle
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+// Dialer dials connections and returns them wrapped in a NagleConn.
+// The zero value dials plain connections with the default net.Dialer settings.
+type Dialer struct {
+	// Dialer establishes the underlying connections.
+	Dialer net.Dialer
+	// TLSConfig, when not nil, makes Dial perform a TLS client handshake over the
+	// dialed connection. If ServerName is empty it is taken from the address.
+	TLSConfig *tls.Config
+	// Options configure every returned NagleConn. TCP_NODELAY is set on TCP
+	// connections unless they include WithTCPNoDelay(false).
+	Options []Option
+}
+
+// Dial connects to address on the named network, as net.Dial does, and wraps the connection.
+func (d *Dialer) Dial(network, address string) (*NagleConn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext is like Dial but uses ctx to bound the dial and the TLS handshake.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (*NagleConn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if d.TLSConfig != nil {
+		config := d.TLSConfig
+		if config.ServerName == "" {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				host = address
+			}
+			config = config.Clone()
+			config.ServerName = host
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	return NewConn(conn, d.Options...), nil
+}
@@ -0,0 +1,74 @@
+package nagle
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDialer_Dial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf, _ := io.ReadAll(conn)
+		received <- string(buf)
+	}()
+
+	d := &Dialer{Options: []Option{WithBufferSize(10), WithFlushTimeout(time.Hour)}}
+	conn, err := d.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.Write([]byte("012"))
+	conn.Write([]byte("34"))
+	conn.Close()
+
+	if got := <-received; got != "01234" {
+		t.Fatalf("expected to read '01234', but got: '%s'", got)
+	}
+}
+
+func TestDialer_DialTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	config := server.Client().Transport.(*http.Transport).TLSClientConfig
+	d := &Dialer{TLSConfig: config}
+	conn, err := d.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Write(conn)
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Fatalf("expected body 'hello', but got: '%s'", string(body))
+	}
+}
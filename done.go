@@ -0,0 +1,92 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// closedChan is returned by Done once the wrapper has already failed.
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+// doneState records the first fatal error seen on the underlying stream. It has a
+// lock of its own so Done and Err never wait behind a flush stuck in the underlying writer.
+type doneState struct {
+	mutex sync.Mutex
+	done  chan struct{}
+	err   error
+}
+
+// reset forgets the error recorded so far, for a wrapper moved to a new stream.
+func (d *doneState) reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.err != nil {
+		d.done = nil
+		d.err = nil
+	}
+}
+
+// Done returns a channel that is closed when a flush or read fails with a fatal error,
+// or when the wrapper is closed. Timeouts and short writes are not fatal.
+func (nw *NagleWriter) Done() <-chan struct{} {
+	nw.doneState.mutex.Lock()
+	defer nw.doneState.mutex.Unlock()
+
+	if nw.doneState.done == nil {
+		if nw.doneState.err != nil {
+			return closedChan
+		}
+		nw.doneState.done = make(chan struct{})
+	}
+	return nw.doneState.done
+}
+
+// Err returns nil until Done is closed. Then it returns the fatal error that closed it,
+// or ErrClosed if the wrapper was closed first.
+func (nw *NagleWriter) Err() error {
+	nw.doneState.mutex.Lock()
+	defer nw.doneState.mutex.Unlock()
+
+	return nw.doneState.err
+}
+
+// fail closes Done with err unless it has already been closed.
+func (nw *NagleWriter) fail(err error) {
+	nw.doneState.mutex.Lock()
+	defer nw.doneState.mutex.Unlock()
+
+	if nw.doneState.err != nil {
+		return
+	}
+	nw.doneState.err = err
+	if nw.doneState.done != nil {
+		close(nw.doneState.done)
+	}
+}
+
+// checkFatal closes Done if err means the underlying stream can no longer be used,
+// and returns err.
+func (nw *NagleWriter) checkFatal(err error) error {
+	if isFatal(err) {
+		nw.fail(err)
+	}
+	return err
+}
+
+func isFatal(err error) bool {
+	if err == nil || errors.Is(err, io.ErrShortWrite) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+	return true
+}
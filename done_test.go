@@ -0,0 +1,72 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_DoneOnFlushError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	nagleWrapper := New(&FailingReadWriteCloser{err: writeErr}, WithBufferSize(100), WithFlushTimeout(10*time.Millisecond))
+	defer nagleWrapper.Close()
+
+	done := nagleWrapper.Done()
+	if err := nagleWrapper.Err(); err != nil {
+		t.Fatalf("expected no error before failure, but got: %v", err)
+	}
+
+	// The background flush fails
+	nagleWrapper.Write([]byte("data"))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Done to be closed after the flush failed")
+	}
+	if err := nagleWrapper.Err(); !errors.Is(err, writeErr) {
+		t.Fatalf("expected %v, but got: %v", writeErr, err)
+	}
+}
+
+func TestNagleWrapper_DoneOnRead(t *testing.T) {
+	pr, pw := io.Pipe()
+	nagleWrapper := New(PipeReadWriteCloser{pr})
+	defer nagleWrapper.Close()
+
+	// Timeouts are not fatal
+	nagleWrapper.SetReadDeadline(time.Now())
+	if _, err := nagleWrapper.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected ErrDeadlineExceeded, but got: %v", err)
+	}
+	if err := nagleWrapper.Err(); err != nil {
+		t.Fatalf("expected no error after a timeout, but got: %v", err)
+	}
+
+	pw.Close()
+	nagleWrapper.SetReadDeadline(time.Time{})
+	nagleWrapper.Read(make([]byte, 1))
+	select {
+	case <-nagleWrapper.Done():
+	default:
+		t.Fatal("expected Done to be closed after EOF")
+	}
+	if err := nagleWrapper.Err(); err != io.EOF {
+		t.Fatalf("expected EOF, but got: %v", err)
+	}
+}
+
+func TestNagleWrapper_DoneOnClose(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{})
+	nagleWrapper.Close()
+
+	select {
+	case <-nagleWrapper.Done():
+	default:
+		t.Fatal("expected Done to be closed after Close")
+	}
+	if err := nagleWrapper.Err(); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+}
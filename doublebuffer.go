@@ -0,0 +1,30 @@
+package nagle
+
+// WithDoubleBuffer moves the underlying Write of size, count and timeout flushes out of
+// the wrapper lock. The buffer is swapped with a spare one and written with the lock
+// released, so other writers keep appending to the fresh buffer instead of stalling
+// behind a slow flush; only the Write that fired the trigger waits for the flush. What
+// they buffer is sent once the write returns if a trigger is due by then, and is
+// otherwise left to the timer. Flush, Close and the other calls that must see the data
+// sent wait for a write in progress before making their own.
+func WithDoubleBuffer() Option {
+	return func(o *options) {
+		o.doubleBuffer = true
+	}
+}
+
+// autoFlushLocked flushes for an automatic trigger, writing with the lock released
+// under WithDoubleBuffer.
+func (nw *NagleWriter) autoFlushLocked(trigger FlushTrigger) error {
+	if !nw.doubleBuffer || nw.async != nil {
+		_, err := nw.flushLocked(trigger)
+		return err
+	}
+	if nw.leaderDone == nil {
+		nw.expireLocked()
+	}
+	if nw.leaderDone != nil || nw.pendingLocked() == 0 || nw.postponeFlushLocked(trigger) {
+		return nil
+	}
+	return nw.leadFlushLocked(trigger)
+}
@@ -0,0 +1,101 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithDoubleBuffer(t *testing.T) {
+	mockRWC := &GatedReadWriteCloser{started: make(chan struct{}, 1), release: make(chan struct{})}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour), WithDoubleBuffer())
+	defer nagleWrapper.Close()
+
+	done := make(chan struct{})
+	go func() {
+		nagleWrapper.Write([]byte("abcd"))
+		close(done)
+	}()
+	<-mockRWC.started
+
+	// Writes made while the flush is stuck return without waiting for it
+	nagleWrapper.Write([]byte("ef"))
+	nagleWrapper.Write([]byte("gh"))
+	if buffered := nagleWrapper.Buffered(); buffered != 4 {
+		t.Fatalf("expected 4 bytes buffered during the flush, but got: %d", buffered)
+	}
+	close(mockRWC.release)
+	<-done
+
+	// The second batch filled the buffer, so it was sent as soon as the first returned
+	if writes := mockRWC.Writes(); len(writes) != 2 || writes[0] != "abcd" || writes[1] != "efgh" {
+		t.Fatalf("expected writes 'abcd' and 'efgh', but got: %q", writes)
+	}
+	if stats := nagleWrapper.Stats(); stats.SizeFlushes != 2 {
+		t.Fatalf("expected 2 size flushes, but got: %+v", stats)
+	}
+}
+
+func TestNagleWrapper_WithDoubleBufferLeavesBelowThreshold(t *testing.T) {
+	mockRWC := &GatedReadWriteCloser{started: make(chan struct{}, 1), release: make(chan struct{})}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour), WithDoubleBuffer())
+	defer nagleWrapper.Close()
+
+	done := make(chan struct{})
+	go func() {
+		nagleWrapper.Write([]byte("abcd"))
+		close(done)
+	}()
+	<-mockRWC.started
+	nagleWrapper.Write([]byte("e"))
+	close(mockRWC.release)
+	<-done
+
+	// A partial batch keeps coalescing until Flush
+	if writes := mockRWC.Writes(); len(writes) != 1 {
+		t.Fatalf("expected only the full batch to be written, but got: %q", writes)
+	}
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if writes := mockRWC.Writes(); len(writes) != 2 || writes[1] != "e" {
+		t.Fatalf("expected 'e' to be flushed, but got: %q", writes)
+	}
+}
+
+// thresholdStrategy flushes once the buffer holds at least n bytes.
+type thresholdStrategy struct {
+	n int
+}
+
+func (s thresholdStrategy) OnWrite(bufLen, writeLen int) bool {
+	return bufLen >= s.n
+}
+
+func (s thresholdStrategy) NextDeadline() time.Time {
+	return time.Time{}
+}
+
+func TestNagleWrapper_WithDoubleBufferStrategy(t *testing.T) {
+	mockRWC := &GatedReadWriteCloser{started: make(chan struct{}, 1), release: make(chan struct{})}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour), WithDoubleBuffer(),
+		WithStrategy(thresholdStrategy{n: 8}))
+	defer nagleWrapper.Close()
+
+	done := make(chan struct{})
+	go func() {
+		nagleWrapper.Write([]byte("01234567"))
+		close(done)
+	}()
+	<-mockRWC.started
+	nagleWrapper.Write([]byte("abcd"))
+	close(mockRWC.release)
+	<-done
+
+	// The strategy, not the buffer size, decides on the data buffered during the write
+	if writes := mockRWC.Writes(); len(writes) != 1 || writes[0] != "01234567" {
+		t.Fatalf("expected only '01234567' to be written, but got: %q", writes)
+	}
+	if buffered := nagleWrapper.Buffered(); buffered != 4 {
+		t.Fatalf("expected 4 bytes buffered, but got: %d", buffered)
+	}
+}
@@ -0,0 +1,45 @@
+package nagle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+var (
+	// ErrClosed is returned by calls made after the wrapper has been closed, or after
+	// CloseWrite for writes. It matches io.ErrClosedPipe and net.ErrClosed with
+	// errors.Is, for code written against the stream it replaces.
+	ErrClosed error = closedError{}
+	// ErrBufferOverflow is returned by Write when the data does not fit within the configured pending bytes limit.
+	ErrBufferOverflow = errors.New("nagle: buffer overflow")
+	// ErrBufferFull is the former name of ErrBufferOverflow.
+	//
+	// Deprecated: Use ErrBufferOverflow.
+	ErrBufferFull = ErrBufferOverflow
+	// ErrFlushFailed wraps the errors of flushes, so they can be told apart from those of
+	// the calls that triggered them. errors.Is and errors.As also match the cause.
+	ErrFlushFailed = errors.New("nagle: flush failed")
+	// ErrFlushTimeout is returned by CloseContext when the final flush does not complete
+	// in time, and by flushes cut short by the watchdog of WithWriteDeadline.
+	ErrFlushTimeout = errors.New("nagle: flush timeout")
+)
+
+type closedError struct{}
+
+func (closedError) Error() string {
+	return "nagle: wrapper closed"
+}
+
+func (closedError) Is(target error) bool {
+	return target == io.ErrClosedPipe || target == net.ErrClosed
+}
+
+// flushFailed wraps err, if any, in ErrFlushFailed.
+func flushFailed(err error) error {
+	if err == nil || errors.Is(err, ErrFlushFailed) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrFlushFailed, err)
+}
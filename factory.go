@@ -0,0 +1,149 @@
+package nagle
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// WrapperFactory creates wrappers that share one configuration and, optionally, one
+// FlushScheduler, for servers that wrap every connection or stream. It tracks the
+// wrappers it created until they are closed, so their settings can be tuned together
+// and their statistics read as a whole.
+type WrapperFactory struct {
+	mutex   sync.Mutex
+	opts    []Option
+	live    map[*NagleWriter]struct{}
+	retired Stats
+}
+
+// NewWrapperFactory creates a factory whose wrappers are configured by opts and, when
+// s is not nil, run their flush timers on s.
+func NewWrapperFactory(s *FlushScheduler, opts ...Option) *WrapperFactory {
+	f := &WrapperFactory{live: make(map[*NagleWriter]struct{})}
+	f.opts = append(f.opts, opts...)
+	if s != nil {
+		f.opts = append(f.opts, WithScheduler(s))
+	}
+	f.opts = append(f.opts, func(o *options) {
+		o.onClose = f.retire
+	})
+	return f
+}
+
+// New creates a wrapper like the package level New with the factory's configuration.
+func (f *WrapperFactory) New(rwc io.ReadWriteCloser) *NagleWrapper {
+	nw := New(rwc, f.options()...)
+	f.trackNew(rwc, nw.NagleWriter)
+	return nw
+}
+
+// NewConn creates a wrapper like the package level NewConn with the factory's configuration.
+func (f *WrapperFactory) NewConn(conn net.Conn) *NagleConn {
+	nc := NewConn(conn, f.options()...)
+	f.trackNew(conn, nc.NagleWriter)
+	return nc
+}
+
+// NewFromReadWriter creates a wrapper like the package level NewFromReadWriter with the
+// factory's configuration.
+func (f *WrapperFactory) NewFromReadWriter(rw io.ReadWriter) *NagleWrapper {
+	nw := NewFromReadWriter(rw, f.options()...)
+	f.trackNew(rw, nw.NagleWriter)
+	return nw
+}
+
+// NewWriter creates a wrapper like the package level NewWriter with the factory's configuration.
+func (f *WrapperFactory) NewWriter(w io.Writer) *NagleWriter {
+	nw := NewWriter(w, f.options()...)
+	f.trackNew(w, nw)
+	return nw
+}
+
+// SetBufferSize changes the buffer size of every open wrapper and of those created later.
+func (f *WrapperFactory) SetBufferSize(n int) {
+	for _, nw := range f.configure(WithBufferSize(n)) {
+		nw.SetBufferSize(n)
+	}
+}
+
+// SetFlushTimeout changes the flush timeout of every open wrapper and of those created later.
+func (f *WrapperFactory) SetFlushTimeout(d time.Duration) {
+	for _, nw := range f.configure(WithFlushTimeout(d)) {
+		nw.SetFlushTimeout(d)
+	}
+}
+
+// Len returns the number of wrappers created by the factory that are still open.
+func (f *WrapperFactory) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return len(f.live)
+}
+
+// Stats returns the counters of all the wrappers created by the factory added together,
+// including closed ones. MaxBuffered is the highest of them.
+func (f *WrapperFactory) Stats() Stats {
+	f.mutex.Lock()
+	total := f.retired
+	live := make([]*NagleWriter, 0, len(f.live))
+	for nw := range f.live {
+		live = append(live, nw)
+	}
+	f.mutex.Unlock()
+
+	for _, nw := range live {
+		total.add(nw.Stats())
+	}
+	return total
+}
+
+func (f *WrapperFactory) options() []Option {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Option(nil), f.opts...)
+}
+
+// configure adds opt to the configuration of future wrappers and returns the open ones.
+func (f *WrapperFactory) configure(opt Option) []*NagleWriter {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.opts = append(f.opts, opt)
+	live := make([]*NagleWriter, 0, len(f.live))
+	for nw := range f.live {
+		live = append(live, nw)
+	}
+	return live
+}
+
+// trackNew tracks nw unless it is the wrapper given to the factory, returned as is under WithNoNest.
+func (f *WrapperFactory) trackNew(wrapped any, nw *NagleWriter) {
+	if nagleWriterOf(wrapped) != nw {
+		f.track(nw)
+	}
+}
+
+func (f *WrapperFactory) track(nw *NagleWriter) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.live[nw] = struct{}{}
+}
+
+// retire moves the counters of a closed wrapper into the factory totals.
+func (f *WrapperFactory) retire(nw *NagleWriter) {
+	stats := nw.Stats()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, ok := f.live[nw]; !ok {
+		return
+	}
+	delete(f.live, nw)
+	f.retired.add(stats)
+}
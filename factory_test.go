@@ -0,0 +1,48 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWrapperFactory(t *testing.T) {
+	scheduler := NewFlushScheduler(time.Millisecond, 2)
+	defer scheduler.Stop()
+	factory := NewWrapperFactory(scheduler, WithBufferSize(4), WithFlushTimeout(time.Hour))
+
+	first := &MockReadWriteCloser{}
+	second := &MockReadWriteCloser{}
+	w1 := factory.New(first)
+	w2 := factory.New(second)
+	if factory.Len() != 2 {
+		t.Fatalf("expected 2 open wrappers, but got: %d", factory.Len())
+	}
+
+	w1.Write([]byte("0123"))
+	w2.Write([]byte("ab"))
+	stats := factory.Stats()
+	if stats.Writes != 2 || stats.BytesWritten != 6 || stats.BytesFlushed != 4 || stats.Buffered != 2 {
+		t.Fatalf("unexpected aggregate stats: %+v", stats)
+	}
+
+	// Tuning applies to open wrappers and to those created later
+	factory.SetBufferSize(2)
+	if w1.BufferSize() != 2 || factory.New(&MockReadWriteCloser{}).BufferSize() != 2 {
+		t.Fatal("expected the new buffer size to apply to all wrappers")
+	}
+	factory.SetFlushTimeout(time.Millisecond)
+	w2.Write([]byte("c"))
+	if second.String() != "abc" {
+		t.Fatalf("expected 'abc' to be flushed, but got: '%s'", second.String())
+	}
+
+	// Closed wrappers are forgotten but their counters are kept
+	w1.Close()
+	w2.Close()
+	if factory.Len() != 1 {
+		t.Fatalf("expected 1 open wrapper, but got: %d", factory.Len())
+	}
+	if stats := factory.Stats(); stats.BytesWritten != 7 || stats.BytesFlushed != 7 || stats.CloseFlushes != 0 {
+		t.Fatalf("unexpected aggregate stats after close: %+v", stats)
+	}
+}
@@ -0,0 +1,38 @@
+package nagle
+
+import "time"
+
+// WithUrgentFairness keeps a steady stream of WriteUrgent calls from holding back the
+// data buffered by Write, which otherwise waits for its own triggers however much
+// urgent data overtakes it. Once n urgent writes, or slice of time since the first of
+// them, have overtaken the buffered data, the next WriteUrgent flushes the buffer
+// before sending its own data. Zero disables either bound. Corked data is not flushed.
+func WithUrgentFairness(n int, slice time.Duration) Option {
+	return func(o *options) {
+		o.fairWrites = n
+		o.fairSlice = slice
+	}
+}
+
+// fairFlushDueLocked counts an urgent write overtaking the buffered data and reports
+// whether the buffer has to be flushed first under WithUrgentFairness.
+func (nw *NagleWriter) fairFlushDueLocked() bool {
+	if nw.fairWrites <= 0 && nw.fairSlice <= 0 {
+		return false
+	}
+	if nw.corked || nw.pendingLocked() == 0 {
+		nw.overtaken = 0
+		return false
+	}
+	now := nw.clock.Now()
+	if nw.overtaken == 0 {
+		nw.overtakenAt = now
+	}
+	nw.overtaken++
+	if (nw.fairWrites > 0 && nw.overtaken > nw.fairWrites) ||
+		(nw.fairSlice > 0 && now.Sub(nw.overtakenAt) >= nw.fairSlice) {
+		nw.overtaken = 0
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithUrgentFairness(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithUrgentFairness(2, 0))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("a"))
+	nagleWrapper.WriteUrgent([]byte("1"))
+	nagleWrapper.WriteUrgent([]byte("2"))
+	if mockRWC.String() != "12" {
+		t.Fatalf("expected '12', but got: '%s'", mockRWC.String())
+	}
+
+	// The third urgent write lets the buffered data go first
+	nagleWrapper.WriteUrgent([]byte("3"))
+	if mockRWC.String() != "12a3" {
+		t.Fatalf("expected '12a3', but got: '%s'", mockRWC.String())
+	}
+
+	// The count starts over with the next buffered data
+	nagleWrapper.Write([]byte("b"))
+	nagleWrapper.WriteUrgent([]byte("4"))
+	if mockRWC.String() != "12a34" {
+		t.Fatalf("expected '12a34', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_WithUrgentFairnessSlice(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithUrgentFairness(0, 20*time.Millisecond))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("a"))
+	nagleWrapper.WriteUrgent([]byte("1"))
+	nagleWrapper.WriteUrgent([]byte("2"))
+	time.Sleep(30 * time.Millisecond)
+	nagleWrapper.WriteUrgent([]byte("3"))
+	if mockRWC.String() != "12a3" {
+		t.Fatalf("expected '12a3', but got: '%s'", mockRWC.String())
+	}
+}
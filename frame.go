@@ -0,0 +1,136 @@
+package nagle
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// DefaultMaxFrameSize is the largest frame a FrameReader accepts unless changed with SetMaxFrameSize.
+const DefaultMaxFrameSize = 16 << 20
+
+// ErrFrameTooLarge is returned by FrameReader when a frame exceeds the maximum frame size.
+var ErrFrameTooLarge = errors.New("nagle: frame too large")
+
+// FramePrefix selects the length prefix written before each frame.
+type FramePrefix int
+
+const (
+	// FramePrefixNone disables framing.
+	FramePrefixNone FramePrefix = iota
+	// FramePrefixUvarint prefixes each frame with its length as an unsigned varint.
+	FramePrefixUvarint
+	// FramePrefixUint32 prefixes each frame with its length as a 4-byte big-endian integer.
+	FramePrefixUint32
+)
+
+// WithFraming emits every flushed batch as a frame: its length, encoded as selected
+// by prefix, followed by the batch. A FrameReader on the other end recovers the
+// batches. Framing is applied after any WithTransform and WithFlushMiddleware.
+func WithFraming(prefix FramePrefix) Option {
+	return func(o *options) {
+		o.framing = prefix
+	}
+}
+
+// appendFrame appends payload to dst as a frame with the given prefix.
+func appendFrame(dst []byte, prefix FramePrefix, payload []byte) []byte {
+	switch prefix {
+	case FramePrefixUvarint:
+		dst = binary.AppendUvarint(dst, uint64(len(payload)))
+	case FramePrefixUint32:
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	}
+	return append(dst, payload...)
+}
+
+// frameMiddleware returns a middleware that frames each batch.
+func frameMiddleware(prefix FramePrefix) Middleware {
+	return func(batch []byte) ([]byte, error) {
+		return appendFrame(nil, prefix, batch), nil
+	}
+}
+
+// FrameReader reads the frames produced by a wrapper configured with WithFraming.
+type FrameReader struct {
+	r            *bufio.Reader
+	prefix       FramePrefix
+	maxFrameSize int
+	checksum     bool
+	frame        []byte
+}
+
+// NewFrameReader creates a reader of frames with the given prefix from r.
+func NewFrameReader(r io.Reader, prefix FramePrefix) *FrameReader {
+	return &FrameReader{
+		r:            bufio.NewReader(r),
+		prefix:       prefix,
+		maxFrameSize: DefaultMaxFrameSize,
+	}
+}
+
+// SetMaxFrameSize sets the largest frame accepted, protecting against corrupt or hostile length prefixes.
+func (fr *FrameReader) SetMaxFrameSize(n int) {
+	fr.maxFrameSize = n
+}
+
+// ReadFrame returns the next frame. The returned slice is only valid until the next
+// call to ReadFrame or Read. It returns io.EOF at a clean end of stream and
+// io.ErrUnexpectedEOF if the stream ends inside a frame.
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	size, err := fr.readSize()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(fr.maxFrameSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	if uint64(cap(fr.frame)) < size {
+		fr.frame = make([]byte, size)
+	}
+	fr.frame = fr.frame[:size]
+	if _, err := io.ReadFull(fr.r, fr.frame); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if fr.checksum {
+		payload, err := verifyChecksum(fr.frame)
+		if err != nil {
+			return nil, err
+		}
+		fr.frame = payload
+	}
+	return fr.frame, nil
+}
+
+// Read reads the payload of the frames as a continuous stream, removing the framing.
+func (fr *FrameReader) Read(p []byte) (int, error) {
+	for len(fr.frame) == 0 {
+		if _, err := fr.ReadFrame(); err != nil {
+			fr.frame = fr.frame[:0]
+			return 0, err
+		}
+	}
+	n := copy(p, fr.frame)
+	fr.frame = fr.frame[n:]
+	return n, nil
+}
+
+func (fr *FrameReader) readSize() (uint64, error) {
+	switch fr.prefix {
+	case FramePrefixUvarint:
+		return binary.ReadUvarint(fr.r)
+	case FramePrefixUint32:
+		var header [4]byte
+		if _, err := io.ReadFull(fr.r, header[:]); err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(header[:])), nil
+	default:
+		return 0, errors.New("nagle: unknown frame prefix")
+	}
+}
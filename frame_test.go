@@ -0,0 +1,129 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestFraming_RoundTrip(t *testing.T) {
+	for _, prefix := range []FramePrefix{FramePrefixUvarint, FramePrefixUint32} {
+		var out bytes.Buffer
+		nagleWriter := NewWriter(&out, WithBufferSize(100), WithFlushTimeout(time.Hour), WithFraming(prefix))
+
+		nagleWriter.Write([]byte("01"))
+		nagleWriter.Write([]byte("23"))
+		nagleWriter.Flush()
+		nagleWriter.Write([]byte("456"))
+		nagleWriter.Close()
+
+		fr := NewFrameReader(&out, prefix)
+		for _, expected := range []string{"0123", "456"} {
+			frame, err := fr.ReadFrame()
+			if err != nil {
+				t.Fatalf("prefix %d: unexpected error: %v", prefix, err)
+			}
+			if string(frame) != expected {
+				t.Fatalf("prefix %d: expected frame '%s', got '%s'", prefix, expected, frame)
+			}
+		}
+		if _, err := fr.ReadFrame(); !errors.Is(err, io.EOF) {
+			t.Fatalf("prefix %d: expected EOF, but got: %v", prefix, err)
+		}
+	}
+}
+
+func TestFraming_DirectWrites(t *testing.T) {
+	for _, checksum := range []bool{false, true} {
+		var out bytes.Buffer
+		opts := []Option{WithBufferSize(100), WithFlushTimeout(time.Hour), WithFraming(FramePrefixUint32)}
+		if checksum {
+			opts = append(opts, WithChecksum())
+		}
+		nagleWriter := NewWriter(&out, opts...)
+
+		// Urgent and no-delay writes are framed like flushed batches
+		nagleWriter.Write([]byte("01"))
+		nagleWriter.WriteUrgent([]byte("urgent"))
+		nagleWriter.Flush()
+		nagleWriter.WriteNoDelay([]byte("nodelay"))
+		nagleWriter.Close()
+
+		fr := NewFrameReader(&out, FramePrefixUint32)
+		fr.SetChecksum(checksum)
+		for _, expected := range []string{"urgent", "01", "nodelay"} {
+			frame, err := fr.ReadFrame()
+			if err != nil {
+				t.Fatalf("checksum %v: unexpected error: %v", checksum, err)
+			}
+			if string(frame) != expected {
+				t.Fatalf("checksum %v: expected frame '%s', got '%s'", checksum, expected, frame)
+			}
+		}
+		if _, err := fr.ReadFrame(); !errors.Is(err, io.EOF) {
+			t.Fatalf("checksum %v: expected EOF, but got: %v", checksum, err)
+		}
+	}
+}
+
+func TestFrameReader_Read(t *testing.T) {
+	var stream []byte
+	stream = appendFrame(stream, FramePrefixUint32, []byte("hello "))
+	stream = appendFrame(stream, FramePrefixUint32, nil)
+	stream = appendFrame(stream, FramePrefixUint32, []byte("world"))
+
+	data, err := io.ReadAll(NewFrameReader(bytes.NewReader(stream), FramePrefixUint32))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Fatalf("expected 'hello world', got '%s'", data)
+	}
+}
+
+func TestFrameReader_Errors(t *testing.T) {
+	stream := appendFrame(nil, FramePrefixUvarint, []byte("0123456789"))
+
+	fr := NewFrameReader(bytes.NewReader(stream), FramePrefixUvarint)
+	fr.SetMaxFrameSize(4)
+	if _, err := fr.ReadFrame(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected ErrFrameTooLarge, but got: %v", err)
+	}
+
+	fr = NewFrameReader(bytes.NewReader(stream[:5]), FramePrefixUvarint)
+	if _, err := fr.ReadFrame(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, but got: %v", err)
+	}
+}
+
+func TestFraming_WithChecksum(t *testing.T) {
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithBufferSize(100), WithFlushTimeout(time.Hour), WithChecksum())
+	nagleWriter.Write([]byte("0123"))
+	nagleWriter.Flush()
+	nagleWriter.Write([]byte("456"))
+	nagleWriter.Close()
+
+	stream := out.Bytes()
+	fr := NewFrameReader(bytes.NewReader(stream), FramePrefixUint32)
+	fr.SetChecksum(true)
+	for _, expected := range []string{"0123", "456"} {
+		frame, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(frame) != expected {
+			t.Fatalf("expected frame '%s', got '%s'", expected, frame)
+		}
+	}
+
+	// Flip a bit in the first payload
+	stream[5] ^= 1
+	fr = NewFrameReader(bytes.NewReader(stream), FramePrefixUint32)
+	fr.SetChecksum(true)
+	if _, err := fr.ReadFrame(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, but got: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package nagle
+
+import "io"
+
+// WithFrameFunc has fn split every flushed batch into the frames of the caller's
+// protocol, such as records with their headers, which are then written with a single
+// writev when the underlying writer supports it. fn sees the batch as it leaves the
+// pipeline of WithTransform and WithFlushMiddleware, and writes made straight to the
+// underlying writer, such as those of WriteUrgent, are split the same way. The frames
+// may alias batch, but fn must not retain any of them after returning. If the frames
+// are only written in part, the rest is sent before the next batch, or by the next
+// Flush or Close, so the peer always receives whole frames.
+func WithFrameFunc(fn func(batch []byte) [][]byte) Option {
+	return func(o *options) {
+		o.frameFunc = fn
+	}
+}
+
+// frameWriter writes each batch to w as the frames fn splits it into.
+type frameWriter struct {
+	w       io.Writer
+	fn      func([]byte) [][]byte
+	pending []byte
+}
+
+func (f *frameWriter) Write(batch []byte) (int, error) {
+	if len(f.pending) > 0 {
+		n, err := f.w.Write(f.pending)
+		if err == nil && n < len(f.pending) {
+			err = io.ErrShortWrite
+		}
+		f.pending = f.pending[n:]
+		if err != nil {
+			return 0, err
+		}
+		f.pending = nil
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	frames := segmentBuffer{segments: f.fn(batch)}
+	for _, frame := range frames.segments {
+		frames.size += len(frame)
+	}
+	n, err := frames.WriteTo(f.w)
+	if err != nil && n == 0 {
+		return 0, err
+	}
+	if err != nil {
+		// The frames already started on the wire are finished before anything else.
+		// WriteTo leaves only the bytes it did not write in frames.
+		f.pending = frames.Bytes()
+	}
+	return len(batch), err
+}
+
+// flushFramesLocked sends the rest of the frames a previous flush only wrote in part,
+// for flushes that find nothing buffered.
+func (nw *NagleWriter) flushFramesLocked() error {
+	if nw.frames == nil {
+		return nil
+	}
+	if nw.async != nil {
+		// The writer goroutine may still be writing frames.
+		nw.async.pending.Wait()
+	}
+	if len(nw.frames.pending) == 0 {
+		return nil
+	}
+	_, err := nw.w.Write(nil)
+	nw.checkFatal(err)
+	if err == nil {
+		nw.markSentLocked()
+	}
+	return flushFailed(err)
+}
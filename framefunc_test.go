@@ -0,0 +1,101 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// recordFrames splits a batch into records of up to 4 bytes, each after a '#' header.
+func recordFrames(batch []byte) [][]byte {
+	var frames [][]byte
+	for len(batch) > 0 {
+		n := min(len(batch), 4)
+		frames = append(frames, []byte("#"), batch[:n])
+		batch = batch[n:]
+	}
+	return frames
+}
+
+func TestNagleWrapper_WithFrameFunc(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithFrameFunc(recordFrames))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("abc"))
+	nagleWrapper.Write([]byte("def"))
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The coalesced batch is split, not each write
+	want := []string{"#", "abcd", "#", "ef"}
+	if len(mockRWC.writes) != len(want) {
+		t.Fatalf("expected writes %q, but got: %q", want, mockRWC.writes)
+	}
+	for i := range want {
+		if mockRWC.writes[i] != want[i] {
+			t.Fatalf("expected writes %q, but got: %q", want, mockRWC.writes)
+		}
+	}
+}
+
+// failOnceReadWriteCloser fails the write that would take it past limit bytes, after
+// writing up to the limit, and accepts every write after it.
+type failOnceReadWriteCloser struct {
+	MockReadWriteCloser
+	limit  int
+	failed bool
+}
+
+func (m *failOnceReadWriteCloser) Write(p []byte) (int, error) {
+	if m.failed || len(m.String())+len(p) <= m.limit {
+		return m.MockReadWriteCloser.Write(p)
+	}
+	m.failed = true
+	n, _ := m.MockReadWriteCloser.Write(p[:m.limit-len(m.String())])
+	return n, errors.New("write failed")
+}
+
+func TestNagleWrapper_WithFrameFuncFailureThenClose(t *testing.T) {
+	mockRWC := &failOnceReadWriteCloser{limit: 3}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithFrameFunc(recordFrames))
+
+	// The write fails in the middle of the first record
+	nagleWrapper.Write([]byte("abcdef"))
+	if err := nagleWrapper.Flush(); err == nil {
+		t.Fatalf("expected the flush to fail")
+	}
+
+	// Close finishes the frames without sending the written bytes again
+	if err := nagleWrapper.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "#abcd#ef" {
+		t.Fatalf("expected '#abcd#ef', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_WithFrameFuncShortWrite(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 3}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithFrameFunc(recordFrames))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("abcdef"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected ErrShortWrite, but got: %v", err)
+	}
+
+	// The frames started are finished before the next batch is framed
+	nagleWrapper.Write([]byte("gh"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected ErrShortWrite, but got: %v", err)
+	}
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "#abcd#ef#gh" {
+		t.Fatalf("expected '#abcd#ef#gh', but got: '%s'", mockRWC.String())
+	}
+}
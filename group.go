@@ -0,0 +1,124 @@
+package nagle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Group flushes and closes a set of wrappers together, such as the connections a
+// payload is fanned out to, and adds up their statistics. The zero Group is empty and
+// ready to use.
+type Group struct {
+	mutex   sync.Mutex
+	members map[*NagleWriter]struct{}
+}
+
+// Add adds w, a wrapper created by this package, to the group.
+func (g *Group) Add(w io.Writer) {
+	nw := nagleWriterOf(w)
+	if nw == nil {
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.members == nil {
+		g.members = make(map[*NagleWriter]struct{})
+	}
+	g.members[nw] = struct{}{}
+}
+
+// Remove removes w from the group.
+func (g *Group) Remove(w io.Writer) {
+	if nw := nagleWriterOf(w); nw != nil {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+
+		delete(g.members, nw)
+	}
+}
+
+// Len returns the number of wrappers in the group.
+func (g *Group) Len() int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return len(g.members)
+}
+
+// Flush flushes every wrapper in the group concurrently and returns their errors
+// joined. Wrappers already closed are skipped. Those still flushing when ctx is done
+// report ErrFlushTimeout, and their flushes keep running in the background.
+func (g *Group) Flush(ctx context.Context) error {
+	return g.each(ctx, func(nw *NagleWriter) error {
+		if err := nw.Flush(); !errors.Is(err, ErrClosed) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Close closes every wrapper in the group concurrently with CloseContext, so ctx bounds
+// all the final flushes, and returns their errors joined. The wrappers stay in the
+// group, so their counters still count in Stats.
+func (g *Group) Close(ctx context.Context) error {
+	return g.each(ctx, func(nw *NagleWriter) error {
+		return nw.CloseContext(ctx)
+	})
+}
+
+// Stats returns the counters of the wrappers in the group added together. MaxBuffered
+// is the highest of them.
+func (g *Group) Stats() Stats {
+	var total Stats
+	for _, nw := range g.snapshot() {
+		total.add(nw.Stats())
+	}
+	return total
+}
+
+func (g *Group) snapshot() []*NagleWriter {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	members := make([]*NagleWriter, 0, len(g.members))
+	for nw := range g.members {
+		members = append(members, nw)
+	}
+	return members
+}
+
+// each runs fn for every wrapper concurrently and collects the errors, giving up on
+// those not done when ctx is.
+func (g *Group) each(ctx context.Context, fn func(*NagleWriter) error) error {
+	members := g.snapshot()
+	errs := make([]error, len(members))
+	done := make(chan int, len(members))
+	for i, nw := range members {
+		go func() {
+			errs[i] = fn(nw)
+			done <- i
+		}()
+	}
+
+	finished := make([]bool, len(members))
+	for range members {
+		select {
+		case i := <-done:
+			finished[i] = true
+		case <-ctx.Done():
+			var joined []error
+			for i, ok := range finished {
+				if ok {
+					joined = append(joined, errs[i])
+				} else {
+					joined = append(joined, ErrFlushTimeout)
+				}
+			}
+			return errors.Join(joined...)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package nagle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	var group Group
+	first := &MockReadWriteCloser{}
+	second := &MockReadWriteCloser{}
+	w1 := New(first, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	w2 := New(second, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	group.Add(w1)
+	group.Add(w2)
+
+	w1.Write([]byte("ab"))
+	w2.Write([]byte("cd"))
+	if err := group.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.String() != "ab" || second.String() != "cd" {
+		t.Fatalf("expected both wrappers flushed, but got: '%s' and '%s'", first.String(), second.String())
+	}
+
+	w1.Write([]byte("e"))
+	if err := group.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats := group.Stats(); stats.BytesFlushed != 5 || stats.ExplicitFlushes != 2 || stats.CloseFlushes != 1 {
+		t.Fatalf("unexpected group stats: %+v", stats)
+	}
+
+	// Closed wrappers are skipped by later flushes
+	if err := group.Flush(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	group.Remove(w1)
+	if group.Len() != 1 {
+		t.Fatalf("expected 1 wrapper, but got: %d", group.Len())
+	}
+}
+
+func TestGroup_FlushDeadline(t *testing.T) {
+	var group Group
+	blocking := NewBlockingReadWriteCloser()
+	defer close(blocking.release)
+	mockRWC := &MockReadWriteCloser{}
+	stuck := New(blocking, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	healthy := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	group.Add(stuck)
+	group.Add(healthy)
+
+	stuck.Write([]byte("ab"))
+	healthy.Write([]byte("cd"))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	// The stuck wrapper does not hold back the healthy one
+	if err := group.Flush(ctx); !errors.Is(err, ErrFlushTimeout) {
+		t.Fatalf("expected ErrFlushTimeout, but got: %v", err)
+	}
+	if mockRWC.String() != "cd" {
+		t.Fatalf("expected the healthy wrapper flushed, but got: '%s'", mockRWC.String())
+	}
+}
@@ -0,0 +1,43 @@
+package nagle
+
+import "errors"
+
+// writeCloser is implemented by streams that can shut down their write side alone,
+// such as *net.TCPConn, *net.UnixConn and *tls.Conn.
+type writeCloser interface {
+	CloseWrite() error
+}
+
+// CloseWrite flushes any buffered data and then shuts down the write side of the
+// underlying stream, sending a FIN on TCP, while reads keep working so the peer's
+// response can be drained. Later writes fail with ErrClosed; Close must still be
+// called to release the stream. It returns errors.ErrUnsupported when the underlying
+// stream has no CloseWrite method.
+func (nw *NagleWriter) CloseWrite() error {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed || nw.writeClosed {
+		return ErrClosed
+	}
+
+	wc, ok := nw.base.(writeCloser)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	nw.resumeLocked()
+	err := nw.takeAsyncErrLocked()
+	if _, flushErr := nw.flushLocked(FlushTriggerClose); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return err
+	}
+
+	nw.writeClosed = true
+	nw.disarmTimerLocked()
+	nw.stopKeepAliveLocked()
+	return wc.CloseWrite()
+}
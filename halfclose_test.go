@@ -0,0 +1,65 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNagleConn_CloseWrite(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	// The peer replies once it has read the whole request
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request, _ := io.ReadAll(conn)
+		conn.Write(append([]byte("got "), request...))
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nagleConn := NewConn(conn, WithFlushTimeout(time.Hour))
+	defer nagleConn.Close()
+
+	nagleConn.Write([]byte("request"))
+	if err := nagleConn.CloseWrite(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := nagleConn.Write([]byte("more")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+
+	response, err := io.ReadAll(nagleConn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(response) != "got request" {
+		t.Fatalf("expected 'got request', but got: '%s'", string(response))
+	}
+}
+
+func TestNagleWrapper_CloseWriteUnsupported(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("data"))
+	if err := nagleWrapper.CloseWrite(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, but got: %v", err)
+	}
+	if _, err := nagleWrapper.Write([]byte("more")); err != nil {
+		t.Fatalf("expected writes to keep working, but got: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package nagle
+
+import (
+	"math"
+	"time"
+)
+
+// sizeBounds are the upper bounds of the buckets of SizeHistogram but the last.
+var sizeBounds = [...]int{64, 256, 1024, 4096, 16384, 65536, 262144}
+
+// queueBounds are the upper bounds of the buckets of QueueHistogram but the last.
+var queueBounds = [...]time.Duration{
+	100 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond,
+	time.Second,
+}
+
+// SizeHistogram counts flushes by the number of bytes they wrote. Bucket i counts those
+// above the bound of bucket i-1 and up to its own, from 64 bytes for the first bucket to
+// 256KiB, growing by a factor of four; the last bucket counts the larger ones.
+type SizeHistogram [len(sizeBounds) + 1]int64
+
+// Bound returns the upper bound of bucket i in bytes, math.MaxInt for the last one.
+func (h SizeHistogram) Bound(i int) int {
+	if i < len(sizeBounds) {
+		return sizeBounds[i]
+	}
+	return math.MaxInt
+}
+
+func (h *SizeHistogram) observe(n int64) {
+	i := 0
+	for i < len(sizeBounds) && n > int64(sizeBounds[i]) {
+		i++
+	}
+	h[i]++
+}
+
+func (h *SizeHistogram) add(o SizeHistogram) {
+	for i := range h {
+		h[i] += o[i]
+	}
+}
+
+// QueueHistogram counts flushes by how long the first byte they wrote had waited in the
+// buffer. Bucket i counts those above the bound of bucket i-1 and up to its own, from
+// 100µs for the first bucket to 1s, in steps of 1 and 5; the last bucket counts the longer ones.
+type QueueHistogram [len(queueBounds) + 1]int64
+
+// Bound returns the upper bound of bucket i, math.MaxInt64 for the last one.
+func (h QueueHistogram) Bound(i int) time.Duration {
+	if i < len(queueBounds) {
+		return queueBounds[i]
+	}
+	return math.MaxInt64
+}
+
+func (h *QueueHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(queueBounds) && d > queueBounds[i] {
+		i++
+	}
+	h[i]++
+}
+
+func (h *QueueHistogram) add(o QueueHistogram) {
+	for i := range h {
+		h[i] += o[i]
+	}
+}
+
+// queuedLocked returns how long the oldest buffered byte has waited in the buffer.
+func (nw *NagleWriter) queuedLocked() time.Duration {
+	if nw.oldest.IsZero() {
+		return 0
+	}
+	return nw.clock.Now().Sub(nw.oldest)
+}
@@ -0,0 +1,38 @@
+package nagle_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestStats_Histograms(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(1000), nagle.WithFlushTimeout(time.Hour), nagle.WithClock(clock))
+	defer nagleWriter.Close()
+
+	// 100 bytes whose first byte waits 2ms
+	nagleWriter.Write(make([]byte, 50))
+	clock.Advance(2 * time.Millisecond)
+	nagleWriter.Write(make([]byte, 50))
+	nagleWriter.Flush()
+
+	// A single byte flushed at once
+	nagleWriter.Write([]byte("a"))
+	nagleWriter.Flush()
+
+	stats := nagleWriter.Stats()
+	if stats.FlushSizes[0] != 1 || stats.FlushSizes[1] != 1 {
+		t.Fatalf("expected one flush of up to 64 bytes and one of up to 256, but got: %v", stats.FlushSizes)
+	}
+	if stats.QueueTimes[0] != 1 || stats.QueueTimes[3] != 1 {
+		t.Fatalf("expected one flush queued up to 100µs and one up to 5ms, but got: %v", stats.QueueTimes)
+	}
+	if stats.FlushSizes.Bound(1) != 256 || stats.QueueTimes.Bound(3) != 5*time.Millisecond {
+		t.Fatalf("unexpected bounds %d and %v", stats.FlushSizes.Bound(1), stats.QueueTimes.Bound(3))
+	}
+}
@@ -0,0 +1,134 @@
+package nagle
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WithFlushHook registers fn to be called after every flush with the bytes it wrote
+// to the underlying writer, after any transform, and what triggered it. fn runs once
+// the wrapper lock is released, so it may call back into the wrapper, and it owns
+// batch. Hooks for flushes made by different goroutines may run concurrently.
+func WithFlushHook(fn func(batch []byte, trigger FlushTrigger)) Option {
+	return func(o *options) {
+		o.flushHook = fn
+	}
+}
+
+// FlushInfo describes a completed flush to the observers added with WithFlushObserver.
+type FlushInfo struct {
+	// Trigger is what caused the flush.
+	Trigger FlushTrigger
+	// Bytes is the number of bytes written to the underlying writer.
+	Bytes int
+	// Start is when the underlying write began.
+	Start time.Time
+	// Duration is the time spent in the underlying write.
+	Duration time.Duration
+	// Queued is how long the oldest byte of the flush had waited in the buffer when
+	// the underlying write began.
+	Queued time.Duration
+	// Contexts are those given to the WriteContext calls made since the previous flush,
+	// for tracing tools to link the flush to the writes it carried.
+	Contexts []context.Context
+	// Err is the error returned by the underlying write, if any.
+	Err error
+}
+
+// WithFlushObserver adds fn to the functions told about every flush. Unlike
+// WithFlushHook it does not copy the flushed bytes, which makes it the cheaper
+// choice for metrics, and it can be given several times. Observers run like hooks,
+// once the wrapper lock is released.
+func WithFlushObserver(fn func(FlushInfo)) Option {
+	return func(o *options) {
+		o.flushObservers = append(o.flushObservers, fn)
+	}
+}
+
+// flushEvent is a flush waiting to be reported to the hook and observers.
+type flushEvent struct {
+	batch []byte
+	info  FlushInfo
+}
+
+// observingFlushesLocked reports whether flushes must be recorded for a hook or observer.
+func (nw *NagleWriter) observingFlushesLocked() bool {
+	return nw.flushHook != nil || len(nw.flushObservers) > 0 || nw.logger != nil
+}
+
+// flushStart is what observers are told about a flush that is not known once it is done.
+type flushStart struct {
+	at       time.Time
+	oldest   time.Time
+	contexts []context.Context
+}
+
+// flushStartLocked records the start of a flush when observers need it, taking the
+// contexts of the writes it carries.
+func (nw *NagleWriter) flushStartLocked() flushStart {
+	if len(nw.flushObservers) == 0 {
+		return flushStart{}
+	}
+	start := flushStart{at: nw.clock.Now(), oldest: nw.oldest, contexts: nw.contexts}
+	nw.contexts = nil
+	return start
+}
+
+// info fills in the fields of a FlushInfo known when the flush started.
+func (s flushStart) info(info *FlushInfo, now time.Time) {
+	if s.at.IsZero() {
+		return
+	}
+	info.Start = s.at
+	info.Duration = now.Sub(s.at)
+	if !s.oldest.IsZero() {
+		info.Queued = s.at.Sub(s.oldest)
+	}
+	info.Contexts = s.contexts
+}
+
+// queueFlushEventLocked records a flush that wrote n bytes of batch to be reported
+// on unlock. batch may alias the buffer, so it is copied.
+func (nw *NagleWriter) queueFlushEventLocked(trigger FlushTrigger, batch []byte, n int, start flushStart, err error) {
+	if (n == 0 && err == nil) || !nw.observingFlushesLocked() {
+		return
+	}
+	event := flushEvent{info: FlushInfo{Trigger: trigger, Bytes: n, Err: err}}
+	if nw.flushHook != nil && n > 0 {
+		event.batch = append([]byte(nil), batch[:n]...)
+	}
+	start.info(&event.info, nw.clock.Now())
+	nw.hookEvents = append(nw.hookEvents, event)
+}
+
+// unlock releases the wrapper lock, giving flushed bytes back to the memory limiter,
+// and then reports the flushes made while it was held.
+func (nw *NagleWriter) unlock() {
+	events := nw.hookEvents
+	nw.hookEvents = nil
+	if nw.memory != nil {
+		nw.syncMemoryLocked()
+	}
+	if nw.journal != nil {
+		nw.syncJournalLocked()
+	}
+	nw.mutex.Unlock()
+
+	for _, event := range events {
+		nw.reportFlush(event)
+	}
+}
+
+// reportFlush passes event to the hook and observers. It must not be called with the lock held.
+func (nw *NagleWriter) reportFlush(event flushEvent) {
+	if nw.flushHook != nil && event.batch != nil {
+		nw.flushHook(event.batch, event.info.Trigger)
+	}
+	for _, observe := range nw.flushObservers {
+		observe(event.info)
+	}
+	if event.info.Err != nil {
+		nw.log(slog.LevelWarn, "nagle: flush failed", "trigger", event.info.Trigger.String(), "bytes", event.info.Bytes, "error", event.info.Err)
+	}
+}
@@ -0,0 +1,66 @@
+package nagle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithFlushHook(t *testing.T) {
+	type flush struct {
+		batch   string
+		trigger FlushTrigger
+	}
+	var flushes []flush
+
+	mockRWC := &MockReadWriteCloser{}
+	var nagleWrapper *NagleWrapper
+	nagleWrapper = New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour), WithFlushHook(func(batch []byte, trigger FlushTrigger) {
+		// The hook runs outside the lock, so calling back into the wrapper is safe
+		nagleWrapper.Stats()
+		flushes = append(flushes, flush{string(batch), trigger})
+	}))
+
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Write([]byte("45"))
+	nagleWrapper.Flush()
+	nagleWrapper.Write([]byte("6"))
+	nagleWrapper.Close()
+
+	expected := []flush{
+		{"0123", FlushTriggerSize},
+		{"45", FlushTriggerExplicit},
+		{"6", FlushTriggerClose},
+	}
+	if len(flushes) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, flushes)
+	}
+	for i := range expected {
+		if flushes[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, flushes)
+		}
+	}
+}
+
+func TestNagleWrapper_WithFlushObserver(t *testing.T) {
+	writeErr := errors.New("write failed")
+	var infos []FlushInfo
+	observe := func(info FlushInfo) { infos = append(infos, info) }
+
+	mockRWC := &FailingReadWriteCloser{err: writeErr}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithFlushObserver(observe), WithFlushObserver(observe))
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Flush()
+	nagleWrapper.Close()
+
+	// Both observers see the failed explicit flush and the failed close flush
+	if len(infos) != 4 {
+		t.Fatalf("expected 4 observations, got %v", infos)
+	}
+	if infos[0].Trigger != FlushTriggerExplicit || !errors.Is(infos[0].Err, writeErr) || infos[0].Bytes != 0 {
+		t.Fatalf("unexpected first observation: %+v", infos[0])
+	}
+	if infos[2].Trigger != FlushTriggerClose {
+		t.Fatalf("unexpected third observation: %+v", infos[2])
+	}
+}
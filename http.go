@@ -0,0 +1,113 @@
+package nagle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrHTTPStatus is returned by HTTPWriter when the endpoint answers with a status other
+// than 2xx. The error wraps it along with the status.
+var ErrHTTPStatus = errors.New("nagle: unexpected HTTP status")
+
+// HTTPWriter is an io.Writer that sends every write as the body of a POST request, so a
+// NagleWriter over it ships each flushed batch to URL in a request of its own:
+//
+//	nw := nagle.NewWriter(&nagle.HTTPWriter{URL: url}, nagle.WithMessageMode())
+//
+// WithMessageMode keeps the batches made of whole writes, such as the lines of an
+// NDJSONWriter. A batch that could not be delivered stays buffered for the next flush,
+// while one the endpoint rejected, with a status other than 429 or 5xx, is dropped, so
+// it cannot hold back the data behind it; both are reported as flush errors.
+type HTTPWriter struct {
+	// Client sends the requests. Nil means http.DefaultClient.
+	Client *http.Client
+	// URL is the endpoint the batches are posted to.
+	URL string
+	// ContentType is the Content-Type of the requests, application/octet-stream when empty.
+	ContentType string
+	// Header holds further headers added to every request.
+	Header http.Header
+	// Retry sets how requests that fail to reach the endpoint, or that are answered with
+	// a 429 or 5xx status, are retried before the write fails.
+	Retry RetryPolicy
+	// Clock times the waits between retries. Nil means the system clock.
+	Clock Clock
+	// Context bounds the requests and the waits between them. Nil means context.Background().
+	Context context.Context
+}
+
+// Write posts p to the endpoint, retrying as set by Retry. It returns len(p) along with
+// the error when the endpoint rejected p for good, and 0 when p may still be delivered.
+func (hw *HTTPWriter) Write(p []byte) (int, error) {
+	ctx := hw.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	clock := hw.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	backoff := hw.Retry.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := hw.post(ctx, p)
+		if err == nil {
+			return len(p), nil
+		}
+		if errors.Is(err, ErrHTTPStatus) && !retry {
+			return len(p), err
+		}
+		if !retry || attempt >= hw.Retry.Attempts {
+			return 0, err
+		}
+		if backoff > 0 {
+			timer := clock.NewTimer(backoff)
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return 0, ctx.Err()
+			}
+		}
+		backoff *= 2
+		if hw.Retry.MaxBackoff > 0 {
+			backoff = min(backoff, hw.Retry.MaxBackoff)
+		}
+	}
+}
+
+// post makes one request, and reports whether it may succeed if made again.
+func (hw *HTTPWriter) post(ctx context.Context, p []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hw.URL, bytes.NewReader(p))
+	if err != nil {
+		return false, err
+	}
+	for key, values := range hw.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	contentType := hw.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	client := hw.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	// Reading the body to the end lets the connection be reused.
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%w: %s", ErrHTTPStatus, resp.Status)
+}
@@ -0,0 +1,127 @@
+package nagle_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestHTTPWriter(t *testing.T) {
+	var mutex sync.Mutex
+	var bodies []string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	hw := &nagle.HTTPWriter{URL: server.URL, ContentType: "application/x-ndjson", Retry: nagle.RetryPolicy{Attempts: 1}}
+	nagleWriter := nagle.NewWriter(hw, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour), nagle.WithMessageMode())
+	nagleWriter.Write([]byte("a\n"))
+	nagleWriter.Write([]byte("b\n"))
+	if err := nagleWriter.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nagleWriter.Write([]byte("c\n"))
+	nagleWriter.Close()
+
+	// The first request is retried, and each flush is a request of its own
+	mutex.Lock()
+	defer mutex.Unlock()
+	if requests != 3 || len(bodies) != 2 || bodies[0] != "a\nb\n" || bodies[1] != "c\n" {
+		t.Fatalf("expected 3 requests with 2 batches, but got: %d and %q", requests, bodies)
+	}
+}
+
+func TestHTTPWriter_Rejected(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	// Client errors are not retried, and the rejected batch is dropped
+	hw := &nagle.HTTPWriter{URL: server.URL, Retry: nagle.RetryPolicy{Attempts: 3}}
+	nagleWriter := nagle.NewWriter(hw, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour))
+	defer nagleWriter.Close()
+	nagleWriter.Write([]byte("a"))
+	if err := nagleWriter.Flush(); !errors.Is(err, nagle.ErrHTTPStatus) {
+		t.Fatalf("expected ErrHTTPStatus, but got: %v", err)
+	}
+	if nagleWriter.Buffered() != 0 || requests.Load() != 1 {
+		t.Fatalf("expected the batch to be dropped after 1 request, but got: %d buffered, %d requests", nagleWriter.Buffered(), requests.Load())
+	}
+
+	// The data behind it goes out as usual
+	nagleWriter.Write([]byte("b"))
+	if err := nagleWriter.Flush(); err != nil || requests.Load() != 2 {
+		t.Fatalf("expected a second request, but got: %d, %v", requests.Load(), err)
+	}
+}
+
+func TestHTTPWriter_Backoff(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	hw := &nagle.HTTPWriter{URL: server.URL, Clock: clock, Context: ctx, Retry: nagle.RetryPolicy{Attempts: 5, Backoff: time.Second}}
+	result := make(chan error, 1)
+	var n int
+	go func() {
+		var err error
+		n, err = hw.Write([]byte("a"))
+		result <- err
+	}()
+
+	// The retry waits on the clock, and the wait ends with the context
+	waitTimers(t, clock)
+	clock.Advance(time.Second)
+	for requests.Load() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) || n != 0 {
+		t.Fatalf("expected context.Canceled with nothing written, but got: %d, %v", n, err)
+	}
+}
+
+// waitTimers waits until a timer is armed on clock.
+func waitTimers(t *testing.T, clock *naglefake.Clock) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for clock.Timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a timer to be armed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
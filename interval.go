@@ -0,0 +1,46 @@
+package nagle
+
+import "time"
+
+// WithMinFlushInterval lets at most one flush reach the underlying writer per interval
+// d, for destinations that charge per write call. Size, timeout and other automatic
+// triggers that fire sooner are postponed and the data keeps accumulating until the
+// interval has elapsed. Flush, Close, WriteNoDelay and WriteUrgent are not held back,
+// and a Write blocked by WithMaxBufferSize waits for the interval before making room.
+func WithMinFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.minInterval = d
+	}
+}
+
+// flushIntervalWaitLocked returns how long a flush caused by trigger must wait to respect the minimum interval.
+func (nw *NagleWriter) flushIntervalWaitLocked(trigger FlushTrigger) time.Duration {
+	if nw.minInterval <= 0 || nw.lastFlush.IsZero() || trigger == FlushTriggerExplicit || trigger == FlushTriggerClose {
+		return 0
+	}
+	return nw.lastFlush.Add(nw.minInterval).Sub(nw.clock.Now())
+}
+
+// postponeFlushLocked reports whether a flush caused by trigger is too soon, and if so
+// makes sure the timer fires once the interval has elapsed.
+func (nw *NagleWriter) postponeFlushLocked(trigger FlushTrigger) bool {
+	if nw.paused {
+		// Resume flushes whatever is left.
+		return true
+	}
+	wait := nw.flushIntervalWaitLocked(trigger)
+	if wait <= 0 {
+		return false
+	}
+	if due := nw.clock.Now().Add(wait); nw.flushAt.Before(due) {
+		nw.armTimerLocked(wait)
+	}
+	return true
+}
+
+// waitFlushIntervalLocked sleeps, with the lock held, until a flush caused by trigger is allowed.
+func (nw *NagleWriter) waitFlushIntervalLocked(trigger FlushTrigger) {
+	if wait := nw.flushIntervalWaitLocked(trigger); wait > 0 {
+		<-nw.clock.NewTimer(wait).C()
+	}
+}
@@ -0,0 +1,40 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithMinFlushInterval(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour), WithMinFlushInterval(50*time.Millisecond))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Write([]byte("4567"))
+	nagleWrapper.Write([]byte("89"))
+
+	// Only the first size-triggered flush is allowed within the interval
+	nagleWrapper.mutex.Lock()
+	writes := append([]string(nil), mockRWC.writes...)
+	nagleWrapper.mutex.Unlock()
+	if len(writes) != 1 || writes[0] != "0123" {
+		t.Fatalf("expected a single write of '0123', but got: %q", writes)
+	}
+
+	// The postponed data goes out once the interval elapses
+	time.Sleep(100 * time.Millisecond)
+	nagleWrapper.mutex.Lock()
+	writes = append([]string(nil), mockRWC.writes...)
+	nagleWrapper.mutex.Unlock()
+	if len(writes) != 2 || writes[1] != "456789" {
+		t.Fatalf("expected a second write of '456789', but got: %q", writes)
+	}
+
+	// Explicit flushes are not held back
+	nagleWrapper.Write([]byte("a"))
+	nagleWrapper.Flush()
+	if len(mockRWC.writes) != 3 {
+		t.Fatalf("expected the explicit flush to be written, but got: %q", mockRWC.writes)
+	}
+}
@@ -0,0 +1,26 @@
+package nagle
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// WithFlushJitter spreads the flush timeout by a random amount of up to fraction of it
+// either way, 0.1 for ±10%, drawn for every write. Wrappers created together, such as
+// the connections of a server after a restart, then drift apart instead of flushing in
+// lockstep and causing synchronized spikes of network and CPU load. fraction is capped
+// at 1.
+func WithFlushJitter(fraction float64) Option {
+	return func(o *options) {
+		o.jitter = min(max(fraction, 0), 1)
+	}
+}
+
+// jitterLocked returns d moved by the random amount of WithFlushJitter.
+func (nw *NagleWriter) jitterLocked(d time.Duration) time.Duration {
+	if nw.jitter == 0 || d <= 0 {
+		return d
+	}
+	spread := nw.jitter * float64(d)
+	return max(d+time.Duration((rand.Float64()*2-1)*spread), 0)
+}
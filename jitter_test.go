@@ -0,0 +1,25 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithFlushJitter(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithFlushTimeout(100*time.Millisecond), WithFlushJitter(0.1))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.mutex.Lock()
+	defer nagleWrapper.mutex.Unlock()
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		delay := nagleWrapper.flushDelayLocked()
+		if delay < 90*time.Millisecond || delay > 110*time.Millisecond {
+			t.Fatalf("expected a delay within 10%% of 100ms, but got: %v", delay)
+		}
+		seen[delay] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("expected the delay to vary")
+	}
+}
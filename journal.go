@@ -0,0 +1,154 @@
+package nagle
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// JournalFile is the name of the file WithJournal keeps in its directory.
+const JournalFile = "nagle.journal"
+
+// WithJournal keeps a copy of the buffered data in a journal file in dir, created if
+// needed, so the tail of the stream survives the process crashing before it is flushed.
+// Every write is appended to the journal, which is truncated once a flush empties the
+// buffer. The data a previous process left in it is put back in the buffer when the
+// wrapper is created, ahead of anything written; Recover sends it right away, and it
+// otherwise goes out with the first flush. Close removes the journal once everything
+// has been flushed, and leaves in it whatever could not be. The journal is not synced to
+// storage, so it does not survive the machine crashing, and a flush cut short by the
+// crash may be sent again in part. Each wrapper needs a dir of its own. Batches handed to
+// the writer goroutine of WithAsyncFlush count as flushed. A journal error is returned by
+// the next call, like those of timeout flushes, and stops the journaling.
+func WithJournal(dir string) Option {
+	return func(o *options) {
+		o.journalDir = dir
+	}
+}
+
+// journal is the file of WithJournal. It holds size bytes, the buffered data possibly
+// preceded by some that has already been flushed.
+type journal struct {
+	file *os.File
+	size int
+}
+
+// openJournalLocked opens the journal in dir and buffers the data found in it.
+func (nw *NagleWriter) openJournalLocked(dir string) {
+	data, j, err := openJournal(dir)
+	if err != nil {
+		nw.journalFailedLocked(err)
+		return
+	}
+	if len(data) > 0 {
+		// Buffered before the journal is set, since it already holds the data.
+		nw.appendLocked(data)
+		nw.recovered = len(data)
+	}
+	nw.journal = j
+}
+
+func openJournal(dir string) ([]byte, *journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, JournalFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return data, &journal{file: file, size: len(data)}, nil
+}
+
+// Recover flushes the data found in the journal of WithJournal when the wrapper was
+// created, which a previous process buffered but never sent, and returns its length.
+func (nw *NagleWriter) Recover() (int, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed {
+		return 0, ErrClosed
+	}
+
+	n := nw.recovered
+	nw.recovered = 0
+	if n == 0 {
+		return 0, nil
+	}
+	_, err := nw.flushLocked(FlushTriggerExplicit)
+	return n, err
+}
+
+// appendJournalLocked appends data, which has just been buffered, to the journal.
+func (nw *NagleWriter) appendJournalLocked(data []byte) {
+	n, err := nw.journal.file.Write(data)
+	nw.journal.size += n
+	if err != nil {
+		nw.journalFailedLocked(err)
+	}
+}
+
+// syncJournalLocked brings the journal in line with the buffer, dropping the data that
+// has been flushed or discarded. A batch whose write is in progress, or whose transformed
+// output is only partly written, stays in the journal until it is done.
+func (nw *NagleWriter) syncJournalLocked() {
+	if nw.closed || nw.leaderDone != nil || len(nw.encoded) > 0 {
+		return
+	}
+	if nw.journal.size == nw.buffer.Len() {
+		return
+	}
+	if err := nw.journal.rewrite(nw.buffer.Bytes()); err != nil {
+		nw.journalFailedLocked(err)
+	}
+}
+
+// rewrite replaces the contents of the journal with data.
+func (j *journal) rewrite(data []byte) error {
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	n, err := j.file.Write(data)
+	j.size = n
+	return err
+}
+
+// closeJournalLocked leaves the unflushed data in the journal for the next process, or
+// removes it when there is none.
+func (nw *NagleWriter) closeJournalLocked() error {
+	j := nw.journal
+	nw.journal = nil
+	name := j.file.Name()
+	if nw.pendingLocked() == 0 {
+		j.file.Close()
+		return os.Remove(name)
+	}
+	var err error
+	if len(nw.encoded) == 0 && j.size != nw.buffer.Len() {
+		err = j.rewrite(nw.buffer.Bytes())
+	}
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// journalFailedLocked reports a journal error like a timeout flush error and stops
+// journaling. The journal is removed, since it may no longer match the buffer.
+func (nw *NagleWriter) journalFailedLocked(err error) {
+	nw.log(slog.LevelWarn, "nagle: journal failed", "error", err)
+	if nw.asyncErr == nil {
+		nw.asyncErr = err
+	}
+	if j := nw.journal; j != nil {
+		nw.journal = nil
+		j.file.Close()
+		os.Remove(j.file.Name())
+	}
+}
@@ -0,0 +1,62 @@
+package nagle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithJournal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, JournalFile)
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithJournal(dir))
+
+	nagleWrapper.Write([]byte("012"))
+	nagleWrapper.Write([]byte("345"))
+	if data, err := os.ReadFile(path); err != nil || string(data) != "012345" {
+		t.Fatalf("expected the journal to hold '012345', but got: '%s', %v", data, err)
+	}
+
+	// A flush that empties the buffer truncates the journal
+	nagleWrapper.Flush()
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Fatalf("expected an empty journal, but got: '%s', %v", data, err)
+	}
+
+	nagleWrapper.Write([]byte("6"))
+	nagleWrapper.Close()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected Close to remove the journal, but got: %v", err)
+	}
+	if mockRWC.String() != "0123456" {
+		t.Fatalf("expected '0123456', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_JournalRecover(t *testing.T) {
+	dir := t.TempDir()
+	writeErr := errors.New("write failed")
+	nagleWrapper := New(&FailingReadWriteCloser{err: writeErr}, WithBufferSize(100), WithFlushTimeout(time.Hour), WithJournal(dir))
+	nagleWrapper.Write([]byte("unsent"))
+	if err := nagleWrapper.Close(); !errors.Is(err, writeErr) {
+		t.Fatalf("expected %v, but got: %v", writeErr, err)
+	}
+
+	// The next wrapper sends what the previous one could not
+	mockRWC := &MockReadWriteCloser{}
+	recovered := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithJournal(dir))
+	defer recovered.Close()
+	n, err := recovered.Recover()
+	if err != nil || n != 6 {
+		t.Fatalf("expected to recover 6 bytes, but got: %d, %v", n, err)
+	}
+	if mockRWC.String() != "unsent" {
+		t.Fatalf("expected 'unsent', but got: '%s'", mockRWC.String())
+	}
+	if n, _ := recovered.Recover(); n != 0 {
+		t.Fatalf("expected nothing left to recover, but got: %d", n)
+	}
+}
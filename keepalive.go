@@ -0,0 +1,73 @@
+package nagle
+
+import "time"
+
+// WithKeepAlive writes payload whenever nothing has been sent for interval, so idle
+// connections stay alive without every consumer running a ticker of its own. The
+// payload goes through the buffer and the flush path like written data, after
+// anything already buffered, and any data sent pushes the next keepalive back.
+// No keepalive is sent while corked or paused. Errors are reported like those of timeout flushes.
+func WithKeepAlive(interval time.Duration, payload []byte) Option {
+	return func(o *options) {
+		o.keepAlive = interval
+		o.keepAliveData = append([]byte(nil), payload...)
+	}
+}
+
+// startKeepAlive arms the keepalive timer of a new writer.
+func (nw *NagleWriter) startKeepAlive() {
+	nw.lastSent = nw.clock.Now()
+	nw.keepAliveTimer = nw.scheduler.AfterFunc(nw.keepAlive, nw.handleKeepAlive)
+}
+
+// markSentLocked records that data reached the underlying writer, postponing the next keepalive.
+func (nw *NagleWriter) markSentLocked() {
+	if nw.keepAlive > 0 {
+		nw.lastSent = nw.clock.Now()
+	}
+}
+
+// stopKeepAliveLocked cancels the keepalive timer once no more data can be sent.
+func (nw *NagleWriter) stopKeepAliveLocked() {
+	if nw.keepAliveTimer != nil {
+		nw.keepAliveTimer.Stop()
+	}
+}
+
+// handleKeepAlive runs when the keepalive timer fires.
+func (nw *NagleWriter) handleKeepAlive() {
+	nw.mutex.Lock()
+
+	if nw.closed || nw.writeClosed {
+		nw.unlock()
+		return
+	}
+
+	// Data sent since the timer was armed pushes the keepalive back.
+	if wait := nw.lastSent.Add(nw.keepAlive).Sub(nw.clock.Now()); wait > 0 {
+		nw.keepAliveTimer.Reset(wait)
+		nw.unlock()
+		return
+	}
+
+	var err error
+	if !nw.corked && !nw.paused && nw.leaderDone == nil {
+		payload, _ := nw.frameMessageLocked(nw.keepAliveData)
+		nw.appendLocked(payload)
+		nw.pendingWrites++
+		nw.stats.KeepAlives++
+		err = nw.autoFlushLocked(FlushTriggerKeepAlive)
+		if err != nil {
+			nw.asyncErr = err
+		}
+	}
+	// Sent or not, wait a full interval before trying again.
+	nw.lastSent = nw.clock.Now()
+	nw.keepAliveTimer.Reset(nw.keepAlive)
+	onError := nw.onError
+	nw.unlock()
+
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
@@ -0,0 +1,89 @@
+package nagle_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestNagleWriter_WithKeepAlive(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(10), nagle.WithFlushTimeout(10*time.Millisecond),
+		nagle.WithClock(clock), nagle.WithKeepAlive(time.Second, []byte("!")))
+	defer nagleWriter.Close()
+
+	// An idle writer sends the payload once per interval
+	clock.Advance(time.Second)
+	if out.String() != "!" {
+		t.Fatalf("expected a keepalive, but got: '%s'", out.String())
+	}
+
+	// Data sent in between pushes the next keepalive back
+	clock.Advance(500 * time.Millisecond)
+	nagleWriter.Write([]byte("ab"))
+	clock.Advance(10 * time.Millisecond)
+	clock.Advance(490 * time.Millisecond)
+	if out.String() != "!ab" {
+		t.Fatalf("expected no keepalive right after data, but got: '%s'", out.String())
+	}
+	clock.Advance(510 * time.Millisecond)
+	if out.String() != "!ab!" {
+		t.Fatalf("expected a keepalive after a quiet interval, but got: '%s'", out.String())
+	}
+
+	// Corked, the keepalive is skipped
+	nagleWriter.Cork()
+	clock.Advance(time.Second)
+	nagleWriter.Uncork()
+	if out.String() != "!ab!" {
+		t.Fatalf("expected no keepalive while corked, but got: '%s'", out.String())
+	}
+
+	if stats := nagleWriter.Stats(); stats.KeepAlives != 2 || stats.KeepAliveFlushes != 2 || stats.Writes != 1 {
+		t.Fatalf("expected 2 keepalives, but got: %+v", stats)
+	}
+}
+
+func TestNagleWriter_WithKeepAlivePaused(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithClock(clock), nagle.WithKeepAlive(time.Second, []byte("!")))
+	defer nagleWriter.Close()
+
+	// Keepalives are not buffered while paused, so none go out on Resume
+	nagleWriter.Pause()
+	for range 5 {
+		clock.Advance(time.Second)
+	}
+	if nagleWriter.Buffered() != 0 {
+		t.Fatalf("expected nothing buffered while paused, but got: %d", nagleWriter.Buffered())
+	}
+	nagleWriter.Resume()
+	if out.Len() != 0 || nagleWriter.Stats().KeepAlives != 0 {
+		t.Fatalf("expected no keepalive while paused, but got: '%s'", out.String())
+	}
+
+	clock.Advance(time.Second)
+	if out.String() != "!" {
+		t.Fatalf("expected a keepalive after Resume, but got: '%s'", out.String())
+	}
+}
+
+func TestNagleWriter_WithKeepAliveStopsOnClose(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithClock(clock), nagle.WithKeepAlive(time.Second, []byte("!")))
+	nagleWriter.Close()
+
+	if clock.Timers() != 0 {
+		t.Fatalf("expected no armed timers after close, got %d", clock.Timers())
+	}
+	clock.Advance(time.Second)
+	if out.Len() != 0 {
+		t.Fatalf("expected no keepalive after close, but got: '%s'", out.String())
+	}
+}
@@ -0,0 +1,53 @@
+package nagle
+
+import "io"
+
+// bypassLocked reports whether data is large enough to fill the buffer on its own, and
+// can be sent straight to the underlying writer once the buffered data is flushed,
+// saving its copy into the buffer and back out.
+func (nw *NagleWriter) bypassLocked(data []byte) bool {
+	if len(data) < nw.bufferSize || nw.corked || nw.paused || nw.transform != nil || nw.delimiter != nil || nw.strategy != nil {
+		return false
+	}
+	if nw.leaderDone != nil || nw.async != nil || nw.doubleBuffer || nw.flushIntervalWaitLocked(FlushTriggerSize) > 0 {
+		// The data has to go out the way flushes do: behind those under way or held
+		// back, or without the lock held.
+		return false
+	}
+	// A write over the pending bytes limit must keep failing with ErrBufferOverflow.
+	return nw.maxPendingBytes == 0 || len(data) <= nw.maxPendingBytes || (nw.blockOnFull && !nw.messageMode)
+}
+
+// writeLargeLocked flushes the buffer and then writes data directly, as a size flush of
+// its own. Bytes the underlying writer does not accept are buffered, as in flushLocked.
+func (nw *NagleWriter) writeLargeLocked(data []byte) (int, error) {
+	if _, err := nw.flushLocked(FlushTriggerSize); err != nil {
+		return 0, err
+	}
+	if nw.pendingLocked() > 0 {
+		start, n, err := nw.bufferLocked(data)
+		if err != nil {
+			return n, err
+		}
+		return n, nw.triggerFlushLocked(start)
+	}
+
+	start := nw.flushStartLocked()
+	n, err := nw.w.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	nw.checkFatal(err)
+	nw.markFlushedLocked(n)
+	nw.stats.Writes++
+	nw.stats.BytesWritten += int64(len(data))
+	nw.stats.record(FlushTriggerSize, int64(n), 0)
+	nw.queueFlushEventLocked(FlushTriggerSize, data, n, start, err)
+	if n < len(data) {
+		// The rest goes out with the next flush, like a partially flushed buffer.
+		nw.appendLocked(data[n:])
+		nw.pendingWrites = 1
+		nw.partial = n > 0
+	}
+	return len(data), flushFailed(err)
+}
@@ -0,0 +1,48 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_LargeWriteBypass(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	var batches []string
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour),
+		WithFlushHook(func(batch []byte, trigger FlushTrigger) { batches = append(batches, string(batch)) }))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("ab"))
+	if n, err := nagleWrapper.Write([]byte("0123456789")); n != 10 || err != nil {
+		t.Fatalf("expected to write 10 bytes, but got: %d, %v", n, err)
+	}
+
+	// The buffered data goes first, then the large write on its own
+	if len(mockRWC.writes) != 2 || mockRWC.writes[0] != "ab" || mockRWC.writes[1] != "0123456789" {
+		t.Fatalf("expected writes 'ab' and '0123456789', but got: %q", mockRWC.writes)
+	}
+	if len(batches) != 2 || batches[1] != "0123456789" {
+		t.Fatalf("expected the hook to see both flushes, but got: %q", batches)
+	}
+	stats := nagleWrapper.Stats()
+	if stats.SizeFlushes != 2 || stats.BytesFlushed != 12 || stats.MaxBuffered != 2 {
+		t.Fatalf("expected the large write never to be buffered, but got: %+v", stats)
+	}
+}
+
+func TestNagleWrapper_LargeWriteCorked(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	// Corked, a large write is buffered like any other
+	nagleWrapper.Cork()
+	nagleWrapper.Write([]byte("0123456789"))
+	if len(mockRWC.writes) != 0 || nagleWrapper.Buffered() != 10 {
+		t.Fatalf("expected the write to be held back, but got: %q", mockRWC.writes)
+	}
+	nagleWrapper.Uncork()
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "0123456789" {
+		t.Fatalf("expected a write of '0123456789', but got: %q", mockRWC.writes)
+	}
+}
@@ -0,0 +1,25 @@
+package nagle
+
+import "net"
+
+// listener wraps every accepted connection in a NagleConn.
+type listener struct {
+	net.Listener
+	opts []Option
+}
+
+// WrapListener returns a listener whose Accept returns connections already wrapped
+// in a NagleConn configured by opts, so an existing server gets write coalescing
+// by wrapping its listener.
+func WrapListener(l net.Listener, opts ...Option) net.Listener {
+	return &listener{Listener: l, opts: opts}
+}
+
+// Accept waits for the next connection and wraps it in a NagleConn.
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn, l.opts...), nil
+}
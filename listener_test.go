@@ -0,0 +1,43 @@
+package nagle
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWrapListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wrapped := WrapListener(l, WithBufferSize(10), WithFlushTimeout(time.Hour))
+	defer wrapped.Close()
+
+	go func() {
+		conn, err := wrapped.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("012"))
+		conn.Write([]byte("34"))
+		// The writes only reach the peer on flush
+		conn.(*NagleConn).Flush()
+	}()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	buf, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(buf) != "01234" {
+		t.Fatalf("expected to read '01234', but got: '%s'", string(buf))
+	}
+}
@@ -0,0 +1,43 @@
+package nagle
+
+import (
+	"context"
+	"log/slog"
+	"net"
+)
+
+// WithLogger logs through logger the events the wrapper otherwise handles silently:
+// failed flushes, writes rejected or dropped because the buffer is full, flush writes
+// retried under WithFlushRetry, and Close. Records carry the bytes and trigger involved
+// and, when the underlying stream has one, its remote address. Failed flushes are
+// logged once the wrapper lock is released, like observers are told about them.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// remoteAddrer is implemented by streams with a remote address, such as net.Conn.
+type remoteAddrer interface {
+	RemoteAddr() net.Addr
+}
+
+// newLogger adds the remote address of w, if any, to the records of logger.
+func newLogger(logger *slog.Logger, w any) *slog.Logger {
+	if logger == nil {
+		return nil
+	}
+	if r, ok := w.(remoteAddrer); ok {
+		if addr := r.RemoteAddr(); addr != nil {
+			logger = logger.With(slog.String("remote", addr.String()))
+		}
+	}
+	return logger
+}
+
+// log logs msg at level through the logger set with WithLogger, if any.
+func (nw *NagleWriter) log(level slog.Level, msg string, args ...any) {
+	if nw.logger != nil {
+		nw.logger.Log(context.Background(), level, msg, args...)
+	}
+}
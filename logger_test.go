@@ -0,0 +1,64 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// RemoteReadWriteCloser is a failing mock with a remote address.
+type RemoteReadWriteCloser struct {
+	FailingReadWriteCloser
+}
+
+func (r *RemoteReadWriteCloser) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}
+}
+
+func TestNagleWrapper_WithLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	mockRWC := &RemoteReadWriteCloser{FailingReadWriteCloser{err: errors.New("write failed")}}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxPendingBytes(4), WithLogger(logger))
+
+	nagleWrapper.Write([]byte("01234"))
+	nagleWrapper.Write([]byte("01"))
+	nagleWrapper.Flush()
+	nagleWrapper.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	expected := []string{
+		`msg="nagle: buffer full, write rejected" remote=127.0.0.1:9 bytes=5`,
+		`msg="nagle: flush failed" remote=127.0.0.1:9 trigger=explicit bytes=0 error="write failed"`,
+		`msg="nagle: closed" remote=127.0.0.1:9 bytes=0 unsent=2`,
+		`msg="nagle: flush failed" remote=127.0.0.1:9 trigger=close bytes=0`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d records, but got: %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, expected[i]) {
+			t.Fatalf("expected record %d to contain %s, but got: %s", i, expected[i], line)
+		}
+	}
+}
+
+func TestNagleWrapper_WithLoggerRetries(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, nil))
+	mockRWC := &FlakyReadWriteCloser{failures: 1, err: syscall.EAGAIN}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithFlushRetry(RetryPolicy{Attempts: 1}), WithLogger(logger))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Flush()
+	if !strings.Contains(out.String(), `msg="nagle: retrying flush write" attempt=1 backoff=0s unsent=2`) {
+		t.Fatalf("expected a retry record, but got: %s", out.String())
+	}
+}
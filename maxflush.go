@@ -0,0 +1,37 @@
+package nagle
+
+import "io"
+
+// WithMaxFlushSize splits every flush into underlying writes of at most n bytes, to
+// align them with the path MTU or a chunk limit downstream. Writes made straight to the
+// underlying writer, such as those of WriteUrgent, are split the same way. The pieces
+// of a flush are written back to back while the wrapper lock is held, and a failed
+// piece stops the flush with the rest still buffered. Zero, the default, means no limit.
+func WithMaxFlushSize(n int) Option {
+	return func(o *options) {
+		o.maxFlushSize = n
+	}
+}
+
+// chunkWriter splits writes to w into pieces of at most size bytes.
+type chunkWriter struct {
+	w    io.Writer
+	size int
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), c.size)]
+		n, err := c.w.Write(chunk)
+		total += n
+		if err == nil && n < len(chunk) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
@@ -0,0 +1,38 @@
+package nagle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNew_WithMaxFlushSize(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxFlushSize(4))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Write([]byte("456789"))
+	nagleWrapper.Flush()
+	if len(mockRWC.writes) != 3 || mockRWC.writes[0] != "0123" || mockRWC.writes[1] != "4567" || mockRWC.writes[2] != "89" {
+		t.Fatalf("expected writes of at most 4 bytes, but got: %q", mockRWC.writes)
+	}
+	if stats := nagleWrapper.Stats(); stats.ExplicitFlushes != 1 || stats.BytesFlushed != 10 {
+		t.Fatalf("expected a single flush of 10 bytes, but got: %+v", stats)
+	}
+}
+
+func TestNew_WithMaxFlushSizeShortWrite(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 3}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxFlushSize(4))
+	defer nagleWrapper.Close()
+
+	// A short piece stops the flush and keeps the rest buffered
+	nagleWrapper.Write([]byte("01234567"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, ErrFlushFailed) {
+		t.Fatalf("expected ErrFlushFailed, but got: %v", err)
+	}
+	if mockRWC.String() != "012" || nagleWrapper.Buffered() != 5 {
+		t.Fatalf("expected '012' written and 5 bytes buffered, but got: '%s' and %d", mockRWC.String(), nagleWrapper.Buffered())
+	}
+}
@@ -0,0 +1,117 @@
+package nagle
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrMemoryLimit is returned by Write when buffering the data would take the wrappers
+// sharing a non-blocking MemoryLimiter past its limit.
+var ErrMemoryLimit = errors.New("nagle: memory limit reached")
+
+// MemoryLimiter caps the bytes buffered by all the wrappers sharing it, for servers
+// holding buffers for tens of thousands of connections. Give it to every wrapper with
+// WithMemoryLimiter, typically through the options of a WrapperFactory.
+type MemoryLimiter struct {
+	mutex sync.Mutex
+	limit int
+	used  int
+	block bool
+	freed chan struct{}
+}
+
+// NewMemoryLimiter creates a limiter allowing limit buffered bytes in total. A Write
+// that would go past it first flushes its own wrapper; if that is not enough, it
+// blocks until other wrappers flush when block is true, and otherwise fails with
+// ErrMemoryLimit. A Write larger than limit always fails.
+func NewMemoryLimiter(limit int, block bool) *MemoryLimiter {
+	return &MemoryLimiter{limit: limit, block: block, freed: make(chan struct{})}
+}
+
+// WithMemoryLimiter accounts the bytes buffered by the wrapper against l.
+func WithMemoryLimiter(l *MemoryLimiter) Option {
+	return func(o *options) {
+		o.memory = l
+	}
+}
+
+// Limit returns the number of bytes the wrappers sharing l may buffer in total.
+func (l *MemoryLimiter) Limit() int {
+	return l.limit
+}
+
+// Used returns the number of bytes currently buffered by the wrappers sharing l.
+func (l *MemoryLimiter) Used() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.used
+}
+
+// acquire reserves n bytes if they fit. Otherwise it returns a channel closed the next
+// time bytes are released.
+func (l *MemoryLimiter) acquire(n int) (bool, <-chan struct{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.used+n > l.limit {
+		return false, l.freed
+	}
+	l.used += n
+	return true, nil
+}
+
+// adjust changes the reserved bytes by delta, waking up blocked writers when it frees some.
+func (l *MemoryLimiter) adjust(delta int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.used += delta
+	if delta < 0 {
+		close(l.freed)
+		l.freed = make(chan struct{})
+	}
+}
+
+// reserveMemoryLocked reserves n bytes from the memory limiter before they are buffered.
+func (nw *NagleWriter) reserveMemoryLocked(n int) error {
+	l := nw.memory
+	if n > l.limit {
+		return ErrMemoryLimit
+	}
+	nw.syncMemoryLocked()
+	flushed := false
+	for {
+		ok, freed := l.acquire(n)
+		if ok {
+			nw.reserved += n
+			return nil
+		}
+		if !flushed && !nw.corked {
+			// Making room in this wrapper is cheaper than waiting for the others.
+			flushed = true
+			if _, err := nw.flushLocked(FlushTriggerSize); err != nil {
+				return err
+			}
+			nw.syncMemoryLocked()
+			continue
+		}
+		if !l.block {
+			return ErrMemoryLimit
+		}
+		<-freed
+	}
+}
+
+// syncMemoryLocked brings the bytes reserved from the memory limiter in line with the
+// bytes actually buffered, giving back those that have been flushed.
+func (nw *NagleWriter) syncMemoryLocked() {
+	pending := 0
+	if nw.buffer != nil {
+		pending = nw.pendingLocked()
+	}
+	if delta := pending - nw.reserved; delta != 0 {
+		nw.memory.adjust(delta)
+		nw.reserved = pending
+	}
+}
@@ -0,0 +1,80 @@
+package nagle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	limiter := NewMemoryLimiter(4, false)
+	factory := NewWrapperFactory(nil, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMemoryLimiter(limiter))
+	first := &MockReadWriteCloser{}
+	w1 := factory.New(first)
+	w2 := factory.New(&MockReadWriteCloser{})
+
+	w1.Write([]byte("abc"))
+	if limiter.Used() != 3 {
+		t.Fatalf("expected 3 bytes in use, but got: %d", limiter.Used())
+	}
+
+	// The other wrapper has nothing of its own to flush, so it is turned away
+	if _, err := w2.Write([]byte("ab")); !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("expected %v, but got: %v", ErrMemoryLimit, err)
+	}
+
+	// Flushing gives the memory back
+	w1.Flush()
+	if _, err := w2.Write([]byte("ab")); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+
+	// A wrapper over the limit flushes itself to make room
+	if _, err := w1.Write([]byte("cd")); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if _, err := w2.Write([]byte("ef")); err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if first.String() != "abc" || limiter.Used() != 4 {
+		t.Fatalf("expected 4 bytes in use, but got: %d", limiter.Used())
+	}
+
+	// Closing a wrapper releases what it held
+	w1.Close()
+	w2.Close()
+	if limiter.Used() != 0 {
+		t.Fatalf("expected no bytes in use, but got: %d", limiter.Used())
+	}
+	if _, err := factory.New(&MockReadWriteCloser{}).Write([]byte("abcde")); !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("expected a write larger than the limit to fail, but got: %v", err)
+	}
+}
+
+func TestMemoryLimiterBlocking(t *testing.T) {
+	limiter := NewMemoryLimiter(4, true)
+	w1 := New(&MockReadWriteCloser{}, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMemoryLimiter(limiter))
+	w2 := New(&MockReadWriteCloser{}, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMemoryLimiter(limiter))
+	defer w1.Close()
+	defer w2.Close()
+
+	w1.Write([]byte("abcd"))
+	done := make(chan error, 1)
+	go func() {
+		_, err := w2.Write([]byte("x"))
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected the write to block on the memory limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	w1.Flush()
+	if err := <-done; err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if limiter.Used() != 1 {
+		t.Fatalf("expected 1 byte in use, but got: %d", limiter.Used())
+	}
+}
@@ -0,0 +1,100 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+)
+
+// errNoFraming is returned by ReadMessage on a wrapper configured without framing.
+var errNoFraming = errors.New("nagle: ReadMessage needs WithMessageFraming or WithFraming")
+
+// WithMessageFraming prefixes the data of every Write with its length, encoded as
+// selected by prefix, as it is buffered. Writes are still coalesced, but the receiver
+// can split the stream back into the original writes with ReadMessage or a
+// FrameScanner. Unlike WithFraming, which frames each flushed batch, it keeps the
+// message boundaries; combined with it, every batch frame carries whole message frames.
+func WithMessageFraming(prefix FramePrefix) Option {
+	return func(o *options) {
+		o.messagePrefix = prefix
+	}
+}
+
+// frameMessageLocked returns data prefixed with its length under WithMessageFraming,
+// along with the length of the prefix. The result is only valid until the next call.
+func (nw *NagleWriter) frameMessageLocked(data []byte) ([]byte, int) {
+	if nw.messagePrefix == FramePrefixNone {
+		return data, 0
+	}
+	if cap(nw.messageBuf) > maxPooledBufferSize {
+		// Do not hold on to the copy of an unusually large write.
+		nw.messageBuf = nil
+	}
+	nw.messageBuf = appendFrame(nw.messageBuf[:0], nw.messagePrefix, data)
+	return nw.messageBuf, len(nw.messageBuf) - len(data)
+}
+
+// ReadMessage reads the next message written by a peer configured like this wrapper:
+// a Write made under WithMessageFraming or, without it, a batch flushed under
+// WithFraming, checked when WithChecksum is set. The returned slice is only valid until
+// the next call. Reads go through Read, so SetReadDeadline applies, but the two must
+// not be mixed as ReadMessage reads ahead.
+func (nw *NagleWrapper) ReadMessage() ([]byte, error) {
+	if nw.messages == nil {
+		if nw.messagePrefix == FramePrefixNone && nw.framing == FramePrefixNone {
+			return nil, errNoFraming
+		}
+		var r io.Reader = readerOnly{nw}
+		if nw.framing != FramePrefixNone {
+			batches := NewFrameReader(r, nw.framing)
+			batches.SetChecksum(nw.checksum)
+			nw.messages, r = batches, batches
+		}
+		if nw.messagePrefix != FramePrefixNone {
+			nw.messages = NewFrameReader(r, nw.messagePrefix)
+		}
+	}
+	return nw.messages.ReadFrame()
+}
+
+// readerOnly hides every method of the reader but Read.
+type readerOnly struct {
+	io.Reader
+}
+
+// FrameScanner splits a stream of frames like bufio.Scanner does lines, for loops
+// over the messages written under WithMessageFraming or the batches of WithFraming.
+// The embedded FrameReader can be configured before the first call to Scan.
+type FrameScanner struct {
+	*FrameReader
+	frame []byte
+	err   error
+}
+
+// NewFrameScanner creates a scanner of frames with the given prefix from r.
+func NewFrameScanner(r io.Reader, prefix FramePrefix) *FrameScanner {
+	return &FrameScanner{FrameReader: NewFrameReader(r, prefix)}
+}
+
+// Scan advances to the next frame, which is then available through Bytes. It returns
+// false at the end of the stream or on an error, reported by Err.
+func (s *FrameScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	s.frame, s.err = s.ReadFrame()
+	return s.err == nil
+}
+
+// Bytes returns the frame found by the last call to Scan. The slice is only valid
+// until the next call.
+func (s *FrameScanner) Bytes() []byte {
+	return s.frame
+}
+
+// Err returns the first error met by Scan, or nil if the stream ended cleanly.
+func (s *FrameScanner) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+	return s.err
+}
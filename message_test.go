@@ -0,0 +1,83 @@
+package nagle
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_ReadMessage(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithMessageFraming(FramePrefixUvarint)},
+		{WithMessageFraming(FramePrefixUint32), WithChecksum()},
+		{WithFraming(FramePrefixUvarint)},
+	} {
+		client, server := net.Pipe()
+		sender := New(client, append([]Option{WithBufferSize(100), WithFlushTimeout(time.Hour)}, opts...)...)
+		receiver := New(server, opts...)
+
+		go func() {
+			sender.Write([]byte("first"))
+			sender.Flush()
+			sender.Write([]byte("second"))
+			sender.Flush()
+			sender.Close()
+		}()
+
+		for _, want := range []string{"first", "second"} {
+			msg, err := receiver.ReadMessage()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(msg) != want {
+				t.Fatalf("expected message '%s', but got: '%s'", want, msg)
+			}
+		}
+		receiver.Close()
+	}
+}
+
+func TestNagleWrapper_ReadMessageWithoutFraming(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{})
+	defer nagleWrapper.Close()
+
+	if _, err := nagleWrapper.ReadMessage(); err != errNoFraming {
+		t.Fatalf("expected %v, but got: %v", errNoFraming, err)
+	}
+}
+
+func TestFrameScanner(t *testing.T) {
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMessageFraming(FramePrefixUvarint))
+
+	// The writes are coalesced into one flush but keep their boundaries
+	nagleWriter.Write([]byte("a"))
+	nagleWriter.Write([]byte(""))
+	nagleWriter.WriteBatch([][]byte{[]byte("bc"), []byte("def")})
+	if n, err := nagleWriter.WriteString("gh"); n != 2 || err != nil {
+		t.Fatalf("expected to write 2 bytes, but got: %d, %v", n, err)
+	}
+	nagleWriter.Close()
+	if stats := nagleWriter.Stats(); stats.Flushes() != 1 {
+		t.Fatalf("expected a single flush, but got: %+v", stats)
+	}
+
+	var frames []string
+	scanner := NewFrameScanner(&out, FramePrefixUvarint)
+	for scanner.Scan() {
+		frames = append(frames, string(scanner.Bytes()))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"a", "", "bc", "def", "gh"}
+	if len(frames) != len(want) {
+		t.Fatalf("expected frames %q, but got: %q", want, frames)
+	}
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Fatalf("expected frames %q, but got: %q", want, frames)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package nagle
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+var errShortSealedBatch = errors.New("nagle: sealed batch too short")
+
+// Middleware rewrites an outgoing batch, for example compressing, encrypting or
+// checksumming it, and returns the bytes to pass on. It must not retain batch after
+// returning. Functions usable with WithTransform, such as GzipTransform, are middlewares.
+type Middleware func(batch []byte) ([]byte, error)
+
+// WithFlushMiddleware adds mw to the pipeline every flushed batch goes through. The
+// batch is passed through the transform set with WithTransform, if any, then through
+// each middleware in the order added, and finally framed if WithFraming is given.
+// Middlewares run during the flush with the wrapper lock held, which keeps batches in
+// order, so they must not call back into the wrapper. If one fails, the batch stays
+// buffered and the error is returned by the flush.
+func WithFlushMiddleware(mw ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, mw...)
+	}
+}
+
+// AEADMiddleware returns a middleware that seals each batch with aead, such as
+// AES-GCM, under a fresh random nonce written before the ciphertext. Combine it with
+// WithFraming so the peer can split the stream back into sealed batches and open
+// each of them with OpenAEAD.
+func AEADMiddleware(aead cipher.AEAD) Middleware {
+	return func(batch []byte) ([]byte, error) {
+		out := make([]byte, aead.NonceSize(), aead.NonceSize()+len(batch)+aead.Overhead())
+		if _, err := rand.Read(out); err != nil {
+			return nil, err
+		}
+		return aead.Seal(out, out, batch, nil), nil
+	}
+}
+
+// OpenAEAD opens a batch sealed by AEADMiddleware.
+func OpenAEAD(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errShortSealedBatch
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// chainMiddleware combines pipeline into one transform, or returns nil if it is empty.
+func chainMiddleware(pipeline []Middleware) func([]byte) ([]byte, error) {
+	switch len(pipeline) {
+	case 0:
+		return nil
+	case 1:
+		return pipeline[0]
+	}
+	return func(batch []byte) ([]byte, error) {
+		for _, mw := range pipeline {
+			var err error
+			if batch, err = mw(batch); err != nil {
+				return nil, err
+			}
+		}
+		return batch, nil
+	}
+}
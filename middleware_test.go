@@ -0,0 +1,96 @@
+package nagle
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithFlushMiddleware(t *testing.T) {
+	wrap := func(open, close string) Middleware {
+		return func(batch []byte) ([]byte, error) {
+			return append(append([]byte(open), batch...), close...), nil
+		}
+	}
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithTransform(wrap("<", ">")), WithFlushMiddleware(wrap("[", "]"), wrap("(", ")")))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01"))
+	nagleWrapper.Write([]byte("23"))
+	nagleWrapper.Flush()
+
+	// The transform runs first, then the middlewares in order
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "([<0123>])" {
+		t.Fatalf("expected a single write of '([<0123>])', got %q", mockRWC.writes)
+	}
+}
+
+func TestAEADMiddleware(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	aead, _ := cipher.NewGCM(block)
+
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithFlushMiddleware(AEADMiddleware(aead)), WithFraming(FramePrefixUvarint))
+	nagleWriter.Write([]byte("secret "))
+	nagleWriter.Write([]byte("message"))
+	nagleWriter.Flush()
+	nagleWriter.Write([]byte("another"))
+	nagleWriter.Close()
+
+	if bytes.Contains(out.Bytes(), []byte("secret")) {
+		t.Fatal("expected the stream to be encrypted")
+	}
+
+	fr := NewFrameReader(&out, FramePrefixUvarint)
+	for _, expected := range []string{"secret message", "another"} {
+		sealed, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batch, err := OpenAEAD(aead, sealed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(batch) != expected {
+			t.Fatalf("expected '%s', but got: '%s'", expected, string(batch))
+		}
+	}
+}
+
+func TestAEADMiddleware_DirectWrites(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	aead, _ := cipher.NewGCM(block)
+
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithFlushMiddleware(AEADMiddleware(aead)), WithFraming(FramePrefixUvarint))
+	nagleWriter.Write([]byte("SECRET-buffered"))
+	nagleWriter.WriteUrgent([]byte("SECRET-urgent"))
+	nagleWriter.Flush()
+	nagleWriter.WriteNoDelay([]byte("SECRET-nodelay"))
+	nagleWriter.Close()
+
+	// Urgent and no-delay writes are sealed like flushed batches
+	if bytes.Contains(out.Bytes(), []byte("SECRET")) {
+		t.Fatal("expected the stream to be encrypted")
+	}
+	fr := NewFrameReader(&out, FramePrefixUvarint)
+	for _, expected := range []string{"SECRET-urgent", "SECRET-buffered", "SECRET-nodelay"} {
+		sealed, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batch, err := OpenAEAD(aead, sealed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(batch) != expected {
+			t.Fatalf("expected '%s', but got: '%s'", expected, string(batch))
+		}
+	}
+}
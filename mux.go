@@ -0,0 +1,196 @@
+package nagle
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// muxHeaderSize is the size of the stream id and payload length before each mux frame.
+const muxHeaderSize = 8
+
+var errMuxStreamExists = errors.New("nagle: mux stream already open")
+
+// Mux multiplexes logical streams over one underlying writer, such as a connection.
+// Every stream is a wrapper of its own, with its own buffering and options, whose
+// flushes become frames tagged with the stream id. The frames go through a shared
+// wrapper, configured by the options given to NewMux, that coalesces the flushes of
+// different streams into single underlying writes. A MuxReader splits them apart on
+// the other end.
+type Mux struct {
+	out     *NagleWriter
+	mutex   sync.Mutex
+	streams map[uint32]*NagleWriter
+	closed  bool
+}
+
+// NewMux creates a Mux writing to w. Give it a flush timeout shorter than those of the
+// streams, as the data of a stream waits twice: in the stream and in the Mux.
+func NewMux(w io.Writer, opts ...Option) *Mux {
+	return &Mux{
+		out:     NewWriter(w, opts...),
+		streams: make(map[uint32]*NagleWriter),
+	}
+}
+
+// Stream opens the stream id, buffered as configured by opts. Closing the stream sends
+// an empty frame that tells the peer it ended, after which the id can be opened again.
+func (m *Mux) Stream(id uint32, opts ...Option) (*NagleWriter, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+	if _, ok := m.streams[id]; ok {
+		return nil, errMuxStreamExists
+	}
+	sw := &muxStreamWriter{mux: m, id: id}
+	opts = append(opts[:len(opts):len(opts)], func(o *options) {
+		o.onClose = sw.end
+	})
+	nw := NewWriter(sw, opts...)
+	m.streams[id] = nw
+	return nw, nil
+}
+
+// Flush flushes every stream and then the frames they queued in the Mux.
+func (m *Mux) Flush() error {
+	var errs []error
+	for _, nw := range m.snapshot() {
+		if err := nw.Flush(); err != nil && !errors.Is(err, ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, m.out.Flush())
+	return errors.Join(errs...)
+}
+
+// Close closes every stream and then the Mux, closing the underlying writer if it
+// implements io.Closer.
+func (m *Mux) Close() error {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return ErrClosed
+	}
+	m.closed = true
+	m.mutex.Unlock()
+
+	var errs []error
+	for _, nw := range m.snapshot() {
+		if err := nw.Close(); err != nil && !errors.Is(err, ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, m.out.Close())
+	return errors.Join(errs...)
+}
+
+// Stats returns the statistics of the shared wrapper, whose flushes are the underlying writes.
+func (m *Mux) Stats() Stats {
+	return m.out.Stats()
+}
+
+func (m *Mux) snapshot() []*NagleWriter {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	streams := make([]*NagleWriter, 0, len(m.streams))
+	for _, nw := range m.streams {
+		streams = append(streams, nw)
+	}
+	return streams
+}
+
+// muxStreamWriter frames the flushed batches of one stream into the Mux. It is only
+// written by the stream's flushes, one at a time.
+type muxStreamWriter struct {
+	mux   *Mux
+	id    uint32
+	frame []byte
+}
+
+func (sw *muxStreamWriter) Write(batch []byte) (int, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if _, err := sw.writeFrame(batch); err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// writeFrame writes the header and payload as a single Write, so they stay together.
+func (sw *muxStreamWriter) writeFrame(payload []byte) (int, error) {
+	sw.frame = binary.BigEndian.AppendUint32(sw.frame[:0], sw.id)
+	sw.frame = binary.BigEndian.AppendUint32(sw.frame, uint32(len(payload)))
+	sw.frame = append(sw.frame, payload...)
+	n, err := sw.mux.out.Write(sw.frame)
+	if cap(sw.frame) > maxPooledBufferSize {
+		sw.frame = nil
+	}
+	return n, err
+}
+
+// end runs when the stream is closed, after its final flush, and on later calls to
+// its Close. The lock is held while the end is sent, so the id is not opened again
+// before the peer learns it ended.
+func (sw *muxStreamWriter) end(nw *NagleWriter) {
+	m := sw.mux
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.streams[sw.id] != nw {
+		return
+	}
+	delete(m.streams, sw.id)
+	sw.writeFrame(nil)
+}
+
+// MuxReader reads the frames written by a Mux.
+type MuxReader struct {
+	r            *bufio.Reader
+	maxFrameSize int
+	frame        []byte
+}
+
+// NewMuxReader creates a reader of the frames of a Mux from r.
+func NewMuxReader(r io.Reader) *MuxReader {
+	return &MuxReader{r: bufio.NewReader(r), maxFrameSize: DefaultMaxFrameSize}
+}
+
+// SetMaxFrameSize sets the largest frame accepted, like FrameReader.SetMaxFrameSize.
+func (mr *MuxReader) SetMaxFrameSize(n int) {
+	mr.maxFrameSize = n
+}
+
+// ReadFrame returns the stream id and payload of the next frame. An empty payload
+// marks the end of the stream. The returned slice is only valid until the next call.
+// It returns io.EOF at a clean end of stream and io.ErrUnexpectedEOF if the stream
+// ends inside a frame.
+func (mr *MuxReader) ReadFrame() (uint32, []byte, error) {
+	var header [muxHeaderSize]byte
+	if _, err := io.ReadFull(mr.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	id := binary.BigEndian.Uint32(header[:4])
+	size := binary.BigEndian.Uint32(header[4:])
+	if uint64(size) > uint64(mr.maxFrameSize) {
+		return 0, nil, ErrFrameTooLarge
+	}
+
+	if uint32(cap(mr.frame)) < size {
+		mr.frame = make([]byte, size)
+	}
+	mr.frame = mr.frame[:size]
+	if _, err := io.ReadFull(mr.r, mr.frame); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return id, mr.frame, nil
+}
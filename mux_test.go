@@ -0,0 +1,99 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMux(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	mux := NewMux(mockRWC, WithBufferSize(1024), WithFlushTimeout(time.Hour))
+	a, err := mux.Stream(1, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := mux.Stream(2, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	if _, err := mux.Stream(1); !errors.Is(err, errMuxStreamExists) {
+		t.Fatalf("expected errMuxStreamExists, but got: %v", err)
+	}
+
+	a.Write([]byte("a0"))
+	b.Write([]byte("b0"))
+	a.Write([]byte("a1"))
+	// The flushes of both streams are coalesced into one underlying write
+	a.Flush()
+	b.Flush()
+	if len(mockRWC.writes) != 0 {
+		t.Fatalf("expected no writes before the mux flushes, but got: %q", mockRWC.writes)
+	}
+	if err := mux.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockRWC.writes) != 1 {
+		t.Fatalf("expected a single write, but got: %q", mockRWC.writes)
+	}
+
+	b.Write([]byte("b1"))
+	if err := mux.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := mux.Stream(3); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+
+	mr := NewMuxReader(bytes.NewReader([]byte(mockRWC.String())))
+	payloads := map[uint32]string{}
+	ended := map[uint32]bool{}
+	for {
+		id, payload, err := mr.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(payload) == 0 {
+			ended[id] = true
+			continue
+		}
+		if ended[id] {
+			t.Fatalf("expected no data after the end of stream %d", id)
+		}
+		payloads[id] += string(payload)
+	}
+	if payloads[1] != "a0a1" || payloads[2] != "b0b1" {
+		t.Fatalf("expected 'a0a1' and 'b0b1', but got: %q", payloads)
+	}
+	if !ended[1] || !ended[2] {
+		t.Fatalf("expected both streams to end, but got: %v", ended)
+	}
+}
+
+func TestMux_ReopenStream(t *testing.T) {
+	var out bytes.Buffer
+	mux := NewMux(&out, WithFlushTimeout(time.Hour))
+	defer mux.Close()
+
+	s, _ := mux.Stream(1)
+	s.Write([]byte("x"))
+	s.Close()
+	s.Close()
+	if _, err := mux.Stream(1); err != nil {
+		t.Fatalf("expected the id to be reusable after Close, but got: %v", err)
+	}
+
+	// A repeated Close does not end the stream twice
+	mux.Flush()
+	mr := NewMuxReader(&out)
+	for _, want := range []string{"x", ""} {
+		if _, payload, err := mr.ReadFrame(); err != nil || string(payload) != want {
+			t.Fatalf("expected frame %q, but got: %q, %v", want, payload, err)
+		}
+	}
+	if _, _, err := mr.ReadFrame(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, but got: %v", err)
+	}
+}
@@ -2,124 +2,862 @@ package nagle
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
+)
+
+// NagleWriter wraps an io.Writer with Nagle's algorithm buffering logic.
+// Errors from background flushes are reported by the next call to Write, Flush or Close.
+//
+// A NagleWriter is safe for concurrent use, and whatever its options it guarantees that
+// the bytes of every Write reach the underlying writer whole and in the order the calls
+// were serialized by the wrapper lock, and that Flush and Close send the data of every
+// Write that returned before they were called. WriteUrgent is the one call that
+// overtakes buffered data, within the bounds of WithUrgentFairness.
+type NagleWriter struct {
+	w               io.Writer
+	base            io.Writer
+	closer          io.Closer
+	closeMutex      sync.Mutex
+	streamClosed    bool
+	buffer          flushBuffer
+	bufferSize      int
+	flushTimeout    time.Duration
+	maxPendingBytes int
+	maxWrites       int
+	pendingWrites   int
+	blockOnFull     bool
+	overflow        OverflowPolicy
+	writes          []bufferedWrite
+	writesTotal     int
+	ttl             time.Duration
+	clock           Clock
+	scheduler       timerScheduler
+	mutex           ctxMutex
+	timer           Timer
+	flushAt         time.Time
+	firesAt         time.Time
+	armed           bool
+	adaptive        adaptiveTimeout
+	messageMode     bool
+	corked          bool
+	partial         bool
+	transform       func([]byte) ([]byte, error)
+	delimiter       []byte
+	flushHook       func([]byte, FlushTrigger)
+	flushObservers  []func(FlushInfo)
+	hookEvents      []flushEvent
+	encoded         []byte
+	closed          bool
+	writeClosed     bool
+	stats           Stats
+	asyncErr        error
+	onError         func(error)
+	doneState       doneState
+	onClose         func(*NagleWriter)
+	ackGating       bool
+	autoAck         bool
+	inFlight        bool
+	leaderDone      chan struct{}
+	flushing        *bytes.Buffer
+	minInterval     time.Duration
+	lastFlush       time.Time
+	doubleBuffer    bool
+	async           *asyncFlusher
+	memory          *MemoryLimiter
+	reserved        int
+	flushOnRead     bool
+	nested          bool
+	ownedWrite      bool
+	messagePrefix   FramePrefix
+	messageBuf      []byte
+	keepAlive       time.Duration
+	keepAliveData   []byte
+	keepAliveTimer  Timer
+	lastSent        time.Time
+	retry           *retryWriter
+	records         *recordCounter
+	frames          *frameWriter
+	oldest          time.Time
+	contexts        []context.Context
+	logger          *slog.Logger
+	strict          bool
+	owned           []ownedSlice
+	prealloc        int
+	signals         []*FlushSignal
+	strategy        Strategy
+	latencyBudget   time.Duration
+	softSize        int
+	softDelay       time.Duration
+	stagers         []stager
+	draining        bool
+	fairWrites      int
+	fairSlice       time.Duration
+	overtaken       int
+	overtakenAt     time.Time
+	jitter          float64
+	paused          bool
+	maxPause        time.Duration
+	pauseTimer      Timer
+	pauses          uint64
+	reconnect       *reconnector
+	journal         *journal
+	recovered       int
+	oneByte         [1]byte
+}
+
+var (
+	_ io.StringWriter = (*NagleWriter)(nil)
+	_ io.ByteWriter   = (*NagleWriter)(nil)
 )
 
 // NagleWrapper wraps a ReadWriteCloser interface with Nagle's algorithm buffering logic.
+// Writes are coalesced by the embedded NagleWriter.
 type NagleWrapper struct {
-	rwc          io.ReadWriteCloser
-	buffer       *bytes.Buffer
-	bufferSize   int
-	flushTimeout time.Duration
-	mutex        sync.Mutex
-	timer        *time.Timer
-	closed       bool
-	wg           sync.WaitGroup
+	*NagleWriter
+	source    atomic.Pointer[readSource]
+	deadlines atomic.Pointer[deadlineReader]
+	framing   FramePrefix
+	checksum  bool
+	messages  *FrameReader
+	reading   atomic.Bool
 }
 
 // NewNagleWrapper creates a new wrapper with Nagle's algorithm.
 func NewNagleWrapper(rwc io.ReadWriteCloser, bufferSize int, flushTimeout time.Duration) *NagleWrapper {
-	wrapper := &NagleWrapper{
-		rwc:          rwc,
-		buffer:       &bytes.Buffer{},
-		bufferSize:   bufferSize,
-		flushTimeout: flushTimeout,
-		timer:        time.NewTimer(flushTimeout),
-		closed:       false,
+	return New(rwc, WithBufferSize(bufferSize), WithFlushTimeout(flushTimeout))
+}
+
+// Write writes data to the buffer and sends it if the buffer is full or the maximum time (timeout) has passed.
+// Data at least as large as the buffer size is written directly after the buffered data,
+// without being copied into the buffer first.
+func (nw *NagleWriter) Write(data []byte) (int, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	return nw.writeLocked(data)
+}
+
+// WriteString is like Write but takes a string, without copying it into a []byte first.
+func (nw *NagleWriter) WriteString(s string) (int, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	// The bytes are only read, being copied into the buffer, so they may alias s.
+	return nw.writeLocked(unsafe.Slice(unsafe.StringData(s), len(s)))
+}
+
+// WriteOwned is like Write but hands p over to the wrapper, which may queue it as is
+// instead of copying it into the buffer, so the caller must neither modify nor reuse p
+// after the call. The copy is saved when the wrapper flushes with writev, as enabled by
+// WithVectoredFlush; otherwise p is copied like in Write.
+func (nw *NagleWriter) WriteOwned(p []byte) (int, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.ownedWrite = true
+	n, err := nw.writeLocked(p)
+	nw.ownedWrite = false
+	return n, err
+}
+
+// WriteByte is like Write for a single byte.
+func (nw *NagleWriter) WriteByte(c byte) error {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.oneByte[0] = c
+	_, err := nw.writeLocked(nw.oneByte[:])
+	return err
+}
+
+// WriteContext is like Write but gives up waiting for the wrapper lock, held for example
+// by a flush to a slow underlying writer, when ctx is canceled or its deadline passes.
+// Observers added with WithFlushObserver find ctx in the Contexts of the next flush.
+func (nw *NagleWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	if err := nw.mutex.LockContext(ctx); err != nil {
+		return 0, err
 	}
+	defer nw.unlock()
 
-	wrapper.wg.Add(1)
-	go wrapper.handleFlush()
+	if len(nw.flushObservers) > 0 {
+		nw.contexts = append(nw.contexts, ctx)
+	}
+	return nw.writeLocked(data)
+}
 
-	return wrapper
+// WriteAndFlush appends data to the buffer and flushes it immediately.
+func (nw *NagleWriter) WriteAndFlush(data []byte) (int, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	n, err := nw.writeLocked(data)
+	if err != nil {
+		return n, err
+	}
+	if _, err := nw.flushLocked(FlushTriggerExplicit); err != nil {
+		return n, err
+	}
+	return len(data), nil
 }
 
-// Write writes data to the buffer and sends it if the buffer is full or the maximum time (timeout) has passed.
-func (nw *NagleWrapper) Write(data []byte) (int, error) {
+// WriteNoDelay sends data straight to the underlying stream when nothing is buffered,
+// skipping coalescing for latency-critical writes. Otherwise the data is appended and
+// flushed along with the buffered bytes, preserving ordering. While corked it behaves like Write.
+// With a transform, middleware, framing or checksum set, the data is flushed as a batch
+// of its own, so it goes through them like any other.
+func (nw *NagleWriter) WriteNoDelay(data []byte) (int, error) {
 	nw.mutex.Lock()
-	defer nw.mutex.Unlock()
+	defer nw.unlock()
 
-	if nw.closed {
-		return 0, io.ErrClosedPipe
+	nw.waitLeaderLocked()
+	if nw.closed || nw.writeClosed || nw.corked || nw.paused || nw.pendingLocked() > 0 || nw.transform != nil {
+		n, err := nw.writeLocked(data)
+		if err != nil || nw.corked {
+			return n, err
+		}
+		if _, err := nw.flushLocked(FlushTriggerExplicit); err != nil {
+			return n, err
+		}
+		return len(data), nil
+	}
+
+	if err := nw.takeAsyncErrLocked(); err != nil {
+		return 0, err
 	}
 
-	nw.buffer.Write(data)
+	framed, header := nw.frameMessageLocked(data)
+	n, err := nw.writeThroughLocked(framed)
+	return max(n-header, 0), err
+}
 
-	if nw.buffer.Len() >= nw.bufferSize {
-		return nw.flushLocked()
+// writeThroughLocked writes data straight to the underlying writer, bypassing the buffer.
+func (nw *NagleWriter) writeThroughLocked(data []byte) (int, error) {
+	n, err := nw.w.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	nw.checkFatal(err)
+	if n > 0 {
+		nw.markSentLocked()
+	}
+	nw.stats.Writes++
+	nw.stats.DirectWrites++
+	nw.stats.BytesWritten += int64(n)
+	nw.stats.BytesFlushed += int64(n)
+	return n, err
+}
+
+// WriteUrgent sends data to the underlying stream immediately, ahead of any data
+// buffered by Write, which stays buffered and keeps coalescing. It also bypasses Cork.
+// Urgent data can land between any two buffered writes, so use it for self-contained
+// frames such as heartbeats and control messages. If a previous flush stopped in
+// the middle of a write, the rest of the buffer is sent first to keep that write whole.
+// With a transform, middleware, framing or checksum set, the data goes through them as
+// a batch of its own.
+func (nw *NagleWriter) WriteUrgent(data []byte) (int, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed || nw.writeClosed {
+		return 0, ErrClosed
+	}
+
+	if err := nw.takeAsyncErrLocked(); err != nil {
+		return 0, err
+	}
+	if nw.paused {
+		return nw.writeLocked(data)
 	}
 
-	if !nw.timer.Stop() {
-		select {
-		case <-nw.timer.C:
-		default:
+	if nw.partial || nw.fairFlushDueLocked() {
+		if _, err := nw.flushLocked(FlushTriggerExplicit); err != nil {
+			return 0, err
 		}
 	}
 
-	nw.timer.Reset(nw.flushTimeout)
+	framed, header := nw.frameMessageLocked(data)
+	var n int
+	var err error
+	if nw.transform != nil {
+		n, err = nw.writeTransformedLocked(framed)
+	} else {
+		n, err = nw.w.Write(framed)
+		if err == nil && n < len(framed) {
+			err = io.ErrShortWrite
+		}
+		nw.checkFatal(err)
+		if n > 0 {
+			nw.markSentLocked()
+		}
+		nw.stats.BytesFlushed += int64(n)
+	}
+	nw.stats.Writes++
+	nw.stats.UrgentWrites++
+	nw.stats.BytesWritten += int64(n)
+	return max(n-header, 0), err
+}
 
-	return len(data), nil
+// WriteBatch appends every slice of batches under a single lock acquisition, each one
+// counting as a separate Write, and evaluates the flush triggers once at the end.
+// It returns the total number of bytes accepted.
+func (nw *NagleWriter) WriteBatch(batches [][]byte) (int, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	if err := nw.writableLocked(); err != nil {
+		return 0, err
+	}
+
+	before := nw.buffer.Len()
+	total := 0
+	for _, data := range batches {
+		framed, header := nw.frameMessageLocked(data)
+		start, n, err := nw.bufferLocked(framed)
+		total += max(n-header, 0)
+		before = min(before, start)
+		if err != nil {
+			// Whatever was accepted still has to be flushed eventually.
+			nw.triggerFlushLocked(before)
+			return total, err
+		}
+	}
+	return total, nw.triggerFlushLocked(before)
+}
+
+func (nw *NagleWriter) writeLocked(data []byte) (int, error) {
+	if err := nw.writableLocked(); err != nil {
+		return 0, err
+	}
+	framed, header := nw.frameMessageLocked(data)
+	n, err := nw.writeFrameLocked(framed)
+	return max(n-header, 0), err
+}
+
+// writeFrameLocked is writeLocked for data already framed under WithMessageFraming.
+func (nw *NagleWriter) writeFrameLocked(data []byte) (int, error) {
+	if nw.passThroughLocked() {
+		return nw.writeThroughLocked(data)
+	}
+	if nw.bypassLocked(data) {
+		return nw.writeLargeLocked(data)
+	}
+
+	start, n, err := nw.bufferLocked(data)
+	if err != nil {
+		return n, err
+	}
+	// The data has been accepted even if a flush fails, so report all of it.
+	return n, nw.triggerFlushLocked(start)
+}
+
+// writableLocked returns the error a write must fail with before buffering anything.
+func (nw *NagleWriter) writableLocked() error {
+	if nw.strict && (nw.closed || nw.writeClosed) {
+		nw.violation("write after Close")
+	}
+	if nw.closed || nw.writeClosed {
+		return ErrClosed
+	}
+	return nw.takeAsyncErrLocked()
+}
+
+// bufferLocked appends data to the buffer, flushing first when the message mode or the
+// pending bytes limit require it. It returns the offset in the buffer where data starts.
+func (nw *NagleWriter) bufferLocked(data []byte) (int, int, error) {
+	n := len(data)
+	if nw.memory != nil {
+		if err := nw.reserveMemoryLocked(len(data)); err != nil {
+			if errors.Is(err, ErrMemoryLimit) {
+				nw.log(slog.LevelWarn, "nagle: memory limit reached, write rejected", "bytes", len(data))
+			}
+			return 0, 0, err
+		}
+	}
+	if nw.messageMode && !nw.corked && nw.buffer.Len() > 0 && nw.buffer.Len()+len(data) > nw.bufferSize {
+		// Send the messages already buffered rather than growing the batch past the threshold.
+		if _, err := nw.flushLocked(FlushTriggerSize); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if nw.leaderDone != nil && nw.blockOnFull && nw.maxPendingBytes > 0 && nw.buffer.Len()+len(data) > nw.maxPendingBytes {
+		// Room is made by flushing, which has to wait for the write in progress.
+		nw.waitLeaderLocked()
+		if err := nw.writableLocked(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if nw.maxPendingBytes > 0 && nw.buffer.Len()+len(data) > nw.maxPendingBytes {
+		if nw.dropping() {
+			if !nw.corked {
+				nw.flushLocked(FlushTriggerSize)
+			}
+			if !nw.overflowLocked(len(data)) {
+				return nw.buffer.Len(), n, nil
+			}
+		} else if !nw.blockOnFull || nw.messageMode {
+			nw.flushLocked(FlushTriggerSize)
+			if nw.buffer.Len()+len(data) > nw.maxPendingBytes {
+				nw.log(slog.LevelWarn, "nagle: buffer full, write rejected", "bytes", len(data))
+				return 0, 0, ErrBufferOverflow
+			}
+		}
+		if nw.paused {
+			nw.log(slog.LevelWarn, "nagle: buffer full while paused, write rejected", "bytes", len(data))
+			return 0, 0, ErrBufferOverflow
+		}
+		// Fill the buffer up to the limit and flush until the rest fits,
+		// so the caller is held back by the speed of the underlying writer.
+		for nw.buffer.Len()+len(data) > nw.maxPendingBytes {
+			if space := nw.maxPendingBytes - nw.buffer.Len(); space > 0 {
+				nw.appendLocked(data[:space])
+				data = data[space:]
+			}
+			nw.waitFlushIntervalLocked(FlushTriggerSize)
+			if _, err := nw.flushLocked(FlushTriggerSize); err != nil {
+				return 0, n - len(data), err
+			}
+		}
+	}
+
+	start := nw.buffer.Len()
+	nw.appendLocked(data)
+	nw.pendingWrites++
+	nw.stats.Writes++
+	return start, n, nil
+}
+
+// triggerFlushLocked flushes if buffering the data appended from offset before onwards
+// fired a trigger, and otherwise schedules the timeout flush.
+func (nw *NagleWriter) triggerFlushLocked(before int) error {
+	if !nw.corked && nw.delimiter != nil {
+		if err := nw.flushDelimitedLocked(before); err != nil {
+			return err
+		}
+		if nw.pendingLocked() == 0 {
+			return nil
+		}
+	}
+
+	if !nw.corked && nw.sizeFlushDueLocked(nw.buffer.Len()-before) {
+		return nw.autoFlushLocked(FlushTriggerSize)
+	}
+
+	if !nw.corked && nw.maxWrites > 0 && nw.pendingWrites >= nw.maxWrites {
+		return nw.autoFlushLocked(FlushTriggerCount)
+	}
+
+	if flushed, err := nw.gatedFlushLocked(); flushed {
+		return err
+	}
+
+	nw.observeWriteLocked()
+	nw.armTimerLocked(nw.flushDelayLocked())
+	return nil
+}
+
+// flushDelayLocked returns how long the data buffered by a write may wait: until the
+// deadline of the Strategy or for the flush timeout spread by WithFlushJitter,
+// shortened by WithSoftBufferSize and WithLatencyBudget.
+func (nw *NagleWriter) flushDelayLocked() time.Duration {
+	delay := nw.jitterLocked(nw.currentFlushTimeoutLocked())
+	if nw.strategy != nil {
+		if deadline := nw.strategy.NextDeadline(); !deadline.IsZero() {
+			delay = max(deadline.Sub(nw.clock.Now()), 0)
+		}
+	}
+	if nw.softSize > 0 && nw.buffer.Len() >= nw.softSize {
+		delay = min(delay, nw.softDelay)
+	}
+	return nw.budgetDelayLocked(delay)
+}
+
+// appendLocked adds data to the buffer and updates the buffering counters.
+func (nw *NagleWriter) appendLocked(data []byte) {
+	first := nw.buffer.Len() == 0
+	if nw.tracksWrites() {
+		nw.recordWriteLocked(len(data))
+	}
+	if b, ok := nw.buffer.(*segmentBuffer); ok && nw.ownedWrite && nw.messagePrefix == FramePrefixNone {
+		b.writeOwned(data)
+		if nw.strict {
+			nw.recordOwnedLocked(data)
+		}
+	} else {
+		nw.buffer.Write(data)
+	}
+	nw.appendedLocked(data, first)
+}
+
+// appendedLocked accounts for data, which has just been appended to the buffer, and
+// which started it if first.
+func (nw *NagleWriter) appendedLocked(data []byte, first bool) {
+	if first {
+		nw.oldest = nw.clock.Now()
+	}
+	if nw.journal != nil {
+		nw.appendJournalLocked(data)
+	}
+	nw.stats.BytesWritten += int64(len(data))
+	if nw.buffer.Len() > nw.stats.MaxBuffered {
+		nw.stats.MaxBuffered = nw.buffer.Len()
+	}
 }
 
-// Read reads data from the underlying stream.
+// Read reads data from the underlying stream, through the read-ahead buffer when one is configured.
+// It is bounded by the deadline set with SetReadDeadline, if any, and flushes the buffer
+// once data arrives under WithFlushOnRead. Under WithReconnect a read that fails with a
+// connection error is made again on a new stream.
 func (nw *NagleWrapper) Read(p []byte) (int, error) {
-	return nw.rwc.Read(p)
+	if nw.strict && nw.beginRead() {
+		defer nw.endRead()
+	}
+	var n int
+	var err error
+	for {
+		src := nw.source.Load()
+		if r := nw.deadlines.Load(); r != nil {
+			n, err = r.Read(p)
+		} else if src.reader != nil {
+			n, err = src.reader.Read(p)
+		} else {
+			n, err = src.rwc.Read(p)
+		}
+		if n > 0 || !nw.reconnectRead(src, err) {
+			break
+		}
+	}
+	if n > 0 && nw.flushOnRead {
+		nw.piggybackFlush()
+	}
+	return n, nw.checkFatal(err)
+}
+
+// Flush writes any buffered data to the underlying stream immediately and disarms the flush timer.
+func (nw *NagleWriter) Flush() error {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed {
+		return ErrClosed
+	}
+
+	if err := nw.takeAsyncErrLocked(); err != nil {
+		return err
+	}
+
+	_, err := nw.flushLocked(FlushTriggerExplicit)
+	return err
+}
+
+// FlushContext is like Flush but gives up when ctx is canceled or its deadline passes,
+// both while waiting for the wrapper lock and during the write, returning ctx.Err() and
+// leaving the unwritten bytes buffered. A write in progress is interrupted by setting
+// a write deadline in the past on streams with a SetWriteDeadline method, as net.Conn
+// has, and cleared afterwards, which also clears any deadline set by the caller. Other
+// streams are closed by a watchdog, like under WithWriteDeadline; without an io.Closer
+// there is nothing to interrupt the write, so it runs to completion.
+func (nw *NagleWriter) FlushContext(ctx context.Context) error {
+	if err := nw.mutex.LockContext(ctx); err != nil {
+		return err
+	}
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed {
+		return ErrClosed
+	}
+
+	if err := nw.takeAsyncErrLocked(); err != nil {
+		return err
+	}
+
+	interrupted := nw.interruptOnDoneLocked(ctx)
+	_, err := nw.flushLocked(FlushTriggerExplicit)
+	if interrupted() && err != nil {
+		return ctx.Err()
+	}
+	return err
 }
 
 // Close closes the wrapper, flushing any remaining data.
-func (nw *NagleWrapper) Close() error {
-	defer nw.wg.Wait()
+func (nw *NagleWriter) Close() error {
+	if nw.reconnect != nil {
+		// A reconnect holding the lock gives up first.
+		nw.reconnect.cancel()
+	}
 	nw.mutex.Lock()
-	defer nw.mutex.Unlock()
+	if nw.onClose != nil {
+		// Deferred first so it runs after the lock is released.
+		defer nw.onClose(nw)
+	}
+	defer nw.unlock()
 
+	nw.waitLeaderLocked()
 	if nw.closed {
-		return io.ErrClosedPipe
+		return ErrClosed
 	}
 
-	nw.flushLocked()
+	nw.resumeLocked()
+	err := nw.takeAsyncErrLocked()
+	if _, flushErr := nw.flushLocked(FlushTriggerClose); err == nil {
+		err = flushErr
+	}
 
+	if nw.logger != nil {
+		level := slog.LevelDebug
+		if err != nil {
+			level = slog.LevelWarn
+		}
+		nw.log(level, "nagle: closed", "bytes", nw.stats.BytesFlushed, "unsent", nw.pendingLocked(), "error", err)
+	}
 	nw.closed = true
-	// Wake up the flush goroutine
-	if !nw.timer.Stop() {
-		select {
-		case <-nw.timer.C:
-		default:
+	nw.fail(ErrClosed)
+	if nw.journal != nil {
+		if journalErr := nw.closeJournalLocked(); err == nil {
+			err = journalErr
 		}
 	}
-	nw.timer.Reset(0)
-	return nw.rwc.Close()
+	// Whatever could not be flushed can never be sent now
+	releaseFlushBuffer(nw.buffer)
+	nw.buffer = nil
+	if nw.flushing != nil {
+		releaseFlushBuffer(nw.flushing)
+		nw.flushing = nil
+	}
+	nw.disarmTimerLocked()
+	nw.stopKeepAliveLocked()
+	nw.stopAsyncFlushLocked()
+	nw.leaveSignalsLocked()
+	nw.closeProducersLocked()
+	if closeErr := nw.closeStream(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
-func (nw *NagleWrapper) handleFlush() {
-	defer nw.wg.Done()
-	for {
-		<-nw.timer.C
+// CloseContext is like Close but bounds the final flush by ctx. If ctx is done first,
+// the underlying stream is closed anyway, which discards any unflushed data, and
+// ErrFlushTimeout is returned. Without an underlying io.Closer there is nothing to
+// unblock the flush, so the final flush keeps running in the background.
+func (nw *NagleWriter) CloseContext(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- nw.Close()
+	}()
 
-		nw.mutex.Lock()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// Closing the underlying stream unblocks the stuck flush so Close can finish.
+		nw.closeStream()
+		return ErrFlushTimeout
+	}
+}
 
-		if nw.closed {
-			nw.mutex.Unlock()
-			return
-		}
+// closeStream closes the underlying stream unless it has already been closed. It takes
+// closeMutex instead of the wrapper lock, which a stuck flush may be holding.
+func (nw *NagleWriter) closeStream() error {
+	nw.closeMutex.Lock()
+	closer, closed := nw.closer, nw.streamClosed
+	nw.streamClosed = true
+	nw.closeMutex.Unlock()
 
-		if nw.buffer.Len() > 0 {
-			nw.flushLocked()
+	if closer == nil || closed {
+		return nil
+	}
+	return closer.Close()
+}
+
+// setCloserLocked makes c the closer of the underlying stream, which is yet to be closed.
+func (nw *NagleWriter) setCloserLocked(c io.Closer) {
+	nw.closeMutex.Lock()
+	defer nw.closeMutex.Unlock()
+
+	nw.closer = c
+	nw.streamClosed = false
+}
+
+// CloseWithTimeout is like CloseContext with a deadline d from now.
+func (nw *NagleWriter) CloseWithTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return nw.CloseContext(ctx)
+}
+
+// handleFlush runs when the flush timer fires. The timer is only armed while data is
+// buffered, so idle and closed wrappers have no goroutine or pending wakeup.
+func (nw *NagleWriter) handleFlush() {
+	nw.mutex.Lock()
+
+	nw.armed = false
+	// Moving staged writes into the buffer pushes the deadline back, but they are as old
+	// as the deadline that fired.
+	due := nw.flushAt
+	nw.drainProducersLocked()
+	nw.flushAt = due
+	if nw.closed || nw.corked || nw.pendingLocked() == 0 {
+		nw.unlock()
+		return
+	}
+
+	// A write may have pushed the deadline back after this run was scheduled.
+	if wait := nw.flushAt.Sub(nw.clock.Now()); wait > 0 {
+		nw.resetTimerLocked(wait)
+		nw.unlock()
+		return
+	}
+
+	err := nw.autoFlushLocked(FlushTriggerTimeout)
+	if err != nil {
+		nw.asyncErr = err
+		if errors.Is(err, io.ErrShortWrite) {
+			// The writer made progress, so retry the rest after another timeout.
+			nw.armTimerLocked(nw.currentFlushTimeoutLocked())
 		}
-		nw.mutex.Unlock()
+	}
+	onError := nw.onError
+	nw.unlock()
+
+	if err != nil && onError != nil {
+		onError(err)
 	}
 }
 
-func (nw *NagleWrapper) flushLocked() (int, error) {
-	if nw.buffer.Len() == 0 {
+// armTimerLocked schedules a timeout flush d from now, replacing any earlier schedule.
+// The timer is only reset when it would fire too late: when it fires early, as most
+// writes push the deadline back, handleFlush sleeps again for the rest instead. The
+// timer is created on first use.
+func (nw *NagleWriter) armTimerLocked(d time.Duration) {
+	nw.flushAt = nw.clock.Now().Add(d)
+	if nw.armed && !nw.flushAt.Before(nw.firesAt) {
+		return
+	}
+	nw.resetTimerLocked(d)
+}
+
+// resetTimerLocked makes the timer fire d from now.
+func (nw *NagleWriter) resetTimerLocked(d time.Duration) {
+	nw.armed = true
+	nw.firesAt = nw.clock.Now().Add(d)
+	if nw.timer == nil {
+		nw.timer = nw.scheduler.AfterFunc(d, nw.handleFlush)
+		return
+	}
+	nw.timer.Reset(d)
+}
+
+// disarmTimerLocked cancels any scheduled timeout flush.
+func (nw *NagleWriter) disarmTimerLocked() {
+	if nw.timer != nil && nw.armed {
+		nw.armed = false
+		nw.timer.Stop()
+	}
+}
+
+// takeAsyncErrLocked returns and clears the last error produced by a background flush.
+func (nw *NagleWriter) takeAsyncErrLocked() error {
+	err := nw.asyncErr
+	nw.asyncErr = nil
+	if err == nil && nw.async != nil {
+		err = nw.async.takeErr()
+	}
+	return err
+}
+
+// flushLocked writes the buffer to the underlying writer. Bytes that are not accepted,
+// because of an error or a short write reported as io.ErrShortWrite, stay buffered
+// and are retried by the next flush. Errors are wrapped in ErrFlushFailed.
+func (nw *NagleWriter) flushLocked(trigger FlushTrigger) (int, error) {
+	if nw.leaderDone != nil {
+		// The write in progress sends the buffer when it returns.
 		return 0, nil
 	}
+	nw.drainProducersLocked()
+	nw.expireLocked()
+	if nw.pendingLocked() == 0 && !nw.paused {
+		return 0, nw.flushFramesLocked()
+	}
+	if nw.pendingLocked() == 0 || nw.postponeFlushLocked(trigger) {
+		return 0, nil
+	}
+	if nw.strict {
+		nw.checkFlushLocked()
+	}
+	if nw.async != nil {
+		return nw.enqueueFlushLocked(trigger)
+	}
+	if nw.transform != nil {
+		n, err := nw.flushTransformedLocked(trigger)
+		nw.markFlushedLocked(n)
+		return n, flushFailed(err)
+	}
 
-	n, err := nw.buffer.WriteTo(nw.rwc)
-	if err != nil {
-		return int(n), err
+	var batch []byte
+	if nw.flushHook != nil {
+		batch = nw.buffer.Bytes()
 	}
+	start := nw.flushStartLocked()
+	queued := nw.queuedLocked()
+	n, err := nw.buffer.WriteTo(nw.w)
+	nw.checkFatal(err)
+	nw.markFlushedLocked(int(n))
+	nw.stats.record(trigger, n, queued)
+	nw.queueFlushEventLocked(trigger, batch, int(n), start, err)
+	// After a partial flush the buffer no longer starts at a write boundary.
+	nw.partial = nw.buffer.Len() > 0 && (n > 0 || nw.partial)
+	if nw.buffer.Len() == 0 {
+		nw.pendingWrites = 0
+		nw.disarmTimerLocked()
+	}
+	return int(n), flushFailed(err)
+}
 
-	return int(n), nil
+// ctxMutex is a mutex whose acquisition can be abandoned when a context is done.
+type ctxMutex chan struct{}
+
+func newCtxMutex() ctxMutex {
+	return make(ctxMutex, 1)
+}
+
+func (m ctxMutex) Lock() {
+	m <- struct{}{}
+}
+
+func (m ctxMutex) Unlock() {
+	<-m
+}
+
+// TryLock acquires the mutex only if it is free, and reports whether it did.
+func (m ctxMutex) TryLock() bool {
+	select {
+	case m <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m ctxMutex) LockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case m <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -2,19 +2,29 @@ package nagle
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
 
 // MockReadWriteCloser mocks an io.ReadWriteCloser for testing purposes.
+// It is safe for concurrent use, so timeout flushes can be checked under -race.
 type MockReadWriteCloser struct {
+	mutex  sync.Mutex
 	buffer bytes.Buffer
 	closed bool
 }
 
 func (m *MockReadWriteCloser) Write(p []byte) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	if m.closed {
 		return 0, io.ErrClosedPipe
 	}
@@ -22,6 +32,9 @@ func (m *MockReadWriteCloser) Write(p []byte) (int, error) {
 }
 
 func (m *MockReadWriteCloser) Read(p []byte) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	if m.closed {
 		return 0, io.ErrClosedPipe
 	}
@@ -29,6 +42,9 @@ func (m *MockReadWriteCloser) Read(p []byte) (int, error) {
 }
 
 func (m *MockReadWriteCloser) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	if m.closed {
 		return io.ErrClosedPipe
 	}
@@ -36,6 +52,22 @@ func (m *MockReadWriteCloser) Close() error {
 	return nil
 }
 
+// String returns the data written so far and not read back.
+func (m *MockReadWriteCloser) String() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.buffer.String()
+}
+
+// Reset discards the data written so far.
+func (m *MockReadWriteCloser) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.buffer.Reset()
+}
+
 func TestNagleWrapper_WriteFlushByBufferSize(t *testing.T) {
 	mockRWC := &MockReadWriteCloser{}
 	nagleWrapper := NewNagleWrapper(mockRWC, 10, 50*time.Millisecond)
@@ -51,8 +83,8 @@ func TestNagleWrapper_WriteFlushByBufferSize(t *testing.T) {
 	}
 
 	// Check if buffer is flushed
-	if mockRWC.buffer.String() != "0123456789" {
-		t.Fatalf("expected buffer to contain '0123456789', but got: %s", mockRWC.buffer.String())
+	if mockRWC.String() != "0123456789" {
+		t.Fatalf("expected buffer to contain '0123456789', but got: %s", mockRWC.String())
 	}
 }
 
@@ -71,18 +103,18 @@ func TestNagleWrapper_WriteFlushByTimeout(t *testing.T) {
 		}
 
 		// Buffer should not be flushed yet
-		if mockRWC.buffer.String() != "" {
-			t.Fatalf("expected buffer to be empty, but got: %s", mockRWC.buffer.String())
+		if mockRWC.String() != "" {
+			t.Fatalf("expected buffer to be empty, but got: %s", mockRWC.String())
 		}
 
 		// Wait for flush timeout
 		time.Sleep(100 * time.Millisecond)
 
 		// Buffer should be flushed now
-		if mockRWC.buffer.String() != "01234" {
-			t.Fatalf("expected buffer to contain '01234', but got: %s", mockRWC.buffer.String())
+		if mockRWC.String() != "01234" {
+			t.Fatalf("expected buffer to contain '01234', but got: %s", mockRWC.String())
 		}
-		mockRWC.buffer.Reset()
+		mockRWC.Reset()
 	}
 }
 
@@ -107,14 +139,18 @@ func TestNagleWrapper_CloseFlushesData(t *testing.T) {
 	}
 
 	// Check if buffer was flushed
-	if mockRWC.buffer.String() != "01234" {
-		t.Fatalf("expected buffer to contain '01234', but got: %s", mockRWC.buffer.String())
+	if mockRWC.String() != "01234" {
+		t.Fatalf("expected buffer to contain '01234', but got: %s", mockRWC.String())
 	}
 
 	// Further writes should fail after close
 	_, err = nagleWrapper.Write([]byte("more data"))
-	if !errors.Is(err, io.ErrClosedPipe) {
-		t.Fatalf("expected ErrClosedPipe, but got: %v", err)
+	if !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+	// ErrClosed still matches the error of a closed stream
+	if !errors.Is(err, io.ErrClosedPipe) || !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected ErrClosed to match io.ErrClosedPipe and net.ErrClosed")
 	}
 
 	// Further reads should fail after close
@@ -126,8 +162,8 @@ func TestNagleWrapper_CloseFlushesData(t *testing.T) {
 
 	// Further closes should fail after close
 	err = nagleWrapper.Close()
-	if !errors.Is(err, io.ErrClosedPipe) {
-		t.Fatalf("expected ErrClosedPipe, but got: %v", err)
+	if !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
 	}
 }
 
@@ -152,3 +188,460 @@ func TestNagleWrapper_Read(t *testing.T) {
 		t.Fatalf("expected to read '%s', but got: '%s'", expected, string(buf[:n]))
 	}
 }
+
+func TestNagleWrapper_Flush(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := NewNagleWrapper(mockRWC, 10, time.Hour)
+
+	// Write 5 bytes (less than buffer size)
+	if _, err := nagleWrapper.Write([]byte("01234")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "" {
+		t.Fatalf("expected buffer to be empty, but got: %s", mockRWC.String())
+	}
+
+	// Flush should send pending data without waiting for the timeout
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error on flush: %v", err)
+	}
+	if mockRWC.String() != "01234" {
+		t.Fatalf("expected buffer to contain '01234', but got: %s", mockRWC.String())
+	}
+
+	// Flushing an empty buffer is a no-op
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error on empty flush: %v", err)
+	}
+
+	nagleWrapper.Close()
+
+	// Flush after close should fail
+	if err := nagleWrapper.Flush(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+}
+
+// FailingReadWriteCloser is a mock whose writes always fail.
+type FailingReadWriteCloser struct {
+	MockReadWriteCloser
+	err error
+}
+
+func (m *FailingReadWriteCloser) Write(p []byte) (int, error) {
+	return 0, m.err
+}
+
+func TestNagleWrapper_AsyncFlushError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	mockRWC := &FailingReadWriteCloser{err: writeErr}
+	reported := make(chan error, 1)
+	nagleWrapper := New(mockRWC, WithBufferSize(10), WithFlushTimeout(10*time.Millisecond), WithOnError(func(err error) {
+		reported <- err
+	}))
+
+	if _, err := nagleWrapper.Write([]byte("01234")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case err := <-reported:
+		if !errors.Is(err, writeErr) {
+			t.Fatalf("expected callback error %v, but got: %v", writeErr, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error callback was not invoked")
+	}
+
+	// The stored error surfaces on the next write, marked as a flush failure
+	if _, err := nagleWrapper.Write([]byte("5")); !errors.Is(err, writeErr) || !errors.Is(err, ErrFlushFailed) {
+		t.Fatalf("expected %v wrapped in ErrFlushFailed, but got: %v", writeErr, err)
+	}
+
+	// Close reports the failure of the final flush
+	if err := nagleWrapper.Close(); !errors.Is(err, writeErr) {
+		t.Fatalf("expected %v, but got: %v", writeErr, err)
+	}
+}
+
+// BlockingReadWriteCloser is a mock whose writes block until release is closed.
+type BlockingReadWriteCloser struct {
+	MockReadWriteCloser
+	started chan struct{}
+	release chan struct{}
+}
+
+func NewBlockingReadWriteCloser() *BlockingReadWriteCloser {
+	return &BlockingReadWriteCloser{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+}
+
+func (m *BlockingReadWriteCloser) Write(p []byte) (int, error) {
+	select {
+	case m.started <- struct{}{}:
+	default:
+	}
+	<-m.release
+	return len(p), nil
+}
+
+func TestNagleWrapper_WriteContextCanceled(t *testing.T) {
+	mockRWC := NewBlockingReadWriteCloser()
+	nagleWrapper := New(mockRWC, WithBufferSize(1), WithFlushTimeout(time.Hour))
+
+	// The first write triggers a flush that blocks inside the underlying writer
+	go nagleWrapper.Write([]byte("0"))
+	<-mockRWC.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	n, err := nagleWrapper.WriteContext(ctx, []byte("1"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, but got: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("expected to write 0 bytes, wrote %d", n)
+	}
+
+	close(mockRWC.release)
+	n, err = nagleWrapper.WriteContext(context.Background(), []byte("2"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected to write 1 byte, wrote %d", n)
+	}
+	nagleWrapper.Close()
+}
+
+// CountingReadWriteCloser is a mock that counts calls to Read.
+type CountingReadWriteCloser struct {
+	MockReadWriteCloser
+	reads int
+}
+
+func (m *CountingReadWriteCloser) Read(p []byte) (int, error) {
+	m.reads++
+	return m.MockReadWriteCloser.Read(p)
+}
+
+func TestNagleWrapper_ReadBuffer(t *testing.T) {
+	mockRWC := &CountingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithReadBuffer(64))
+	defer nagleWrapper.Close()
+
+	mockRWC.buffer.WriteString("readable data")
+
+	// Small reads are served from the read-ahead buffer
+	buf := make([]byte, 4)
+	var got []byte
+	for i := 0; i < 3; i++ {
+		n, err := nagleWrapper.Read(buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "readable dat" {
+		t.Fatalf("expected to read 'readable dat', but got: '%s'", string(got))
+	}
+	if mockRWC.reads != 1 {
+		t.Fatalf("expected 1 underlying read, got %d", mockRWC.reads)
+	}
+}
+
+func TestNagleWrapper_WriteAndFlush(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01"))
+	n, err := nagleWrapper.WriteAndFlush([]byte("234"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected to write 3 bytes, wrote %d", n)
+	}
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "01234" {
+		t.Fatalf("expected a single write of '01234', got %q", mockRWC.writes)
+	}
+}
+
+func TestNagleWrapper_WriteNoDelay(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	// With an empty buffer the data goes straight to the underlying stream
+	if _, err := nagleWrapper.WriteNoDelay([]byte("ping")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "ping" {
+		t.Fatalf("expected a single write of 'ping', got %q", mockRWC.writes)
+	}
+
+	// With pending data it is sent after the buffered bytes
+	nagleWrapper.Write([]byte("data"))
+	if _, err := nagleWrapper.WriteNoDelay([]byte("ping")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockRWC.writes) != 2 || mockRWC.writes[1] != "dataping" {
+		t.Fatalf("expected second write of 'dataping', got %q", mockRWC.writes)
+	}
+
+	if stats := nagleWrapper.Stats(); stats.DirectWrites != 1 {
+		t.Fatalf("expected 1 direct write, got %d", stats.DirectWrites)
+	}
+}
+
+func TestNagleWrapper_NoIdleGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	wrappers := make([]*NagleWrapper, 100)
+	for i := range wrappers {
+		wrappers[i] = NewNagleWrapper(&MockReadWriteCloser{}, 10, time.Hour)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expected idle wrappers to start no goroutines, got %d more", after-before)
+	}
+
+	for _, w := range wrappers {
+		w.Write([]byte("0"))
+		w.Close()
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expected closed wrappers to leave no goroutines, got %d more", after-before)
+	}
+}
+
+func TestNagleWrapper_WriteReturnsLength(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(10), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123456"))
+
+	// This write triggers a flush of 10 bytes but must only report its own length
+	n, err := nagleWrapper.Write([]byte("789"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected to write 3 bytes, wrote %d", n)
+	}
+}
+
+func TestNagleWrapper_MultiWriter(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour))
+	var copyBuf bytes.Buffer
+
+	w := io.MultiWriter(nagleWrapper, &copyBuf)
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("abc")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	nagleWrapper.Close()
+
+	if mockRWC.String() != copyBuf.String() {
+		t.Fatalf("expected '%s', but got: '%s'", copyBuf.String(), mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_IoCopy(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(16), WithFlushTimeout(time.Hour))
+
+	src := bytes.Repeat([]byte("0123456789"), 100)
+	n, err := io.Copy(nagleWrapper, bytes.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != int64(len(src)) {
+		t.Fatalf("expected to copy %d bytes, copied %d", len(src), n)
+	}
+	nagleWrapper.Close()
+
+	if mockRWC.String() != string(src) {
+		t.Fatalf("copied data does not match source")
+	}
+}
+
+func TestNagleWrapper_Fprintf(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(8), WithFlushTimeout(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		n, err := fmt.Fprintf(nagleWrapper, "line %d\n", i)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 7 {
+			t.Fatalf("expected to write 7 bytes, wrote %d", n)
+		}
+	}
+	nagleWrapper.Close()
+
+	expected := "line 0\nline 1\nline 2\n"
+	if mockRWC.String() != expected {
+		t.Fatalf("expected '%s', but got: '%s'", expected, mockRWC.String())
+	}
+}
+
+// ShortWriteReadWriteCloser is a mock that accepts at most limit bytes per Write without error.
+type ShortWriteReadWriteCloser struct {
+	MockReadWriteCloser
+	limit int
+}
+
+func (m *ShortWriteReadWriteCloser) Write(p []byte) (int, error) {
+	if len(p) > m.limit {
+		p = p[:m.limit]
+	}
+	return m.MockReadWriteCloser.Write(p)
+}
+
+func TestNagleWrapper_ShortWrite(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 3}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123456"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected ErrShortWrite, but got: %v", err)
+	}
+	if mockRWC.String() != "012" {
+		t.Fatalf("expected buffer to contain '012', but got: %s", mockRWC.String())
+	}
+
+	// Unflushed bytes are retained and retried by the next flush
+	if stats := nagleWrapper.Stats(); stats.Buffered != 4 {
+		t.Fatalf("expected 4 bytes buffered, got %d", stats.Buffered)
+	}
+	nagleWrapper.Flush()
+	nagleWrapper.Flush()
+	if mockRWC.String() != "0123456" {
+		t.Fatalf("expected buffer to contain '0123456', but got: %s", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_ShortWriteTimer(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 3}
+	reported := make(chan error, 10)
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(10*time.Millisecond), WithOnError(func(err error) {
+		reported <- err
+	}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123456"))
+	select {
+	case err := <-reported:
+		if !errors.Is(err, io.ErrShortWrite) {
+			t.Fatalf("expected ErrShortWrite, but got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error callback was not invoked")
+	}
+
+	// The timer keeps retrying until the buffer drains
+	time.Sleep(100 * time.Millisecond)
+	if stats := nagleWrapper.Stats(); stats.Buffered != 0 || stats.BytesFlushed != 7 {
+		t.Fatalf("expected buffer to drain, got %+v", stats)
+	}
+}
+
+func TestNagleWrapper_WriteUrgent(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("bulk"))
+	if _, err := nagleWrapper.WriteUrgent([]byte("ping")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Urgent data goes out alone while the bulk data keeps coalescing
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "ping" {
+		t.Fatalf("expected a single write of 'ping', got %q", mockRWC.writes)
+	}
+	nagleWrapper.Write([]byte("more"))
+	nagleWrapper.Flush()
+	if len(mockRWC.writes) != 2 || mockRWC.writes[1] != "bulkmore" {
+		t.Fatalf("expected second write of 'bulkmore', got %q", mockRWC.writes)
+	}
+	if stats := nagleWrapper.Stats(); stats.UrgentWrites != 1 {
+		t.Fatalf("expected 1 urgent write, got %d", stats.UrgentWrites)
+	}
+}
+
+func TestNagleWrapper_WriteUrgentAfterPartialFlush(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 3}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01234"))
+	nagleWrapper.Flush()
+
+	// The partially sent write is completed before the urgent data
+	mockRWC.limit = 100
+	nagleWrapper.WriteUrgent([]byte("!"))
+	if mockRWC.String() != "01234!" {
+		t.Fatalf("expected buffer to contain '01234!', but got: %s", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_WriteBatch(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	// Triggers are evaluated once, after all slices are buffered
+	n, err := nagleWrapper.WriteBatch([][]byte{[]byte("01"), []byte("23"), []byte("45")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 6 {
+		t.Fatalf("expected 6 bytes written, but got: %d", n)
+	}
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "012345" {
+		t.Fatalf("expected a single write of '012345', but got: %q", mockRWC.writes)
+	}
+	if stats := nagleWrapper.Stats(); stats.Writes != 3 {
+		t.Fatalf("expected 3 writes counted, but got: %d", stats.Writes)
+	}
+
+	// Below the threshold the batch waits for the timer like a Write
+	nagleWrapper.WriteBatch([][]byte{[]byte("6"), []byte("7")})
+	if len(mockRWC.writes) != 1 {
+		t.Fatalf("expected the batch to stay buffered, but got: %q", mockRWC.writes)
+	}
+	nagleWrapper.Flush()
+	if len(mockRWC.writes) != 2 || mockRWC.writes[1] != "67" {
+		t.Fatalf("expected a second write of '67', but got: %q", mockRWC.writes)
+	}
+}
+
+func TestNagleWrapper_WriteStringAndByte(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	if n, err := nagleWrapper.WriteString("012"); err != nil || n != 3 {
+		t.Fatalf("expected 3 bytes written, but got: %d (%v)", n, err)
+	}
+	if len(mockRWC.String()) != 0 {
+		t.Fatalf("expected data to be buffered, but got: %s", mockRWC.String())
+	}
+
+	// The byte completes the buffer and triggers a size flush
+	if err := nagleWrapper.WriteByte('3'); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "0123" {
+		t.Fatalf("expected buffer to contain '0123', but got: %s", mockRWC.String())
+	}
+}
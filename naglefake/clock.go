@@ -0,0 +1,155 @@
+// Package naglefake provides a fake nagle.Clock that only moves when told to,
+// so code using nagle wrappers can be tested without real sleeps.
+package naglefake
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jaracil/nagle"
+)
+
+// Clock is a nagle.Clock whose time only advances when Advance or Set is called.
+type Clock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*Timer
+}
+
+var _ nagle.Clock = (*Clock)(nil)
+
+// NewClock creates a fake clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current fake time.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// NewTimer creates a timer that delivers the fake time on its channel once the clock reaches now+d.
+func (c *Clock) NewTimer(d time.Duration) nagle.Timer {
+	t := &Timer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc creates a timer that calls f once the clock reaches now+d.
+func (c *Clock) AfterFunc(d time.Duration, f func()) nagle.Timer {
+	t := &Timer{clock: c, fn: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer that expires on the way in order.
+// AfterFunc callbacks run synchronously on the calling goroutine, without the clock lock held.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t, firing every timer that expires on the way in order.
+func (c *Clock) Set(t time.Time) {
+	for {
+		c.mutex.Lock()
+		timer := c.nextExpiredLocked(t)
+		if timer == nil {
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.mutex.Unlock()
+			return
+		}
+		c.now = timer.when
+		c.removeLocked(timer)
+		now := c.now
+		c.mutex.Unlock()
+
+		timer.fire(now)
+	}
+}
+
+// Timers returns the number of timers waiting to fire.
+func (c *Clock) Timers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.timers)
+}
+
+func (c *Clock) nextExpiredLocked(t time.Time) *Timer {
+	if len(c.timers) == 0 || c.timers[0].when.After(t) {
+		return nil
+	}
+	return c.timers[0]
+}
+
+func (c *Clock) addLocked(t *Timer) {
+	c.timers = append(c.timers, t)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+}
+
+func (c *Clock) removeLocked(t *Timer) bool {
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Timer is a timer driven by a fake Clock.
+type Timer struct {
+	clock *Clock
+	when  time.Time
+	ch    chan time.Time
+	fn    func()
+}
+
+var _ nagle.Timer = (*Timer)(nil)
+
+// C returns the channel the timer delivers on, or nil for AfterFunc timers.
+func (t *Timer) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop prevents the timer from firing and reports whether it was active.
+func (t *Timer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	return t.clock.removeLocked(t)
+}
+
+// Reset rearms the timer to fire d after the current fake time and reports whether it was active.
+// A non-positive d fires the timer on the next Advance or Set, even by zero.
+func (t *Timer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	active := t.clock.removeLocked(t)
+	if d < 0 {
+		d = 0
+	}
+	t.when = t.clock.now.Add(d)
+	t.clock.addLocked(t)
+	return active
+}
+
+func (t *Timer) fire(now time.Time) {
+	if t.fn != nil {
+		t.fn()
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
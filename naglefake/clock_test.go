@@ -0,0 +1,57 @@
+package naglefake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock_AfterFunc(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewClock(start)
+
+	var fired []int
+	clock.AfterFunc(20*time.Millisecond, func() { fired = append(fired, 2) })
+	clock.AfterFunc(10*time.Millisecond, func() { fired = append(fired, 1) })
+	stopped := clock.AfterFunc(15*time.Millisecond, func() { fired = append(fired, 0) })
+	if !stopped.Stop() {
+		t.Fatal("expected Stop to report an active timer")
+	}
+
+	clock.Advance(5 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Fatalf("expected no timers to fire, got %v", fired)
+	}
+
+	clock.Advance(20 * time.Millisecond)
+	if len(fired) != 2 || fired[0] != 1 || fired[1] != 2 {
+		t.Fatalf("expected timers to fire in order [1 2], got %v", fired)
+	}
+	if got := clock.Now(); !got.Equal(start.Add(25 * time.Millisecond)) {
+		t.Fatalf("expected clock at 25ms, got %v", got.Sub(start))
+	}
+	if clock.Timers() != 0 {
+		t.Fatalf("expected no pending timers, got %d", clock.Timers())
+	}
+}
+
+func TestClock_NewTimer(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	timer := clock.NewTimer(10 * time.Millisecond)
+
+	clock.Advance(10 * time.Millisecond)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("expected timer to fire")
+	}
+
+	if timer.Reset(time.Millisecond) {
+		t.Fatal("expected Reset to report an expired timer")
+	}
+	clock.Advance(time.Millisecond)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("expected timer to fire after reset")
+	}
+}
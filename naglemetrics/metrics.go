@@ -0,0 +1,235 @@
+// Package naglemetrics exports the metrics of nagle wrappers through expvar and
+// as a Prometheus collector. It lives in its own module so the nagle package
+// itself stays free of third-party dependencies.
+package naglemetrics
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+
+	"github.com/jaracil/nagle"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the flush latency histogram.
+var DefaultLatencyBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1}
+
+// StatsSource is a wrapper whose counters can be tracked, such as *nagle.NagleWriter,
+// *nagle.NagleWrapper or *nagle.NagleConn.
+type StatsSource interface {
+	Stats() nagle.Stats
+}
+
+// Histogram is a snapshot of the flush latency distribution.
+type Histogram struct {
+	// Buckets are the upper bounds of the buckets, in seconds.
+	Buckets []float64 `json:"buckets"`
+	// Counts are the cumulative number of flushes at or below each bucket.
+	Counts []uint64 `json:"counts"`
+	// Sum is the total flush time in seconds.
+	Sum float64 `json:"sum"`
+	// Count is the number of flushes observed.
+	Count uint64 `json:"count"`
+}
+
+// Snapshot holds the aggregated metrics of a Collector.
+type Snapshot struct {
+	// Flushes counts flushes by trigger name.
+	Flushes map[string]uint64 `json:"flushes"`
+	// FlushErrors counts flushes that failed.
+	FlushErrors uint64 `json:"flush_errors"`
+	// BytesIn is the number of bytes accepted by the tracked wrappers.
+	BytesIn int64 `json:"bytes_in"`
+	// BytesOut is the number of bytes written by the tracked wrappers to their underlying writers.
+	BytesOut int64 `json:"bytes_out"`
+	// Buffered is the number of bytes currently awaiting flush in the tracked wrappers.
+	Buffered int64 `json:"buffered"`
+	// HighWater is the largest buffer occupancy seen in any tracked wrapper.
+	HighWater int `json:"high_water"`
+	// Latency is the distribution of the time spent in underlying writes.
+	Latency Histogram `json:"latency"`
+}
+
+// Collector aggregates the metrics of many wrappers. Flush counts and latencies come
+// from the flush observer installed by Option; byte counters and buffer occupancy
+// come from the Stats of the wrappers registered with Track.
+type Collector struct {
+	mutex         sync.Mutex
+	flushes       map[nagle.FlushTrigger]uint64
+	flushErrors   uint64
+	buckets       []float64
+	latencyCounts []uint64
+	latencySum    float64
+	latencyCount  uint64
+	sources       map[StatsSource]struct{}
+	retired       nagle.Stats
+
+	flushesDesc     *prometheus.Desc
+	flushErrorsDesc *prometheus.Desc
+	bytesInDesc     *prometheus.Desc
+	bytesOutDesc    *prometheus.Desc
+	bufferedDesc    *prometheus.Desc
+	highWaterDesc   *prometheus.Desc
+	latencyDesc     *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector creates a collector using DefaultLatencyBuckets.
+func NewCollector() *Collector {
+	return NewCollectorWithBuckets(DefaultLatencyBuckets)
+}
+
+// NewCollectorWithBuckets creates a collector with the given latency bucket upper bounds in seconds.
+func NewCollectorWithBuckets(buckets []float64) *Collector {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Collector{
+		flushes:       make(map[nagle.FlushTrigger]uint64),
+		buckets:       buckets,
+		latencyCounts: make([]uint64, len(buckets)),
+		sources:       make(map[StatsSource]struct{}),
+
+		flushesDesc:     prometheus.NewDesc("nagle_flushes_total", "Number of flushes by trigger.", []string{"trigger"}, nil),
+		flushErrorsDesc: prometheus.NewDesc("nagle_flush_errors_total", "Number of failed flushes.", nil, nil),
+		bytesInDesc:     prometheus.NewDesc("nagle_bytes_in_total", "Bytes accepted by wrappers.", nil, nil),
+		bytesOutDesc:    prometheus.NewDesc("nagle_bytes_out_total", "Bytes written by wrappers to their underlying writers.", nil, nil),
+		bufferedDesc:    prometheus.NewDesc("nagle_buffered_bytes", "Bytes currently awaiting flush.", nil, nil),
+		highWaterDesc:   prometheus.NewDesc("nagle_buffer_high_water_bytes", "Largest buffer occupancy seen in any wrapper.", nil, nil),
+		latencyDesc:     prometheus.NewDesc("nagle_flush_duration_seconds", "Time spent in underlying writes by flushes.", nil, nil),
+	}
+}
+
+// Option returns the option that reports a wrapper's flushes to c.
+func (c *Collector) Option() nagle.Option {
+	return nagle.WithFlushObserver(c.observe)
+}
+
+// Track adds the byte counters and buffer occupancy of s to the collector.
+func (c *Collector) Track(s StatsSource) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sources[s] = struct{}{}
+}
+
+// Untrack stops polling s, typically after closing it. Its final counters are kept
+// in the totals so they never go backwards.
+func (c *Collector) Untrack(s StatsSource) {
+	stats := s.Stats()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.sources[s]; !ok {
+		return
+	}
+	delete(c.sources, s)
+	c.retired.BytesWritten += stats.BytesWritten
+	c.retired.BytesFlushed += stats.BytesFlushed
+	if stats.MaxBuffered > c.retired.MaxBuffered {
+		c.retired.MaxBuffered = stats.MaxBuffered
+	}
+}
+
+// Snapshot returns the current aggregated metrics.
+func (c *Collector) Snapshot() Snapshot {
+	c.mutex.Lock()
+	sources := make([]StatsSource, 0, len(c.sources))
+	for s := range c.sources {
+		sources = append(sources, s)
+	}
+	snapshot := Snapshot{
+		Flushes:     make(map[string]uint64, len(c.flushes)),
+		FlushErrors: c.flushErrors,
+		BytesIn:     c.retired.BytesWritten,
+		BytesOut:    c.retired.BytesFlushed,
+		HighWater:   c.retired.MaxBuffered,
+		Latency: Histogram{
+			Buckets: append([]float64(nil), c.buckets...),
+			Counts:  make([]uint64, len(c.buckets)),
+			Sum:     c.latencySum,
+			Count:   c.latencyCount,
+		},
+	}
+	for trigger, n := range c.flushes {
+		snapshot.Flushes[trigger.String()] = n
+	}
+	var cumulative uint64
+	for i, n := range c.latencyCounts {
+		cumulative += n
+		snapshot.Latency.Counts[i] = cumulative
+	}
+	c.mutex.Unlock()
+
+	// Stats takes each wrapper's lock, so it is collected without holding ours.
+	for _, s := range sources {
+		stats := s.Stats()
+		snapshot.BytesIn += stats.BytesWritten
+		snapshot.BytesOut += stats.BytesFlushed
+		snapshot.Buffered += int64(stats.Buffered)
+		if stats.MaxBuffered > snapshot.HighWater {
+			snapshot.HighWater = stats.MaxBuffered
+		}
+	}
+	return snapshot
+}
+
+// Publish exports the collector's snapshot as the expvar variable name.
+// Like expvar.Publish, it panics if name is already in use.
+func (c *Collector) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return c.Snapshot()
+	}))
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.flushesDesc
+	ch <- c.flushErrorsDesc
+	ch <- c.bytesInDesc
+	ch <- c.bytesOutDesc
+	ch <- c.bufferedDesc
+	ch <- c.highWaterDesc
+	ch <- c.latencyDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.Snapshot()
+	for trigger, n := range s.Flushes {
+		ch <- prometheus.MustNewConstMetric(c.flushesDesc, prometheus.CounterValue, float64(n), trigger)
+	}
+	ch <- prometheus.MustNewConstMetric(c.flushErrorsDesc, prometheus.CounterValue, float64(s.FlushErrors))
+	ch <- prometheus.MustNewConstMetric(c.bytesInDesc, prometheus.CounterValue, float64(s.BytesIn))
+	ch <- prometheus.MustNewConstMetric(c.bytesOutDesc, prometheus.CounterValue, float64(s.BytesOut))
+	ch <- prometheus.MustNewConstMetric(c.bufferedDesc, prometheus.GaugeValue, float64(s.Buffered))
+	ch <- prometheus.MustNewConstMetric(c.highWaterDesc, prometheus.GaugeValue, float64(s.HighWater))
+
+	buckets := make(map[float64]uint64, len(s.Latency.Buckets))
+	for i, upper := range s.Latency.Buckets {
+		buckets[upper] = s.Latency.Counts[i]
+	}
+	ch <- prometheus.MustNewConstHistogram(c.latencyDesc, s.Latency.Count, s.Latency.Sum, buckets)
+}
+
+func (c *Collector) observe(info nagle.FlushInfo) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if info.Err != nil {
+		c.flushErrors++
+	}
+	if info.Bytes == 0 {
+		return
+	}
+	c.flushes[info.Trigger]++
+
+	seconds := info.Duration.Seconds()
+	c.latencySum += seconds
+	c.latencyCount++
+	if i := sort.SearchFloat64s(c.buckets, seconds); i < len(c.buckets) {
+		c.latencyCounts[i]++
+	}
+}
@@ -0,0 +1,102 @@
+package naglemetrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestCollector_Snapshot(t *testing.T) {
+	c := NewCollector()
+
+	var out bytes.Buffer
+	w := nagle.NewWriter(&out, nagle.WithBufferSize(4), nagle.WithFlushTimeout(time.Hour), c.Option())
+	c.Track(w)
+
+	w.Write([]byte("01"))
+	w.Write([]byte("23"))
+	w.Write([]byte("45"))
+	w.Flush()
+	w.Write([]byte("6"))
+
+	s := c.Snapshot()
+	if s.Flushes["size"] != 1 || s.Flushes["explicit"] != 1 {
+		t.Fatalf("unexpected flush counts: %v", s.Flushes)
+	}
+	if s.BytesIn != 7 || s.BytesOut != 6 || s.Buffered != 1 || s.HighWater != 4 {
+		t.Fatalf("unexpected byte counters: %+v", s)
+	}
+	if s.Latency.Count != 2 {
+		t.Fatalf("expected 2 latency samples, got %d", s.Latency.Count)
+	}
+
+	// Counters of untracked wrappers are kept
+	w.Close()
+	c.Untrack(w)
+	s = c.Snapshot()
+	if s.BytesIn != 7 || s.BytesOut != 7 || s.Buffered != 0 || s.Flushes["close"] != 1 {
+		t.Fatalf("unexpected counters after untrack: %+v", s)
+	}
+
+	fw := nagle.NewWriter(failingWriter{}, nagle.WithBufferSize(100), c.Option())
+	fw.Write([]byte("x"))
+	fw.Flush()
+	if s := c.Snapshot(); s.FlushErrors != 1 {
+		t.Fatalf("expected 1 flush error, got %d", s.FlushErrors)
+	}
+}
+
+func TestCollector_Prometheus(t *testing.T) {
+	c := NewCollector()
+	var out bytes.Buffer
+	w := nagle.NewWriter(&out, nagle.WithBufferSize(4), nagle.WithFlushTimeout(time.Hour), c.Option())
+	c.Track(w)
+	w.Write([]byte("0123"))
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `
+# HELP nagle_bytes_in_total Bytes accepted by wrappers.
+# TYPE nagle_bytes_in_total counter
+nagle_bytes_in_total 4
+# HELP nagle_flushes_total Number of flushes by trigger.
+# TYPE nagle_flushes_total counter
+nagle_flushes_total{trigger="size"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nagle_bytes_in_total", "nagle_flushes_total"); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(c, "nagle_flush_duration_seconds"); n != 1 {
+		t.Fatalf("expected one latency histogram, got %d", n)
+	}
+}
+
+func TestCollector_Publish(t *testing.T) {
+	c := NewCollector()
+	c.Publish("nagle_test")
+
+	var s Snapshot
+	if err := json.Unmarshal([]byte(expvar.Get("nagle_test").String()), &s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(s.Latency.Buckets) != len(DefaultLatencyBuckets) {
+		t.Fatalf("expected %d buckets, got %d", len(DefaultLatencyBuckets), len(s.Latency.Buckets))
+	}
+}
@@ -0,0 +1,74 @@
+// Package nagleotel records an OpenTelemetry span for every flush of nagle wrappers,
+// so the latency added by coalescing shows up in distributed traces. It lives in its
+// own module so the nagle package itself stays free of third-party dependencies.
+package nagleotel
+
+import (
+	"context"
+
+	"github.com/jaracil/nagle"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer the spans are recorded with.
+const TracerName = "github.com/jaracil/nagle/nagleotel"
+
+// SpanName is the name of the span recorded for every flush.
+const SpanName = "nagle.flush"
+
+// Attribute keys set on every flush span.
+const (
+	// BytesKey is the number of bytes written to the underlying writer.
+	BytesKey = attribute.Key("nagle.flush.bytes")
+	// TriggerKey is the name of what caused the flush, such as "size" or "timeout".
+	TriggerKey = attribute.Key("nagle.flush.trigger")
+	// QueueTimeKey is how long, in seconds, the oldest byte of the flush waited in the buffer.
+	QueueTimeKey = attribute.Key("nagle.flush.queue_time")
+)
+
+// Tracer records flush spans for the wrappers configured with its Option.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a tracer using tp, or the global TracerProvider when tp is nil.
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(TracerName)}
+}
+
+// Option returns the nagle option that makes a wrapper record its flushes. Each span
+// covers the underlying write and is linked to the spans found in the contexts given
+// to WriteContext for the data it carried.
+func (t *Tracer) Option() nagle.Option {
+	return nagle.WithFlushObserver(t.observe)
+}
+
+func (t *Tracer) observe(info nagle.FlushInfo) {
+	var links []trace.Link
+	for _, ctx := range info.Contexts {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+
+	_, span := t.tracer.Start(context.Background(), SpanName,
+		trace.WithTimestamp(info.Start),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			BytesKey.Int(info.Bytes),
+			TriggerKey.String(info.Trigger.String()),
+			QueueTimeKey.Float64(info.Queued.Seconds()),
+		))
+	if info.Err != nil {
+		span.RecordError(info.Err)
+		span.SetStatus(codes.Error, info.Err.Error())
+	}
+	span.End(trace.WithTimestamp(info.Start.Add(info.Duration)))
+}
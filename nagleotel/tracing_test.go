@@ -0,0 +1,76 @@
+package nagleotel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracer_Option(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := NewTracer(tp)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	var out bytes.Buffer
+	w := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour), tracer.Option())
+	w.WriteContext(ctx, []byte("01"))
+	w.Write([]byte("23"))
+	time.Sleep(time.Millisecond)
+	w.Flush()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != SpanName {
+		t.Fatalf("expected a flush span, but got: %v", spans)
+	}
+	span := spans[0]
+	attrs := attributes(span)
+	if attrs[BytesKey].AsInt64() != 4 || attrs[TriggerKey].AsString() != "explicit" {
+		t.Fatalf("unexpected attributes: %v", attrs)
+	}
+	if attrs[QueueTimeKey].AsFloat64() < time.Millisecond.Seconds() {
+		t.Fatalf("expected a queue time of at least 1ms, but got: %v", attrs[QueueTimeKey].AsFloat64())
+	}
+	// The flush is linked to the write that gave its context
+	if links := span.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("expected a link to the parent span, but got: %v", links)
+	}
+	w.Close()
+}
+
+func TestTracer_FlushError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	w := nagle.NewWriter(failingWriter{}, nagle.WithBufferSize(100), tracer.Option())
+	w.Write([]byte("x"))
+	w.Flush()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatalf("expected a span with an error status, but got: %v", spans)
+	}
+}
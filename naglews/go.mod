@@ -0,0 +1,10 @@
+module github.com/jaracil/nagle/naglews
+
+go 1.23.0
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/jaracil/nagle v0.0.0
+)
+
+replace github.com/jaracil/nagle => ../
@@ -0,0 +1,115 @@
+// Package naglews coalesces many small application messages sent over a gorilla/websocket
+// connection into fewer binary frames. Each message is prefixed with its length as an
+// unsigned varint, and the receiving Conn splits the frames back into messages. It lives
+// in its own module so the nagle package itself stays free of third-party dependencies.
+package naglews
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaracil/nagle"
+)
+
+// Conn sends and receives messages over a WebSocket connection, coalescing the messages
+// written between flushes into a single binary frame. Both ends must use a Conn.
+type Conn struct {
+	ws     *websocket.Conn
+	writer *nagle.NagleWriter
+	reader *nagle.FrameReader
+}
+
+// NewConn wraps ws, buffering outgoing messages as configured by opts. Buffer sizes
+// count the length prefixes too. The Conn takes over ws: it must no longer be written
+// to directly, except for control messages sent with WriteControl.
+func NewConn(ws *websocket.Conn, opts ...nagle.Option) *Conn {
+	return &Conn{
+		ws:     ws,
+		writer: nagle.NewWriter(&frameWriter{ws: ws}, opts...),
+		reader: nagle.NewFrameReader(&frameStream{ws: ws}, nagle.FramePrefixUvarint),
+	}
+}
+
+// WriteMessage buffers p as one message. It is sent with the messages written around it
+// when a flush trigger fires.
+func (c *Conn) WriteMessage(p []byte) error {
+	message := make([]byte, 0, binary.MaxVarintLen64+len(p))
+	message = binary.AppendUvarint(message, uint64(len(p)))
+	message = append(message, p...)
+	_, err := c.writer.Write(message)
+	return err
+}
+
+// ReadMessage returns the next message sent by the peer's WriteMessage. The returned
+// slice is only valid until the next call to ReadMessage.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	return c.reader.ReadFrame()
+}
+
+// SetMaxMessageSize sets the largest message ReadMessage accepts.
+func (c *Conn) SetMaxMessageSize(n int) {
+	c.reader.SetMaxFrameSize(n)
+}
+
+// Flush sends the buffered messages immediately.
+func (c *Conn) Flush() error {
+	return c.writer.Flush()
+}
+
+// Writer returns the wrapper buffering outgoing messages, for access to its statistics and settings.
+func (c *Conn) Writer() *nagle.NagleWriter {
+	return c.writer
+}
+
+// Close sends the buffered messages and closes the WebSocket connection.
+func (c *Conn) Close() error {
+	return c.writer.Close()
+}
+
+// frameWriter sends each flushed batch as one binary frame.
+type frameWriter struct {
+	ws *websocket.Conn
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	if err := w.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *frameWriter) Close() error {
+	return w.ws.Close()
+}
+
+// frameStream reads the payloads of the binary frames as one continuous stream, so a
+// message may span frames. Text frames are skipped.
+type frameStream struct {
+	ws *websocket.Conn
+	r  io.Reader
+}
+
+func (s *frameStream) Read(p []byte) (int, error) {
+	for {
+		if s.r == nil {
+			messageType, r, err := s.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			if messageType != websocket.BinaryMessage {
+				continue
+			}
+			s.r = r
+		}
+		n, err := s.r.Read(p)
+		if err == io.EOF {
+			s.r = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
@@ -0,0 +1,96 @@
+package naglews
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jaracil/nagle"
+)
+
+// dial starts a WebSocket server handing its side of the connection to serve.
+func dial(t *testing.T, serve func(ws *websocket.Conn)) *websocket.Conn {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		serve(ws)
+	}))
+	t.Cleanup(server.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return ws
+}
+
+func TestConn_CoalescesMessages(t *testing.T) {
+	frames := make(chan int, 10)
+	ws := dial(t, func(ws *websocket.Conn) {
+		for {
+			if _, _, err := ws.NextReader(); err != nil {
+				close(frames)
+				return
+			}
+			frames <- 1
+		}
+	})
+
+	conn := NewConn(ws, nagle.WithFlushTimeout(time.Hour))
+	for _, message := range []string{"one", "two", "three"} {
+		if err := conn.WriteMessage([]byte(message)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.Close()
+
+	count := 0
+	for range frames {
+		count++
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 frame, but got: %d", count)
+	}
+}
+
+func TestConn_RoundTrip(t *testing.T) {
+	// The server echoes every message back
+	ws := dial(t, func(ws *websocket.Conn) {
+		conn := NewConn(ws, nagle.WithFlushTimeout(time.Millisecond))
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(message)
+		}
+	})
+
+	conn := NewConn(ws, nagle.WithBufferSize(8), nagle.WithFlushTimeout(time.Hour))
+	defer conn.Close()
+	messages := []string{"a", "bb", "ccc", strings.Repeat("d", 100)}
+	for _, message := range messages {
+		conn.WriteMessage([]byte(message))
+	}
+	conn.Flush()
+
+	for _, expected := range messages {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(message) != expected {
+			t.Fatalf("expected '%s', but got: '%s'", expected, string(message))
+		}
+	}
+}
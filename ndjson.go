@@ -0,0 +1,32 @@
+package nagle
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// NDJSONWriter marshals values to JSON and writes them to a NagleWriter as newline
+// delimited JSON, one line per value, for shipping telemetry to collectors. The lines
+// are coalesced into batches flushed by size and time like any other writes, and since
+// the writer runs in message mode, every batch ends at a line boundary.
+type NDJSONWriter struct {
+	*NagleWriter
+}
+
+// NewNDJSONWriter creates an NDJSONWriter writing to w, configured by opts. WithMessageMode
+// is always on.
+func NewNDJSONWriter(w io.Writer, opts ...Option) *NDJSONWriter {
+	opts = append(opts[:len(opts):len(opts)], WithMessageMode())
+	return &NDJSONWriter{NagleWriter: NewWriter(w, opts...)}
+}
+
+// Encode writes the JSON encoding of v followed by a newline. Nothing is written when v
+// cannot be marshaled.
+func (nj *NDJSONWriter) Encode(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = nj.Write(append(line, '\n'))
+	return err
+}
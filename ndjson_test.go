@@ -0,0 +1,30 @@
+package nagle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nj := NewNDJSONWriter(mockRWC, WithBufferSize(25), WithFlushTimeout(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		if err := nj.Encode(map[string]int{"seq": i}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := nj.Encode(func() {}); err == nil {
+		t.Fatal("expected an error for a value JSON cannot encode")
+	}
+	nj.Close()
+
+	// Each batch holds whole lines
+	if len(mockRWC.writes) != 2 || mockRWC.writes[0] != "{\"seq\":0}\n{\"seq\":1}\n" {
+		t.Fatalf("expected 2 batches of whole lines, but got: %q", mockRWC.writes)
+	}
+	if got := strings.Join(mockRWC.writes, ""); got != "{\"seq\":0}\n{\"seq\":1}\n{\"seq\":2}\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package nagle
+
+import (
+	"io"
+	"net"
+)
+
+// WithNoNest makes New, NewConn and NewWriter return the stream they are given, instead
+// of a new wrapper around it, when it already is a wrapper of the kind they create, so
+// middleware stacks can wrap unconditionally. The options of that call are then ignored.
+func WithNoNest() Option {
+	return func(o *options) {
+		o.noNest = true
+	}
+}
+
+// IsWrapped reports whether w is a wrapper created by this package or sits on top of one,
+// following Unwrap and NetConn methods like UnwrapAs.
+func IsWrapped(w io.Writer) bool {
+	_, ok := UnwrapAs[nagleWriterer](w)
+	return ok
+}
+
+// nagleWriterer is implemented by NagleWriter and the wrappers embedding it.
+type nagleWriterer interface {
+	nagleWriter() *NagleWriter
+}
+
+func (nw *NagleWriter) nagleWriter() *NagleWriter {
+	return nw
+}
+
+// nagleWriterOf returns the NagleWriter v is or embeds, or nil.
+func nagleWriterOf(v any) *NagleWriter {
+	if n, ok := v.(nagleWriterer); ok {
+		return n.nagleWriter()
+	}
+	return nil
+}
+
+// reuseWrapper returns the wrapper New would nest rwc in under WithNoNest.
+func reuseWrapper(rwc io.ReadWriteCloser) (*NagleWrapper, bool) {
+	switch w := rwc.(type) {
+	case *NagleWrapper:
+		return w, true
+	case *NagleConn:
+		return w.NagleWrapper, true
+	}
+	return nil, false
+}
+
+// reuseConn returns the conn NewConn would nest conn in under WithNoNest.
+func reuseConn(conn net.Conn) (*NagleConn, bool) {
+	nc, ok := conn.(*NagleConn)
+	return nc, ok
+}
+
+// passThroughLocked reports whether a Write can go straight to the underlying writer
+// because it is itself a wrapper that coalesces, so buffering twice would only add a
+// copy and a second flush delay.
+func (nw *NagleWriter) passThroughLocked() bool {
+	return nw.nested && nw.transform == nil && !nw.corked && !nw.paused && nw.pendingLocked() == 0 &&
+		nw.leaderDone == nil && nw.async == nil
+}
@@ -0,0 +1,81 @@
+package nagle
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIsWrapped(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	if IsWrapped(mockRWC) {
+		t.Fatalf("expected a plain stream not to be wrapped")
+	}
+	nagleWrapper := New(mockRWC)
+	defer nagleWrapper.Close()
+	if !IsWrapped(nagleWrapper) || !IsWrapped(nagleWrapper.NagleWriter) {
+		t.Fatalf("expected the wrapper to be detected")
+	}
+
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client)
+	defer nagleConn.Close()
+	if !IsWrapped(nagleConn) || !IsWrapped(tls.Client(nagleConn, &tls.Config{})) {
+		t.Fatalf("expected NagleConn to be detected, also under TLS")
+	}
+}
+
+func TestNagleWrapper_NestedPassThrough(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	inner := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour))
+	outer := New(inner, WithBufferSize(100), WithFlushTimeout(time.Hour))
+
+	// The outer wrapper hands writes to the inner one, which does the coalescing
+	outer.Write([]byte("ab"))
+	if outer.Buffered() != 0 || inner.Buffered() != 2 {
+		t.Fatalf("expected the data to be buffered once, in the inner wrapper, but got: %d and %d", outer.Buffered(), inner.Buffered())
+	}
+	outer.Write([]byte("cd"))
+	if mockRWC.String() != "abcd" {
+		t.Fatalf("expected 'abcd' to be flushed by the inner wrapper, but got: '%s'", mockRWC.String())
+	}
+
+	// Corked, the outer wrapper buffers as usual
+	outer.Cork()
+	outer.Write([]byte("ef"))
+	if outer.Buffered() != 2 {
+		t.Fatalf("expected the corked wrapper to buffer, but got: %d", outer.Buffered())
+	}
+	outer.Uncork()
+	outer.Close()
+	if mockRWC.String() != "abcdef" {
+		t.Fatalf("expected 'abcdef', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestWithNoNest(t *testing.T) {
+	factory := NewWrapperFactory(nil, WithNoNest())
+	nagleWrapper := factory.New(&MockReadWriteCloser{})
+	if again := factory.New(nagleWrapper); again != nagleWrapper {
+		t.Fatalf("expected the wrapper to be returned as is")
+	}
+	if writer := NewWriter(nagleWrapper, WithNoNest()); writer != nagleWrapper.NagleWriter {
+		t.Fatalf("expected NewWriter to return the wrapped writer")
+	}
+	if New(nagleWrapper) == nagleWrapper {
+		t.Fatalf("expected a new wrapper without WithNoNest")
+	}
+	if factory.Len() != 1 {
+		t.Fatalf("expected the wrapper to be tracked once, but got: %d", factory.Len())
+	}
+
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client)
+	if NewConn(nagleConn, WithNoNest()) != nagleConn {
+		t.Fatalf("expected NewConn to return the conn as is")
+	}
+	nagleConn.Close()
+}
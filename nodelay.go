@@ -0,0 +1,50 @@
+package nagle
+
+import (
+	"io"
+	"net"
+)
+
+// WithTCPNoDelay controls whether NewConn sets TCP_NODELAY on TCP connections, including
+// those under a *tls.Conn. It is enabled by default, so the kernel does not hold small
+// segments back on top of the coalescing done by the wrapper. The setting found on the
+// connection is restored when the NagleConn is closed. It has no effect on New and NewWriter.
+func WithTCPNoDelay(enabled bool) Option {
+	return func(o *options) {
+		o.tcpNoDelay = enabled
+	}
+}
+
+// tcpConn returns the TCP connection carrying conn, if any.
+func tcpConn(conn net.Conn) (*net.TCPConn, bool) {
+	return UnwrapAs[*net.TCPConn](conn)
+}
+
+// noDelayCloser restores the TCP_NODELAY setting of a connection before closing it.
+type noDelayCloser struct {
+	tcp     *net.TCPConn
+	noDelay bool
+	closer  io.Closer
+}
+
+func (c *noDelayCloser) Close() error {
+	c.tcp.SetNoDelay(c.noDelay)
+	return c.closer.Close()
+}
+
+// setNoDelay enables TCP_NODELAY on the TCP connection under conn and returns the
+// closer that restores the previous setting, or closer itself when there is nothing to restore.
+func setNoDelay(conn net.Conn, closer io.Closer) io.Closer {
+	tcp, ok := tcpConn(conn)
+	if !ok {
+		return closer
+	}
+	noDelay, err := tcpNoDelay(tcp)
+	if err == nil && noDelay {
+		return closer
+	}
+	if tcp.SetNoDelay(true) != nil || err != nil {
+		return closer
+	}
+	return &noDelayCloser{tcp: tcp, noDelay: noDelay, closer: closer}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package nagle
+
+import (
+	"errors"
+	"net"
+)
+
+// tcpNoDelay reports whether TCP_NODELAY is set on tcp. The setting cannot be read on
+// this platform, so TCP_NODELAY is still enabled but not restored on Close.
+func tcpNoDelay(tcp *net.TCPConn) (bool, error) {
+	return false, errors.ErrUnsupported
+}
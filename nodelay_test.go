@@ -0,0 +1,61 @@
+package nagle
+
+import (
+	"net"
+	"testing"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func TestNagleConn_TCPNoDelay(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tcp := conn.(*net.TCPConn)
+	tcp.SetNoDelay(false)
+	if _, err := tcpNoDelay(tcp); err != nil {
+		t.Skipf("TCP_NODELAY cannot be read on this platform: %v", err)
+	}
+
+	nagleConn := NewConn(conn)
+	if noDelay, _ := tcpNoDelay(tcp); !noDelay {
+		t.Fatalf("expected TCP_NODELAY to be set on the wrapped conn")
+	}
+
+	// Check the setting right before the conn is closed
+	noDelayAtClose := true
+	nagleConn.closer.(*noDelayCloser).closer = closerFunc(func() error {
+		noDelayAtClose, _ = tcpNoDelay(tcp)
+		return conn.Close()
+	})
+	if err := nagleConn.Close(); err != nil {
+		t.Fatalf("unexpected error on close: %v", err)
+	}
+	if noDelayAtClose {
+		t.Fatalf("expected TCP_NODELAY to be restored on close")
+	}
+
+	// Disabled, the kernel setting is left alone
+	conn, err = net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tcp = conn.(*net.TCPConn)
+	tcp.SetNoDelay(false)
+	nagleConn = NewConn(conn, WithTCPNoDelay(false))
+	defer nagleConn.Close()
+	if noDelay, _ := tcpNoDelay(tcp); noDelay {
+		t.Fatalf("expected TCP_NODELAY to be left unset")
+	}
+}
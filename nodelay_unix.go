@@ -0,0 +1,25 @@
+//go:build unix
+
+package nagle
+
+import (
+	"net"
+	"syscall"
+)
+
+// tcpNoDelay reports whether TCP_NODELAY is set on tcp.
+func tcpNoDelay(tcp *net.TCPConn) (bool, error) {
+	raw, err := tcp.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	var value int
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		value, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	})
+	if err != nil {
+		return false, err
+	}
+	return value != 0, sockErr
+}
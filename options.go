@@ -0,0 +1,398 @@
+package nagle
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is the flush threshold used when WithBufferSize is not given.
+	DefaultBufferSize = 4096
+	// DefaultFlushTimeout is the flush timeout used when WithFlushTimeout is not given.
+	DefaultFlushTimeout = 10 * time.Millisecond
+)
+
+// Option configures a NagleWrapper created with New or a NagleWriter created with NewWriter.
+type Option func(*options)
+
+type options struct {
+	bufferSize      int
+	flushTimeout    time.Duration
+	maxPendingBytes int
+	blockOnFull     bool
+	messageMode     bool
+	clock           Clock
+	onError         func(error)
+	readBufferSize  int
+	adaptiveMin     time.Duration
+	adaptiveMax     time.Duration
+	vectoredFlush   bool
+	scheduler       *FlushScheduler
+	transform       func([]byte) ([]byte, error)
+	framing         FramePrefix
+	delimiter       []byte
+	flushHook       func([]byte, FlushTrigger)
+	flushObservers  []func(FlushInfo)
+	tcpNoDelay      bool
+	maxWrites       int
+	rateLimit       int
+	rateBurst       int
+	middlewares     []Middleware
+	checksum        bool
+	mtu             int
+	onClose         func(*NagleWriter)
+	ackGating       bool
+	autoAck         bool
+	minInterval     time.Duration
+	doubleBuffer    bool
+	asyncDepth      int
+	memory          *MemoryLimiter
+	flushOnRead     bool
+	noNest          bool
+	messagePrefix   FramePrefix
+	keepAlive       time.Duration
+	keepAliveData   []byte
+	writeDeadline   time.Duration
+	retry           RetryPolicy
+	overflow        OverflowPolicy
+	ttl             time.Duration
+	logger          *slog.Logger
+	strict          bool
+	preallocate     bool
+	prealloc        int
+	signals         []*FlushSignal
+	strategy        Strategy
+	latencyBudget   time.Duration
+	softSize        int
+	softDelay       time.Duration
+	maxFlushSize    int
+	frameFunc       func([]byte) [][]byte
+	tlsRecords      bool
+	syncPolicy      SyncPolicy
+	profile         *Profile
+	fairWrites      int
+	fairSlice       time.Duration
+	jitter          float64
+	maxPause        time.Duration
+	dial            func(context.Context) (io.ReadWriteCloser, error)
+	reconnect       RetryPolicy
+	journalDir      string
+}
+
+func defaultOptions() options {
+	return options{
+		bufferSize:   DefaultBufferSize,
+		flushTimeout: DefaultFlushTimeout,
+		clock:        realClock{},
+		tcpNoDelay:   true,
+		mtu:          DefaultMTU,
+	}
+}
+
+// WithBufferSize sets the number of buffered bytes that triggers a flush.
+func WithBufferSize(n int) Option {
+	return func(o *options) {
+		o.bufferSize = n
+	}
+}
+
+// WithFlushTimeout sets how long buffered data may wait before it is flushed.
+func WithFlushTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.flushTimeout = d
+	}
+}
+
+// WithMaxPendingBytes caps the number of bytes the wrapper may hold.
+// A Write that does not fit, even after flushing, fails with ErrBufferOverflow.
+// Zero means no limit. It replaces any limit set by WithMaxBufferSize.
+func WithMaxPendingBytes(n int) Option {
+	return func(o *options) {
+		o.maxPendingBytes = n
+		o.blockOnFull = false
+		o.overflow = OverflowReject
+	}
+}
+
+// WithMaxBufferSize caps the number of bytes the wrapper may hold, like WithMaxPendingBytes,
+// but a Write that does not fit blocks while the buffer is flushed to make room instead
+// of failing, applying backpressure from the underlying writer to the caller.
+// Zero means no limit. It replaces any limit set by WithMaxPendingBytes.
+func WithMaxBufferSize(n int) Option {
+	return func(o *options) {
+		o.maxPendingBytes = n
+		o.blockOnFull = true
+		o.overflow = OverflowBlock
+	}
+}
+
+// WithMaxPendingWrites flushes once n Write calls have been buffered, for transports
+// that care about the number of messages more than their size. It works alongside the
+// size and timeout triggers. Zero, the default, means no limit.
+func WithMaxPendingWrites(n int) Option {
+	return func(o *options) {
+		o.maxWrites = n
+	}
+}
+
+// WithClock sets the time source used to schedule flushes.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithOnError registers a callback invoked with errors from background flushes.
+// The callback runs without the wrapper lock held, so it may call Close.
+func WithOnError(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// WithReadBuffer enables read-ahead buffering of n bytes, so small reads are served
+// from memory with bufio.Reader semantics instead of hitting the underlying stream each time.
+// Zero, the default, passes reads straight through. It has no effect on a NagleWriter.
+func WithReadBuffer(n int) Option {
+	return func(o *options) {
+		o.readBufferSize = n
+	}
+}
+
+// WithAdaptiveTimeout makes the flush timeout track the observed interval between writes,
+// bounded by min and max: bursty traffic gets short timeouts, sparse traffic longer ones.
+// The value given by WithFlushTimeout is used until enough writes have been observed.
+func WithAdaptiveTimeout(min, max time.Duration) Option {
+	return func(o *options) {
+		o.adaptiveMin = min
+		o.adaptiveMax = max
+	}
+}
+
+// WithMessageMode treats every Write as an indivisible message: flushes may coalesce
+// whole messages but never emit part of one. Buffered messages are flushed before a new
+// one would push the batch past the buffer size, and a message that cannot fit within
+// the pending bytes limit fails with ErrBufferOverflow instead of being split.
+// Use it when Write boundaries are meaningful to the underlying transport.
+func WithMessageMode() Option {
+	return func(o *options) {
+		o.messageMode = true
+	}
+}
+
+// WithVectoredFlush keeps buffered writes as separate segments and flushes them with a
+// single writev call instead of copying them into one contiguous buffer. It only takes
+// effect when the underlying writer is a *net.TCPConn, *net.UnixConn, *net.IPConn or
+// *net.UDPConn, the connections on which net.Buffers uses writev, and no option such as
+// WithWriteDeadline or WithFlushRetry adds a layer in front of it.
+func WithVectoredFlush() Option {
+	return func(o *options) {
+		o.vectoredFlush = true
+	}
+}
+
+// WithScheduler runs the wrapper's flush timer on s instead of a timer of its own,
+// so thousands of wrappers can share one ticker and a small pool of flush goroutines.
+func WithScheduler(s *FlushScheduler) Option {
+	return func(o *options) {
+		o.scheduler = s
+	}
+}
+
+// New creates a new wrapper with Nagle's algorithm configured by opts.
+// When rwc is itself a wrapper from this package, writes go straight to it unless
+// corked or transformed, so the data is not buffered and delayed twice.
+func New(rwc io.ReadWriteCloser, opts ...Option) *NagleWrapper {
+	o := buildOptions(opts)
+	if nw, ok := reuseWrapper(rwc); ok && o.noNest {
+		return nw
+	}
+	return newWrapper(rwc, o)
+}
+
+// NewWriter creates a new io.Writer wrapper with Nagle's algorithm configured by opts.
+// If w also implements io.Closer, Close closes it after the final flush.
+func NewWriter(w io.Writer, opts ...Option) *NagleWriter {
+	o := buildOptions(opts)
+	if nw := nagleWriterOf(w); nw != nil && o.noNest {
+		return nw
+	}
+	closer, _ := w.(io.Closer)
+	return newWriter(w, closer, o)
+}
+
+// NewFromReadWriter is like New for a stream that may have no Close method, such as
+// the standard input and output joined by a struct. If rw also implements io.Closer,
+// the result is that of New; otherwise Close only flushes and marks the wrapper closed,
+// and Unwrap returns rw with a Close that does nothing.
+func NewFromReadWriter(rw io.ReadWriter, opts ...Option) *NagleWrapper {
+	if rwc, ok := rw.(io.ReadWriteCloser); ok {
+		return New(rwc, opts...)
+	}
+	nw := newWrapper(nopCloser{rw}, buildOptions(opts))
+	nw.closer = nil
+	return nw
+}
+
+// nopCloser adds a Close that does nothing to a stream that cannot be closed.
+type nopCloser struct {
+	io.ReadWriter
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func newWrapper(rwc io.ReadWriteCloser, o options) *NagleWrapper {
+	wrapper := &NagleWrapper{
+		NagleWriter: newWriter(rwc, rwc, o),
+		framing:     o.framing,
+		checksum:    o.checksum,
+	}
+	wrapper.source.Store(newReadSource(rwc, o.readBufferSize))
+	if wrapper.reconnect != nil {
+		wrapper.reconnect.swap = wrapper.swapLocked
+	}
+	if o.checksum && o.framing == FramePrefixNone {
+		wrapper.framing = FramePrefixUint32
+	}
+	return wrapper
+}
+
+func buildOptions(opts []Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if p := o.profile; p != nil {
+		// Start over from the profile, so the explicit options override it wherever given.
+		o = defaultOptions()
+		p.apply(&o)
+		for _, opt := range opts {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// newFlushBuffer returns the buffer for a writer flushing to w, the outermost layer of
+// its chain: segments only pay off when the flush hands them to the connection itself.
+func newFlushBuffer(w io.Writer, o options) flushBuffer {
+	if o.vectoredFlush && supportsWritev(w) {
+		return &segmentBuffer{}
+	}
+	return newBatchBuffer(o.bufferSize, o.prealloc)
+}
+
+func newWriter(w io.Writer, closer io.Closer, o options) *NagleWriter {
+	o.applyPrealloc()
+	writer := &NagleWriter{
+		w:               w,
+		base:            w,
+		closer:          closer,
+		mutex:           newCtxMutex(),
+		bufferSize:      o.bufferSize,
+		flushTimeout:    o.flushTimeout,
+		maxPendingBytes: o.maxPendingBytes,
+		maxWrites:       o.maxWrites,
+		blockOnFull:     o.blockOnFull,
+		overflow:        o.overflow,
+		ttl:             o.ttl,
+		messageMode:     o.messageMode,
+		clock:           o.clock,
+		scheduler:       o.clock,
+		onError:         o.onError,
+		adaptive:        adaptiveTimeout{min: o.adaptiveMin, max: o.adaptiveMax},
+		delimiter:       o.delimiter,
+		flushHook:       o.flushHook,
+		flushObservers:  o.flushObservers,
+		onClose:         o.onClose,
+		ackGating:       o.ackGating,
+		autoAck:         o.autoAck,
+		minInterval:     o.minInterval,
+		doubleBuffer:    o.doubleBuffer,
+		memory:          o.memory,
+		flushOnRead:     o.flushOnRead,
+		nested:          nagleWriterOf(w) != nil && o.frameFunc == nil,
+		messagePrefix:   o.messagePrefix,
+		keepAlive:       o.keepAlive,
+		keepAliveData:   o.keepAliveData,
+		logger:          newLogger(o.logger, w),
+		strict:          o.strict,
+		prealloc:        o.prealloc,
+		signals:         append([]*FlushSignal(nil), o.signals...),
+		strategy:        o.strategy,
+		latencyBudget:   o.latencyBudget,
+		softSize:        o.softSize,
+		softDelay:       o.softDelay,
+		fairWrites:      o.fairWrites,
+		fairSlice:       o.fairSlice,
+		jitter:          o.jitter,
+		maxPause:        o.maxPause,
+	}
+	var pipeline []Middleware
+	if o.transform != nil {
+		pipeline = append(pipeline, o.transform)
+	}
+	pipeline = append(pipeline, o.middlewares...)
+	if o.checksum {
+		pipeline = append(pipeline, checksumMiddleware)
+		if o.framing == FramePrefixNone {
+			o.framing = FramePrefixUint32
+		}
+	}
+	if o.framing != FramePrefixNone {
+		pipeline = append(pipeline, frameMiddleware(o.framing))
+	}
+	writer.transform = chainMiddleware(pipeline)
+	if o.scheduler != nil {
+		writer.scheduler = o.scheduler
+	}
+	if o.asyncDepth > 0 {
+		writer.async = newAsyncFlusher(o.asyncDepth)
+	}
+	if o.writeDeadline > 0 {
+		writer.w = newDeadlineWriter(w, o.clock, o.writeDeadline)
+	}
+	if o.dial != nil {
+		writer.reconnect = newReconnector(writer, o)
+		writer.w = &reconnectWriter{w: writer.w, nw: writer}
+	}
+	if o.tlsRecords {
+		writer.records = &recordCounter{w: writer.w}
+		writer.w = writer.records
+	}
+	if o.retry.Attempts > 0 {
+		writer.retry = &retryWriter{w: writer.w, clock: o.clock, policy: o.retry, logger: writer.logger}
+		writer.w = writer.retry
+	}
+	if o.rateLimit > 0 {
+		writer.w = newRateLimiter(writer.w, o.clock, o.rateLimit, o.rateBurst)
+	}
+	if o.maxFlushSize > 0 {
+		writer.w = &chunkWriter{w: writer.w, size: o.maxFlushSize}
+	}
+	if o.frameFunc != nil {
+		writer.frames = &frameWriter{w: writer.w, fn: o.frameFunc}
+		writer.w = writer.frames
+	}
+	if file, ok := w.(syncer); ok && o.syncPolicy != SyncNever {
+		s := &syncWriter{w: writer.w, file: file, clock: o.clock, policy: o.syncPolicy}
+		writer.w = s
+		writer.closer = syncCloser{s: s, closer: closer}
+	}
+	writer.buffer = newFlushBuffer(writer.w, o)
+	if o.journalDir != "" {
+		writer.openJournalLocked(o.journalDir)
+	}
+	if o.keepAlive > 0 {
+		writer.startKeepAlive()
+	}
+	for _, s := range writer.signals {
+		s.add(writer)
+	}
+
+	return writer
+}
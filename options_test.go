@@ -0,0 +1,165 @@
+package nagle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNew_Defaults(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC)
+	defer nagleWrapper.Close()
+
+	if nagleWrapper.bufferSize != DefaultBufferSize {
+		t.Fatalf("expected buffer size %d, got %d", DefaultBufferSize, nagleWrapper.bufferSize)
+	}
+	if nagleWrapper.flushTimeout != DefaultFlushTimeout {
+		t.Fatalf("expected flush timeout %v, got %v", DefaultFlushTimeout, nagleWrapper.flushTimeout)
+	}
+}
+
+func TestNew_WithOptions(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	if _, err := nagleWrapper.Write([]byte("012")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "" {
+		t.Fatalf("expected buffer to be empty, but got: %s", mockRWC.String())
+	}
+	if _, err := nagleWrapper.Write([]byte("3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "0123" {
+		t.Fatalf("expected buffer to contain '0123', but got: %s", mockRWC.String())
+	}
+}
+
+func TestNew_WithMaxPendingBytes(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxPendingBytes(8))
+	defer nagleWrapper.Close()
+
+	if _, err := nagleWrapper.Write([]byte("0123456789")); !errors.Is(err, ErrBufferOverflow) {
+		t.Fatalf("expected ErrBufferOverflow, but got: %v", err)
+	}
+
+	// Writes exceeding the limit flush pending data first to make room
+	if _, err := nagleWrapper.Write([]byte("01234")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := nagleWrapper.Write([]byte("56789")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "01234" {
+		t.Fatalf("expected buffer to contain '01234', but got: %s", mockRWC.String())
+	}
+}
+
+func TestNew_WithMaxBufferSize(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxBufferSize(4))
+	defer nagleWrapper.Close()
+
+	// A write larger than the limit is pushed through in limit-sized chunks
+	n, err := nagleWrapper.Write([]byte("0123456789"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("expected to write 10 bytes, wrote %d", n)
+	}
+	if mockRWC.String() != "01234567" {
+		t.Fatalf("expected buffer to contain '01234567', but got: %s", mockRWC.String())
+	}
+	if stats := nagleWrapper.Stats(); stats.MaxBuffered != 4 {
+		t.Fatalf("expected max buffered 4, got %d", stats.MaxBuffered)
+	}
+}
+
+func TestNew_WithMaxBufferSizeFlushError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	mockRWC := &FailingReadWriteCloser{err: writeErr}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxBufferSize(4))
+
+	n, err := nagleWrapper.Write([]byte("0123456789"))
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("expected %v, but got: %v", writeErr, err)
+	}
+	if n != 4 {
+		t.Fatalf("expected to write 4 bytes, wrote %d", n)
+	}
+}
+
+func TestNew_WithMessageMode(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(8), WithFlushTimeout(time.Hour), WithMessageMode(), WithMaxBufferSize(10))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("aaa"))
+	nagleWrapper.Write([]byte("bbb"))
+	// This message would cross the threshold, so the previous ones go out first
+	nagleWrapper.Write([]byte("ccccc"))
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "aaabbb" {
+		t.Fatalf("expected a single write of 'aaabbb', got %q", mockRWC.writes)
+	}
+
+	// A message larger than the limit is rejected instead of being split
+	if _, err := nagleWrapper.Write([]byte("0123456789a")); !errors.Is(err, ErrBufferOverflow) {
+		t.Fatalf("expected ErrBufferOverflow, but got: %v", err)
+	}
+
+	nagleWrapper.Flush()
+	if len(mockRWC.writes) != 2 || mockRWC.writes[1] != "ccccc" {
+		t.Fatalf("expected second write of 'ccccc', got %q", mockRWC.writes)
+	}
+}
+
+func TestNew_WithMaxPendingWrites(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxPendingWrites(3))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("a"))
+	nagleWrapper.Write([]byte("b"))
+	if len(mockRWC.writes) != 0 {
+		t.Fatalf("expected no writes before the limit, but got: %q", mockRWC.writes)
+	}
+	nagleWrapper.Write([]byte("c"))
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "abc" {
+		t.Fatalf("expected a single write of 'abc', but got: %q", mockRWC.writes)
+	}
+
+	// The count starts over after each flush
+	nagleWrapper.Write([]byte("d"))
+	nagleWrapper.Flush()
+	nagleWrapper.Write([]byte("e"))
+	nagleWrapper.Write([]byte("f"))
+	if len(mockRWC.writes) != 2 {
+		t.Fatalf("expected 2 writes, but got: %q", mockRWC.writes)
+	}
+
+	if stats := nagleWrapper.Stats(); stats.CountFlushes != 1 {
+		t.Fatalf("expected 1 count flush, but got: %d", stats.CountFlushes)
+	}
+}
+
+func TestNew_WithProfile(t *testing.T) {
+	o := buildOptions([]Option{WithFlushTimeout(time.Second), WithProfile(ProfileSerial9600)})
+	if o.bufferSize != 64 || o.maxFlushSize != 64 {
+		t.Fatalf("expected the sizes of the profile, got %d and %d", o.bufferSize, o.maxFlushSize)
+	}
+	// Explicit options win even when given before the profile
+	if o.flushTimeout != time.Second {
+		t.Fatalf("expected flush timeout %v, got %v", time.Second, o.flushTimeout)
+	}
+
+	nagleWrapper := New(&MockReadWriteCloser{}, WithProfile(ProfileLAN), WithBufferSize(10))
+	defer nagleWrapper.Close()
+	if nagleWrapper.bufferSize != 10 || nagleWrapper.flushTimeout != time.Millisecond {
+		t.Fatalf("expected buffer size 10 and flush timeout 1ms, got %d and %v", nagleWrapper.bufferSize, nagleWrapper.flushTimeout)
+	}
+}
@@ -0,0 +1,111 @@
+package nagle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_ConcurrentWriteOrdering(t *testing.T) {
+	variants := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"max buffer size", []Option{WithMaxBufferSize(48)}},
+		{"message mode", []Option{WithMessageMode()}},
+		{"double buffer", []Option{WithDoubleBuffer()}},
+		{"async flush", []Option{WithAsyncFlush(4)}},
+		{"auto ack", []Option{WithAutoAck()}},
+		{"delimiter", []Option{WithFlushDelimiter([]byte(";"))}},
+	}
+
+	const writers, records = 8, 200
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			mockRWC := &MockReadWriteCloser{}
+			opts := append([]Option{WithBufferSize(32), WithFlushTimeout(time.Millisecond)}, variant.opts...)
+			nagleWrapper := New(mockRWC, opts...)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, writers)
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < records; i++ {
+						record := fmt.Sprintf("%d:%d;", w, i)
+						if _, err := nagleWrapper.Write([]byte(record)); err != nil {
+							errs <- err
+							return
+						}
+						if i%40 != 0 {
+							continue
+						}
+						// Flush sends everything written before it
+						if err := nagleWrapper.Flush(); err != nil {
+							errs <- err
+							return
+						}
+						if !strings.Contains(mockRWC.String(), record) {
+							errs <- fmt.Errorf("record %q not sent by Flush", record)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := nagleWrapper.Close(); err != nil {
+				t.Fatalf("unexpected error on close: %v", err)
+			}
+
+			// Every record arrives whole, once, and in the order its writer made it
+			next := make([]int, writers)
+			for _, record := range strings.Split(strings.TrimSuffix(mockRWC.String(), ";"), ";") {
+				w, i, ok := strings.Cut(record, ":")
+				writer, err1 := strconv.Atoi(w)
+				seq, err2 := strconv.Atoi(i)
+				if !ok || err1 != nil || err2 != nil || writer >= writers {
+					t.Fatalf("expected whole records, but got: %q", record)
+				}
+				if seq != next[writer] {
+					t.Fatalf("expected record %d of writer %d, but got: %d", next[writer], writer, seq)
+				}
+				next[writer]++
+			}
+			for w, n := range next {
+				if n != records {
+					t.Fatalf("expected %d records from writer %d, but got: %d", records, w, n)
+				}
+			}
+		})
+	}
+}
+
+func TestNagleWrapper_CloseObservesConcurrentWrites(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		mockRWC := &MockReadWriteCloser{}
+		nagleWrapper := New(mockRWC, WithBufferSize(16), WithFlushTimeout(time.Hour), WithDoubleBuffer())
+
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				nagleWrapper.Write([]byte("0123456789"))
+			}()
+		}
+		wg.Wait()
+		nagleWrapper.Close()
+		if got := len(mockRWC.String()); got != 40 {
+			t.Fatalf("expected Close to send all 40 bytes, but got: %d", got)
+		}
+	}
+}
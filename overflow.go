@@ -0,0 +1,144 @@
+package nagle
+
+import (
+	"log/slog"
+	"time"
+)
+
+// OverflowPolicy selects what a Write does when the data it adds would take the buffer
+// past the cap set with WithOverflowPolicy.
+type OverflowPolicy int
+
+const (
+	// OverflowReject fails the write with ErrBufferOverflow, like WithMaxPendingBytes.
+	OverflowReject OverflowPolicy = iota
+	// OverflowBlock holds the write back while the buffer is flushed, like WithMaxBufferSize.
+	OverflowBlock
+	// OverflowDropNewest discards the new write.
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest buffered writes until the new one fits.
+	OverflowDropOldest
+)
+
+// WithOverflowPolicy caps the number of bytes the wrapper may hold at n and sets what
+// a Write that does not fit, even after flushing, does. Under the drop policies the
+// write reports success and the discarded writes are counted in Stats, which suits
+// telemetry streams where stale data is worthless. They do not flush while the wrapper
+// is corked, and never drop a write that was partly sent. Zero means no limit. It
+// replaces any limit set by WithMaxPendingBytes or WithMaxBufferSize.
+func WithOverflowPolicy(n int, policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.maxPendingBytes = n
+		o.blockOnFull = policy == OverflowBlock
+		o.overflow = policy
+	}
+}
+
+// dropping reports whether a write that does not fit is handled by discarding data.
+func (nw *NagleWriter) dropping() bool {
+	return nw.overflow == OverflowDropNewest || nw.overflow == OverflowDropOldest
+}
+
+// overflowLocked makes room for n bytes under a drop policy by discarding the oldest
+// writes if allowed, and otherwise counts the n bytes as dropped. It reports whether
+// they fit.
+func (nw *NagleWriter) overflowLocked(n int) bool {
+	if nw.overflow == OverflowDropOldest {
+		nw.dropOldestLocked(nw.buffer.Len() + n - nw.maxPendingBytes)
+	}
+	if nw.buffer.Len()+n <= nw.maxPendingBytes {
+		return true
+	}
+	nw.stats.DroppedWrites++
+	nw.stats.DroppedBytes += int64(n)
+	nw.log(slog.LevelWarn, "nagle: buffer full, write dropped", "bytes", n)
+	return false
+}
+
+// bufferedWrite is the size of a write held in the buffer and the time it was buffered.
+type bufferedWrite struct {
+	size int
+	at   time.Time
+}
+
+// tracksWrites reports whether the boundaries of the buffered writes are recorded, for
+// OverflowDropOldest or WithMessageTTL to discard whole writes.
+func (nw *NagleWriter) tracksWrites() bool {
+	return nw.overflow == OverflowDropOldest || nw.ttl > 0
+}
+
+// recordWriteLocked remembers a write of n bytes about to be appended to the buffer.
+func (nw *NagleWriter) recordWriteLocked(n int) {
+	nw.syncWritesLocked()
+	nw.writes = append(nw.writes, bufferedWrite{size: n, at: nw.clock.Now()})
+	nw.writesTotal += n
+}
+
+// syncWritesLocked brings the recorded writes in line with the buffer. Flushes take
+// bytes from the front of the buffer, and a short write puts the unsent ones back there.
+func (nw *NagleWriter) syncWritesLocked() {
+	gone := nw.writesTotal - nw.buffer.Len()
+	for gone > 0 && len(nw.writes) > 0 {
+		if nw.writes[0].size > gone {
+			nw.writes[0].size -= gone
+			break
+		}
+		gone -= nw.writes[0].size
+		nw.writes = nw.writes[1:]
+	}
+	if gone < 0 {
+		nw.writes = append([]bufferedWrite{{size: -gone, at: nw.clock.Now()}}, nw.writes...)
+	}
+	if len(nw.writes) == 0 {
+		nw.writes = nil
+	}
+	nw.writesTotal = nw.buffer.Len()
+}
+
+// keptWritesLocked returns the number of recorded writes at the front of the buffer that
+// must be kept: the first one if it was partly sent, since the rest of it must follow.
+func (nw *NagleWriter) keptWritesLocked() int {
+	nw.syncWritesLocked()
+	if nw.partial && len(nw.writes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// dropOldestLocked discards the oldest whole writes in the buffer until at least excess
+// bytes are gone. It discards nothing if that is not possible.
+func (nw *NagleWriter) dropOldestLocked(excess int) {
+	keep := nw.keptWritesLocked()
+	count, size := 0, 0
+	for _, w := range nw.writes[keep:] {
+		if size >= excess {
+			break
+		}
+		count++
+		size += w.size
+	}
+	if count == 0 || size < excess {
+		return
+	}
+	nw.removeWritesLocked(keep, count, size)
+	nw.stats.DroppedWrites += int64(count)
+	nw.stats.DroppedBytes += int64(size)
+	nw.log(slog.LevelWarn, "nagle: buffer full, oldest writes dropped", "writes", count, "bytes", size)
+}
+
+// removeWritesLocked removes from the buffer the count recorded writes, size bytes in
+// all, that follow the first keep ones.
+func (nw *NagleWriter) removeWritesLocked(keep, count, size int) {
+	head := 0
+	for _, w := range nw.writes[:keep] {
+		head += w.size
+	}
+	data := nw.buffer.Bytes()
+	kept := append(data[:head:head], data[head+size:]...)
+	nw.buffer.Reset()
+	nw.buffer.Write(kept)
+
+	nw.writes = append(nw.writes[:keep], nw.writes[keep+count:]...)
+	nw.writesTotal -= size
+	nw.pendingWrites = max(nw.pendingWrites-count, 0)
+}
@@ -0,0 +1,78 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNew_WithOverflowPolicyDropNewest(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithOverflowPolicy(8, OverflowDropNewest))
+	defer nagleWrapper.Close()
+
+	// While corked nothing can be flushed to make room
+	nagleWrapper.Cork()
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Write([]byte("4567"))
+	if n, err := nagleWrapper.Write([]byte("89")); n != 2 || err != nil {
+		t.Fatalf("expected the dropped write to report 2 bytes, but got: %d, %v", n, err)
+	}
+	nagleWrapper.Uncork()
+	nagleWrapper.Flush()
+
+	if mockRWC.String() != "01234567" {
+		t.Fatalf("expected '01234567', but got: '%s'", mockRWC.String())
+	}
+	if stats := nagleWrapper.Stats(); stats.DroppedWrites != 1 || stats.DroppedBytes != 2 {
+		t.Fatalf("expected 1 write of 2 bytes dropped, but got: %d, %d", stats.DroppedWrites, stats.DroppedBytes)
+	}
+}
+
+func TestNew_WithOverflowPolicyDropOldest(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithOverflowPolicy(8, OverflowDropOldest))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Cork()
+	nagleWrapper.Write([]byte("aaa"))
+	nagleWrapper.Write([]byte("bb"))
+	nagleWrapper.Write([]byte("ccc"))
+	// The two oldest writes go to make room for this one
+	nagleWrapper.Write([]byte("dddd"))
+	// Nothing is dropped for a write that could never fit
+	nagleWrapper.Write([]byte("0123456789"))
+	nagleWrapper.Uncork()
+	nagleWrapper.Flush()
+
+	if mockRWC.String() != "cccdddd" {
+		t.Fatalf("expected 'cccdddd', but got: '%s'", mockRWC.String())
+	}
+	if stats := nagleWrapper.Stats(); stats.DroppedWrites != 3 || stats.DroppedBytes != 15 {
+		t.Fatalf("expected 3 writes of 15 bytes dropped, but got: %d, %d", stats.DroppedWrites, stats.DroppedBytes)
+	}
+}
+
+func TestNew_WithOverflowPolicyKeepsPartialWrite(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 2}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithOverflowPolicy(6, OverflowDropOldest))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("aaaa"))
+	nagleWrapper.Flush()
+	nagleWrapper.Cork()
+	nagleWrapper.Write([]byte("bb"))
+	// The rest of the partly sent write is kept, so only "bb" can go
+	nagleWrapper.Write([]byte("cccc"))
+	if got := string(nagleWrapper.buffer.Bytes()); got != "aacccc" {
+		t.Fatalf("expected 'aacccc' buffered, but got: '%s'", got)
+	}
+}
+
+func TestNew_WithOverflowPolicyReplacesLimit(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithMaxBufferSize(4), WithOverflowPolicy(8, OverflowReject))
+	defer nagleWrapper.Close()
+
+	if nagleWrapper.maxPendingBytes != 8 || nagleWrapper.blockOnFull {
+		t.Fatalf("expected a non-blocking limit of 8, but got: %d, %v", nagleWrapper.maxPendingBytes, nagleWrapper.blockOnFull)
+	}
+}
@@ -0,0 +1,206 @@
+package nagle
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultMTU is the largest datagram a NaglePacketConn sends unless changed with WithMTU.
+// It leaves room for IP and UDP headers on a 1500 byte Ethernet link.
+const DefaultMTU = 1400
+
+// maxDatagramSize is the size of the buffer NaglePacketConn reads datagrams into.
+const maxDatagramSize = 64 << 10
+
+var (
+	// ErrMessageTooLarge is returned by NaglePacketConn.WriteTo when a message does not fit in a datagram.
+	ErrMessageTooLarge = errors.New("nagle: message too large")
+	// ErrMalformedDatagram is returned by NaglePacketConn.ReadFrom when a datagram is not a valid batch.
+	ErrMalformedDatagram = errors.New("nagle: malformed datagram")
+)
+
+// WithMTU sets the largest datagram a NaglePacketConn sends. It has no effect on stream wrappers.
+func WithMTU(n int) Option {
+	return func(o *options) {
+		o.mtu = n
+	}
+}
+
+// NaglePacketConn wraps a net.PacketConn, coalescing small messages sent to the same
+// address into a single datagram. Each message is prefixed with its length as an
+// unsigned varint, so the peer must read with a NaglePacketConn too. A batch is sent
+// when the next message would not fit in the MTU set with WithMTU, or when the flush
+// timeout has passed since its first message was buffered.
+type NaglePacketConn struct {
+	net.PacketConn
+	mtu          int
+	flushTimeout time.Duration
+	scheduler    timerScheduler
+	onError      func(error)
+
+	mutex   sync.Mutex
+	batches map[string]*packetBatch
+	closed  bool
+
+	readMutex sync.Mutex
+	datagram  []byte
+	unread    []byte
+	from      net.Addr
+}
+
+var _ net.PacketConn = (*NaglePacketConn)(nil)
+
+// packetBatch holds the messages waiting to be sent to one address.
+type packetBatch struct {
+	addr  net.Addr
+	buf   []byte
+	timer Timer
+}
+
+// NewPacketConn creates a new net.PacketConn wrapper that coalesces messages into datagrams.
+// WithFlushTimeout, WithMTU, WithClock, WithScheduler and WithOnError apply to it.
+func NewPacketConn(pc net.PacketConn, opts ...Option) *NaglePacketConn {
+	o := buildOptions(opts)
+	c := &NaglePacketConn{
+		PacketConn:   pc,
+		mtu:          o.mtu,
+		flushTimeout: o.flushTimeout,
+		scheduler:    o.clock,
+		onError:      o.onError,
+		batches:      make(map[string]*packetBatch),
+	}
+	if o.scheduler != nil {
+		c.scheduler = o.scheduler
+	}
+	return c
+}
+
+// WriteTo buffers p as a message for addr, sending the batch for addr first if p does not fit in it.
+func (c *NaglePacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	size := len(binary.AppendUvarint(nil, uint64(len(p)))) + len(p)
+	if size > c.mtu {
+		return 0, ErrMessageTooLarge
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return 0, ErrClosed
+	}
+
+	key := addr.String()
+	batch := c.batches[key]
+	if batch != nil && len(batch.buf)+size > c.mtu {
+		if err := c.sendLocked(key, batch); err != nil {
+			return 0, err
+		}
+		batch = nil
+	}
+	if batch == nil {
+		batch = &packetBatch{addr: addr, buf: make([]byte, 0, c.mtu)}
+		c.batches[key] = batch
+		batch.timer = c.scheduler.AfterFunc(c.flushTimeout, func() {
+			c.handleFlush(key, batch)
+		})
+	}
+	batch.buf = binary.AppendUvarint(batch.buf, uint64(len(p)))
+	batch.buf = append(batch.buf, p...)
+
+	if len(batch.buf) == c.mtu {
+		if err := c.sendLocked(key, batch); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// ReadFrom returns the next message, reading a new datagram once all the messages of
+// the previous one have been returned. A message larger than p is truncated.
+func (c *NaglePacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	for len(c.unread) == 0 {
+		if c.datagram == nil {
+			c.datagram = make([]byte, maxDatagramSize)
+		}
+		n, addr, err := c.PacketConn.ReadFrom(c.datagram)
+		if err != nil {
+			return 0, addr, err
+		}
+		c.unread, c.from = c.datagram[:n], addr
+	}
+
+	size, header := binary.Uvarint(c.unread)
+	if header <= 0 || size > uint64(len(c.unread)-header) {
+		c.unread = nil
+		return 0, c.from, ErrMalformedDatagram
+	}
+	message := c.unread[header : header+int(size)]
+	c.unread = c.unread[header+int(size):]
+	return copy(p, message), c.from, nil
+}
+
+// Flush sends every buffered batch immediately.
+func (c *NaglePacketConn) Flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	return c.flushAllLocked()
+}
+
+// Close sends every buffered batch and closes the underlying connection.
+func (c *NaglePacketConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	c.closed = true
+	err := c.flushAllLocked()
+	if closeErr := c.PacketConn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *NaglePacketConn) flushAllLocked() error {
+	var err error
+	for key, batch := range c.batches {
+		if sendErr := c.sendLocked(key, batch); err == nil {
+			err = sendErr
+		}
+	}
+	return err
+}
+
+// sendLocked sends batch as one datagram and forgets it, so idle addresses are not
+// kept around. A batch that fails to send is dropped, as datagrams may be anyway.
+func (c *NaglePacketConn) sendLocked(key string, batch *packetBatch) error {
+	batch.timer.Stop()
+	delete(c.batches, key)
+	_, err := c.PacketConn.WriteTo(batch.buf, batch.addr)
+	return err
+}
+
+func (c *NaglePacketConn) handleFlush(key string, batch *packetBatch) {
+	c.mutex.Lock()
+	var err error
+	if !c.closed && c.batches[key] == batch {
+		err = c.sendLocked(key, batch)
+	}
+	onError := c.onError
+	c.mutex.Unlock()
+
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
@@ -0,0 +1,71 @@
+package nagle
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenUDP(t *testing.T) net.PacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return pc
+}
+
+func TestNaglePacketConn_Coalescing(t *testing.T) {
+	receiver := listenUDP(t)
+	defer receiver.Close()
+	sender := NewPacketConn(listenUDP(t), WithFlushTimeout(20*time.Millisecond))
+	defer sender.Close()
+
+	sender.WriteTo([]byte("one"), receiver.LocalAddr())
+	sender.WriteTo([]byte("two"), receiver.LocalAddr())
+
+	// Both messages arrive in a single datagram once the timeout passes
+	datagram := make([]byte, 100)
+	receiver.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := receiver.ReadFrom(datagram)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(datagram[:n]) != "\x03one\x03two" {
+		t.Fatalf("expected a single batched datagram, got %q", datagram[:n])
+	}
+}
+
+func TestNaglePacketConn_RoundTrip(t *testing.T) {
+	receiver := NewPacketConn(listenUDP(t))
+	defer receiver.Close()
+	sender := NewPacketConn(listenUDP(t), WithFlushTimeout(time.Hour), WithMTU(10))
+	defer sender.Close()
+
+	// The third message does not fit in the MTU, sending the first two
+	for _, message := range []string{"abc", "def", "ghi"} {
+		if _, err := sender.WriteTo([]byte(message), receiver.LocalAddr()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := sender.WriteTo([]byte(strings.Repeat("x", 10)), receiver.LocalAddr()); !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, but got: %v", err)
+	}
+	sender.Flush()
+
+	receiver.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 10)
+	for _, expected := range []string{"abc", "def", "ghi"} {
+		n, addr, err := receiver.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(buf[:n]) != expected {
+			t.Fatalf("expected '%s', but got: '%s'", expected, string(buf[:n]))
+		}
+		if addr.String() != sender.LocalAddr().String() {
+			t.Fatalf("expected sender address %v, but got: %v", sender.LocalAddr(), addr)
+		}
+	}
+}
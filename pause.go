@@ -0,0 +1,90 @@
+package nagle
+
+import "time"
+
+// WithMaxPause bounds every Pause: the wrapper resumes on its own once paused for d,
+// so a pause that is never undone cannot hold the data back forever.
+func WithMaxPause(d time.Duration) Option {
+	return func(o *options) {
+		o.maxPause = d
+	}
+}
+
+// Pause stops all flushing until Resume, for instance while the underlying stream is
+// swapped or the destination is not ready yet. Writes keep being buffered, up to the
+// limit set with WithMaxPendingBytes or WithMaxBufferSize, over which they fail with
+// ErrBufferOverflow instead of blocking, unless a drop policy of WithOverflowPolicy
+// applies. Nothing reaches the underlying writer meanwhile: Flush returns with the data
+// still buffered, and WriteNoDelay and WriteUrgent buffer their data like Write. Close
+// resumes before its final flush. Pause calls do not nest.
+func (nw *NagleWriter) Pause() {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	if nw.paused || nw.closed {
+		return
+	}
+	nw.paused = true
+	nw.pauses++
+	if nw.maxPause > 0 {
+		pause := nw.pauses
+		nw.pauseTimer = nw.clock.AfterFunc(nw.maxPause, func() { nw.expirePause(pause) })
+	}
+}
+
+// Resume undoes Pause and writes everything buffered while paused, like Uncork.
+func (nw *NagleWriter) Resume() error {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed {
+		return ErrClosed
+	}
+	if !nw.paused {
+		return nil
+	}
+	return nw.resumeFlushLocked()
+}
+
+// expirePause resumes once WithMaxPause has passed, unless the pause numbered pause
+// has already been undone. The timer of an undone pause may have fired before Resume
+// stopped it, and must not end a later one.
+func (nw *NagleWriter) expirePause(pause uint64) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed || !nw.paused || nw.pauses != pause {
+		return
+	}
+	if err := nw.resumeFlushLocked(); err != nil && nw.asyncErr == nil {
+		nw.asyncErr = err
+	}
+}
+
+// resumeFlushLocked undoes Pause and flushes what was buffered meanwhile.
+func (nw *NagleWriter) resumeFlushLocked() error {
+	nw.resumeLocked()
+	if err := nw.takeAsyncErrLocked(); err != nil {
+		return err
+	}
+	_, err := nw.flushLocked(FlushTriggerExplicit)
+	return err
+}
+
+// Paused reports whether flushing is suspended by Pause.
+func (nw *NagleWriter) Paused() bool {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	return nw.paused
+}
+
+func (nw *NagleWriter) resumeLocked() {
+	nw.paused = false
+	if nw.pauseTimer != nil {
+		nw.pauseTimer.Stop()
+		nw.pauseTimer = nil
+	}
+}
@@ -0,0 +1,92 @@
+package nagle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_PauseResume(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour), WithMaxBufferSize(12))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Pause()
+	if !nagleWrapper.Paused() {
+		t.Fatal("expected the wrapper to be paused")
+	}
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Write([]byte("45"))
+	nagleWrapper.WriteUrgent([]byte("!"))
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "" {
+		t.Fatalf("expected nothing written while paused, but got: '%s'", mockRWC.String())
+	}
+
+	// Over the limit writes fail rather than wait for a flush that cannot happen
+	if _, err := nagleWrapper.Write([]byte("6789abc")); !errors.Is(err, ErrBufferOverflow) {
+		t.Fatalf("expected ErrBufferOverflow, but got: %v", err)
+	}
+
+	if err := nagleWrapper.Resume(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "012345!" {
+		t.Fatalf("expected '012345!', but got: '%s'", mockRWC.String())
+	}
+	nagleWrapper.Write([]byte("6789"))
+	if mockRWC.String() != "012345!6789" {
+		t.Fatalf("expected the size trigger to apply again, but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_WithMaxPause(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxPause(20*time.Millisecond))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Pause()
+	nagleWrapper.Write([]byte("0123"))
+	time.Sleep(50 * time.Millisecond)
+	if nagleWrapper.Paused() || mockRWC.String() != "0123" {
+		t.Fatalf("expected to resume on its own and flush '0123', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_WithMaxPauseStaleTimer(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithMaxPause(time.Hour))
+	defer nagleWrapper.Close()
+
+	// The timer of the first pause fires after Resume, while the second pause is on
+	nagleWrapper.Pause()
+	first := nagleWrapper.pauses
+	nagleWrapper.Resume()
+	nagleWrapper.Pause()
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.expirePause(first)
+	if !nagleWrapper.Paused() || mockRWC.String() != "" {
+		t.Fatalf("expected the stale timer to leave the pause on, but got: '%s'", mockRWC.String())
+	}
+
+	nagleWrapper.expirePause(nagleWrapper.pauses)
+	if nagleWrapper.Paused() || mockRWC.String() != "0123" {
+		t.Fatalf("expected to resume and flush '0123', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_CloseWhilePaused(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+
+	nagleWrapper.Pause()
+	nagleWrapper.Write([]byte("0123"))
+	if err := nagleWrapper.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "0123" {
+		t.Fatalf("expected Close to flush '0123', but got: '%s'", mockRWC.String())
+	}
+}
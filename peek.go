@@ -0,0 +1,24 @@
+package nagle
+
+// Snapshot returns a copy of the data buffered by Write and not yet flushed, for
+// debugging what is pending when a connection wedges. It is the data as written, before
+// any WithTransform or framing, and leaves out batches already handed to the writer,
+// such as those queued under WithAsyncFlush. It returns nil when nothing is pending.
+func (nw *NagleWriter) Snapshot() []byte {
+	return nw.Peek(-1)
+}
+
+// Peek is like Snapshot but copies at most the first n bytes. A negative n copies all of them.
+func (nw *NagleWriter) Peek(n int) []byte {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	if nw.closed {
+		return nil
+	}
+	buf := nw.buffer.Bytes()
+	if n >= 0 && n < len(buf) {
+		buf = buf[:n]
+	}
+	return append([]byte(nil), buf...)
+}
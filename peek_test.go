@@ -0,0 +1,37 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_Snapshot(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithBufferSize(100), WithFlushTimeout(time.Hour))
+
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.Write([]byte("4567"))
+	snapshot := nagleWrapper.Snapshot()
+	if string(snapshot) != "01234567" {
+		t.Fatalf("expected '01234567', but got: '%s'", snapshot)
+	}
+	if peek := nagleWrapper.Peek(3); string(peek) != "012" {
+		t.Fatalf("expected '012', but got: '%s'", peek)
+	}
+	if peek := nagleWrapper.Peek(100); string(peek) != "01234567" {
+		t.Fatalf("expected '01234567', but got: '%s'", peek)
+	}
+
+	// The result is a copy the buffer does not share
+	snapshot[0] = 'x'
+	if peek := nagleWrapper.Peek(1); string(peek) != "0" {
+		t.Fatalf("expected '0', but got: '%s'", peek)
+	}
+	nagleWrapper.Flush()
+	if snapshot := nagleWrapper.Snapshot(); snapshot != nil {
+		t.Fatalf("expected nothing pending after the flush, but got: '%s'", snapshot)
+	}
+	nagleWrapper.Close()
+	if snapshot := nagleWrapper.Snapshot(); snapshot != nil {
+		t.Fatalf("expected nothing pending after close, but got: '%s'", snapshot)
+	}
+}
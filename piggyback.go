@@ -0,0 +1,31 @@
+package nagle
+
+// WithFlushOnRead flushes the buffer whenever Read returns data from the peer, the way
+// a delayed ACK carries pending data: responses written while a request was being read
+// are coalesced and go out as soon as more of the conversation arrives, instead of
+// waiting for the flush timeout. Errors are reported like those of timeout flushes.
+func WithFlushOnRead() Option {
+	return func(o *options) {
+		o.flushOnRead = true
+	}
+}
+
+// piggybackFlush flushes the buffer after a Read under WithFlushOnRead.
+func (nw *NagleWriter) piggybackFlush() {
+	nw.mutex.Lock()
+	if nw.closed || nw.writeClosed || nw.corked || nw.pendingLocked() == 0 {
+		nw.unlock()
+		return
+	}
+
+	err := nw.autoFlushLocked(FlushTriggerRead)
+	if err != nil {
+		nw.asyncErr = err
+	}
+	onError := nw.onError
+	nw.unlock()
+
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
@@ -0,0 +1,40 @@
+package nagle
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithFlushOnRead(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleWrapper := New(client, WithBufferSize(100), WithFlushTimeout(time.Hour), WithFlushOnRead())
+	defer nagleWrapper.Close()
+
+	// A response written before the next request arrives stays buffered
+	nagleWrapper.Write([]byte("reply"))
+	if buffered := nagleWrapper.Buffered(); buffered != 5 {
+		t.Fatalf("expected 5 bytes buffered, but got: %d", buffered)
+	}
+
+	received := make(chan string, 1)
+	go func() {
+		server.Write([]byte("next"))
+		buf := make([]byte, 5)
+		io.ReadFull(server, buf)
+		received <- string(buf)
+	}()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(nagleWrapper, buf); err != nil || string(buf) != "next" {
+		t.Fatalf("expected to read 'next', but got: '%s', %v", buf, err)
+	}
+	if got := <-received; got != "reply" {
+		t.Fatalf("expected the peer to receive 'reply', but got: '%s'", got)
+	}
+	if stats := nagleWrapper.Stats(); stats.ReadFlushes != 1 {
+		t.Fatalf("expected 1 read flush, but got: %+v", stats)
+	}
+}
@@ -0,0 +1,13 @@
+package nagle
+
+import "net"
+
+// Pipe returns the two ends of an in-memory connection made by net.Pipe, each wrapped by
+// NewConn with opts, so protocol code can be tested against the coalescing of a real
+// connection without opening sockets. As with net.Pipe, there is no buffering between
+// the ends: a flush blocks until the other end has read the batch, or until a deadline
+// set on the writing end expires.
+func Pipe(opts ...Option) (*NagleConn, *NagleConn) {
+	c1, c2 := net.Pipe()
+	return NewConn(c1, opts...), NewConn(c2, opts...)
+}
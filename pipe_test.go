@@ -0,0 +1,38 @@
+package nagle
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPipe(t *testing.T) {
+	client, server := Pipe(WithBufferSize(10), WithFlushTimeout(20*time.Millisecond))
+	defer client.Close()
+	defer server.Close()
+
+	// Small writes reach the other end coalesced into a single flush
+	client.Write([]byte("012"))
+	client.Write([]byte("34"))
+
+	buf := make([]byte, 10)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(buf[:n]) != "01234" {
+		t.Fatalf("expected to read '01234', but got: '%s'", string(buf[:n]))
+	}
+	if flushes := client.Stats().Flushes(); flushes != 1 {
+		t.Fatalf("expected 1 flush, but got: %d", flushes)
+	}
+
+	// And the other way around
+	go func() {
+		server.Write([]byte("reply"))
+		server.Flush()
+	}()
+	if _, err := io.ReadFull(client, buf[:5]); err != nil || string(buf[:5]) != "reply" {
+		t.Fatalf("expected to read 'reply', but got: '%s', %v", string(buf[:5]), err)
+	}
+}
@@ -0,0 +1,34 @@
+package nagle
+
+// WithPreallocatedBuffer allocates the buffer once with room for n bytes, or for the
+// buffer size when n is zero or less, so it never reallocates in steady state. The
+// buffer then holds at most that many bytes: a Write that does not fit blocks while it
+// is flushed, as under WithMaxBufferSize, unless a limit set with WithMaxPendingBytes,
+// WithMaxBufferSize or WithOverflowPolicy already applies, in which case that limit is
+// lowered to the capacity if needed and keeps its policy. The spare buffers of
+// WithDoubleBuffer and WithAsyncFlush are grown the same way, and Stats reports the
+// capacity. Sockets under WithVectoredFlush queue their writes without copying them
+// and are not affected.
+func WithPreallocatedBuffer(n int) Option {
+	return func(o *options) {
+		o.preallocate = true
+		o.prealloc = n
+	}
+}
+
+// applyPrealloc resolves the capacity of WithPreallocatedBuffer and caps the buffer to it.
+func (o *options) applyPrealloc() {
+	if !o.preallocate {
+		return
+	}
+	if o.prealloc <= 0 {
+		o.prealloc = o.bufferSize
+	}
+	if o.maxPendingBytes == 0 {
+		o.blockOnFull = true
+		o.overflow = OverflowBlock
+	}
+	if o.maxPendingBytes == 0 || o.maxPendingBytes > o.prealloc {
+		o.maxPendingBytes = o.prealloc
+	}
+}
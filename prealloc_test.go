@@ -0,0 +1,39 @@
+package nagle
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNagleWriter_WithPreallocatedBuffer(t *testing.T) {
+	nagleWriter := NewWriter(io.Discard, WithBufferSize(16), WithFlushTimeout(time.Hour), WithPreallocatedBuffer(0))
+	defer nagleWriter.Close()
+
+	capacity := nagleWriter.Stats().Capacity
+	if capacity < 16 {
+		t.Fatalf("expected a capacity of at least 16, but got: %d", capacity)
+	}
+
+	// Writes crossing the capacity are split instead of growing the buffer
+	data := []byte("0123456789")
+	allocs := testing.AllocsPerRun(100, func() {
+		nagleWriter.Write(data)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, but got: %v", allocs)
+	}
+	if stats := nagleWriter.Stats(); stats.Capacity != capacity || stats.MaxBuffered > capacity {
+		t.Fatalf("expected the capacity to stay %d, but got: %+v", capacity, stats)
+	}
+}
+
+func TestNew_WithPreallocatedBufferKeepsLimit(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithMaxPendingBytes(100), WithPreallocatedBuffer(64))
+	defer nagleWrapper.Close()
+
+	// The lower capacity replaces the limit but not its policy
+	if nagleWrapper.maxPendingBytes != 64 || nagleWrapper.blockOnFull {
+		t.Fatalf("expected a non-blocking limit of 64, but got: %d, %v", nagleWrapper.maxPendingBytes, nagleWrapper.blockOnFull)
+	}
+}
@@ -0,0 +1,173 @@
+package nagle
+
+import "sync"
+
+// Producer stages the writes of one goroutine to a wrapper. Goroutines writing to the
+// same wrapper contend for its lock on every Write, which serializes them on the copy
+// into the buffer; with a Producer each, their writes are staged apart, under a lock
+// only the flushes share, and moved into the buffer in bulk, taking the wrapper lock
+// once per batch. A Producer must not be used by several goroutines at once.
+type Producer struct {
+	nw     *NagleWriter
+	mutex  sync.Mutex
+	staged []byte
+	limit  int
+	closed bool
+}
+
+// NewProducer returns a Producer writing to nw. Its writes are moved into the buffer
+// when they add up to a quarter of the buffer size, and by every flush, so the flush
+// timeout and Flush and Close cover them, while the size trigger only counts them once
+// moved. Each move is a single Write, so the options that act on every Write, such as
+// WithMessageFraming and WithMaxPendingWrites, see the staged writes as one. Producers
+// are released by Close, or earlier with their own Close.
+func (nw *NagleWriter) NewProducer() *Producer {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	p := &Producer{nw: nw, limit: max(nw.bufferSize/4, 1), closed: nw.closed}
+	if !p.closed {
+		nw.stagers = append(nw.stagers, p)
+	}
+	return p
+}
+
+// Write stages data, moving the staged writes into the wrapper when they reach the
+// limit. An error of the move is returned with nothing written.
+func (p *Producer) Write(data []byte) (int, error) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return 0, ErrClosed
+	}
+	first := len(p.staged) == 0
+	p.staged = append(p.staged, data...)
+	var batch []byte
+	if len(p.staged) >= p.limit {
+		batch = p.staged
+		p.staged = nil
+	}
+	p.mutex.Unlock()
+
+	if batch == nil {
+		if first {
+			p.nw.armProducersFlush()
+		}
+		return len(data), nil
+	}
+	_, err := p.nw.Write(batch)
+	p.mutex.Lock()
+	if p.staged == nil && !p.closed {
+		p.staged = batch[:0]
+	}
+	p.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Close moves the staged writes into the wrapper and releases the Producer, whose
+// later writes fail with ErrClosed.
+func (p *Producer) Close() error {
+	nw := p.nw
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	p.mutex.Lock()
+	closed, staged := p.closed, p.staged
+	p.closed = true
+	p.staged = nil
+	p.mutex.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+	nw.removeStagerLocked(p)
+	if len(staged) == 0 {
+		return nil
+	}
+	_, err := nw.writeLocked(staged)
+	return err
+}
+
+// stager is implemented by the types that hold writes back from the buffer until the
+// next flush, Producer and NagleQueue.
+type stager interface {
+	// drainLocked moves the staged writes into the buffer.
+	drainLocked()
+	// closeLocked discards the staged writes when the wrapper is closed.
+	closeLocked()
+}
+
+// removeStagerLocked forgets s, once it has been closed.
+func (nw *NagleWriter) removeStagerLocked(s stager) {
+	for i, t := range nw.stagers {
+		if t == s {
+			nw.stagers = append(nw.stagers[:i], nw.stagers[i+1:]...)
+			return
+		}
+	}
+}
+
+// armProducersFlush schedules the timeout flush for writes staged by a Producer or a
+// NagleQueue, unless it is already scheduled for the data in the buffer.
+func (nw *NagleWriter) armProducersFlush() {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	if !nw.closed && nw.pendingLocked() == 0 {
+		nw.armTimerLocked(nw.flushDelayLocked())
+	}
+}
+
+// drainProducersLocked moves the writes staged by the producers and queues into the
+// buffer, for the flush about to run. The flushes the moves trigger do not drain again.
+func (nw *NagleWriter) drainProducersLocked() {
+	if len(nw.stagers) == 0 || nw.draining || nw.closed {
+		return
+	}
+	nw.draining = true
+	defer func() { nw.draining = false }()
+
+	for _, s := range nw.stagers {
+		s.drainLocked()
+	}
+}
+
+func (p *Producer) drainLocked() {
+	p.mutex.Lock()
+	staged := p.staged
+	p.staged = nil
+	p.mutex.Unlock()
+
+	if len(staged) == 0 {
+		return
+	}
+	// Written without holding p.mutex: the write may release the wrapper lock under
+	// WithDoubleBuffer, and Close takes the two locks the other way around.
+	if _, err := p.nw.writeLocked(staged); err != nil && p.nw.asyncErr == nil {
+		p.nw.asyncErr = err
+	}
+	p.mutex.Lock()
+	if p.staged == nil && !p.closed {
+		p.staged = staged[:0]
+	}
+	p.mutex.Unlock()
+}
+
+func (p *Producer) closeLocked() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+	p.staged = nil
+}
+
+// closeProducersLocked releases the producers and queues when the wrapper is closed.
+func (nw *NagleWriter) closeProducersLocked() {
+	for _, s := range nw.stagers {
+		s.closeLocked()
+	}
+	nw.stagers = nil
+}
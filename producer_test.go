@@ -0,0 +1,126 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_Producer(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(16), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+	p := nagleWrapper.NewProducer()
+
+	// Writes are staged until they add up to a quarter of the buffer size
+	p.Write([]byte("012"))
+	if stats := nagleWrapper.Stats(); stats.Buffered != 0 {
+		t.Fatalf("expected nothing buffered, but got: %d", stats.Buffered)
+	}
+	p.Write([]byte("3"))
+	if stats := nagleWrapper.Stats(); stats.Buffered != 4 || stats.Writes != 1 {
+		t.Fatalf("expected 4 bytes buffered by 1 write, but got: %d by %d", stats.Buffered, stats.Writes)
+	}
+
+	// Flush covers the staged writes
+	p.Write([]byte("45"))
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "012345" {
+		t.Fatalf("expected '012345', but got: '%s'", mockRWC.String())
+	}
+
+	p.Write([]byte("6"))
+	if err := p.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.Write([]byte("7")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+	nagleWrapper.Flush()
+	if mockRWC.String() != "0123456" {
+		t.Fatalf("expected '0123456', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_ProducerTimeout(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(10*time.Millisecond))
+	defer nagleWrapper.Close()
+
+	p := nagleWrapper.NewProducer()
+	p.Write([]byte("012"))
+	time.Sleep(50 * time.Millisecond)
+	if mockRWC.String() != "012" {
+		t.Fatalf("expected staged writes to be flushed after the timeout, but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_ProducerDoubleBuffer(t *testing.T) {
+	mockRWC := &GatedReadWriteCloser{started: make(chan struct{}, 1), release: make(chan struct{})}
+	nagleWrapper := New(mockRWC, WithBufferSize(8), WithFlushTimeout(10*time.Millisecond), WithDoubleBuffer())
+
+	// The timeout flush drains the producer into a size flush, made with the lock released
+	nagleWrapper.Write([]byte("0123456"))
+	p := nagleWrapper.NewProducer()
+	p.Write([]byte("7"))
+	<-mockRWC.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- p.Close() }()
+	time.Sleep(10 * time.Millisecond)
+	close(mockRWC.release)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close deadlocked with the drain")
+	}
+	nagleWrapper.Close()
+	if writes := mockRWC.Writes(); len(writes) != 1 || writes[0] != "01234567" {
+		t.Fatalf("expected '01234567' to be written, but got: %q", writes)
+	}
+}
+
+func TestNagleWrapper_ProducersConcurrent(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(64), WithFlushTimeout(time.Millisecond))
+
+	const producers, writes = 16, 200
+	var wg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := nagleWrapper.NewProducer()
+			for j := 0; j < writes; j++ {
+				fmt.Fprintf(p, "%02d:%03d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := nagleWrapper.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Nothing is lost, and the writes of each producer stay in order
+	next := make([]int, producers)
+	lines := bytes.Split(bytes.TrimSuffix([]byte(mockRWC.String()), []byte("\n")), []byte("\n"))
+	if len(lines) != producers*writes {
+		t.Fatalf("expected %d lines, but got: %d", producers*writes, len(lines))
+	}
+	for _, line := range lines {
+		var i, j int
+		fmt.Sscanf(string(line), "%02d:%03d", &i, &j)
+		if j != next[i] {
+			t.Fatalf("expected write %d of producer %d, but got: %d", next[i], i, j)
+		}
+		next[i]++
+	}
+}
@@ -0,0 +1,50 @@
+package nagle
+
+import "time"
+
+// Profile is a preset of the buffering settings suited to a kind of link, selected with
+// WithProfile. A zero field keeps the default setting.
+type Profile struct {
+	// BufferSize is the flush threshold, as set by WithBufferSize.
+	BufferSize int
+	// FlushTimeout is the flush timeout, as set by WithFlushTimeout.
+	FlushTimeout time.Duration
+	// MaxFlushSize is the largest underlying write, as set by WithMaxFlushSize.
+	MaxFlushSize int
+}
+
+var (
+	// ProfileSerial9600 suits a serial line at 9600 baud, which sends about a byte per
+	// millisecond: small batches flushed soon, and large writes split so that none of
+	// them holds the line for long.
+	ProfileSerial9600 = Profile{BufferSize: 64, FlushTimeout: 20 * time.Millisecond, MaxFlushSize: 64}
+	// ProfileLAN suits a local network, where round trips are short enough that waiting
+	// long costs more than it saves.
+	ProfileLAN = Profile{BufferSize: 16 << 10, FlushTimeout: time.Millisecond}
+	// ProfileWAN suits an internet path, trading a few milliseconds for fewer segments.
+	ProfileWAN = Profile{BufferSize: 8 << 10, FlushTimeout: 10 * time.Millisecond}
+	// ProfileSatellite suits a high latency link, where a wait is small next to the
+	// round trip and large batches make the most of every window.
+	ProfileSatellite = Profile{BufferSize: 64 << 10, FlushTimeout: 50 * time.Millisecond}
+)
+
+// WithProfile configures the wrapper with the settings of p. Explicit options override
+// them, whether given before or after WithProfile.
+func WithProfile(p Profile) Option {
+	return func(o *options) {
+		o.profile = &p
+	}
+}
+
+// apply sets the settings of p on o.
+func (p Profile) apply(o *options) {
+	if p.BufferSize > 0 {
+		o.bufferSize = p.BufferSize
+	}
+	if p.FlushTimeout > 0 {
+		o.flushTimeout = p.FlushTimeout
+	}
+	if p.MaxFlushSize > 0 {
+		o.maxFlushSize = p.MaxFlushSize
+	}
+}
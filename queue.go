@@ -0,0 +1,225 @@
+package nagle
+
+import (
+	"encoding"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// NagleQueue holds typed messages for a wrapper and encodes them only when they are
+// flushed, so the messages dropped by its limit are never encoded, and those past
+// WithMessageTTL only to count their bytes in ExpiredBytes.
+// Each encoded message is written to the wrapper as a Write of its own, so options such
+// as WithMessageFraming and WithMaxPendingWrites apply to it as usual, and the
+// encodings are coalesced into batched writes like any other data. It is safe for
+// concurrent use.
+type NagleQueue[T any] struct {
+	nw      *NagleWriter
+	encode  func(T) ([]byte, error)
+	mutex   sync.Mutex
+	pending []queuedMessage[T]
+	limit   int
+	policy  OverflowPolicy
+	closed  bool
+}
+
+// queuedMessage is a message waiting in a NagleQueue and the time it was pushed.
+type queuedMessage[T any] struct {
+	msg T
+	at  time.Time
+}
+
+// NewQueue returns a queue writing to nw the messages encoded by encode or, when encode
+// is nil, by their MarshalBinary method. The messages are encoded by every flush, so
+// the flush timeout and Flush and Close cover them, and once WithMaxPendingWrites
+// messages are waiting; the size trigger only counts them once encoded. An encoding
+// error drops the message and is returned by the next call, like those of timeout
+// flushes. Queues are released by Close, or earlier with their own Close.
+func NewQueue[T any](nw *NagleWriter, encode func(T) []byte) *NagleQueue[T] {
+	q := &NagleQueue[T]{nw: nw, encode: marshalBinary[T]}
+	if encode != nil {
+		q.encode = func(msg T) ([]byte, error) { return encode(msg), nil }
+	}
+
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	q.closed = nw.closed
+	if !q.closed {
+		nw.stagers = append(nw.stagers, q)
+	}
+	return q
+}
+
+func marshalBinary[T any](msg T) ([]byte, error) {
+	m, ok := any(msg).(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("nagle: %T has no MarshalBinary method", msg)
+	}
+	return m.MarshalBinary()
+}
+
+// SetLimit caps the number of messages the queue may hold at n and sets what a Push
+// that does not fit does, as WithOverflowPolicy does for bytes: OverflowBlock encodes
+// the waiting messages into the wrapper to make room. Dropped messages are counted in
+// DroppedWrites. Zero, the default, means no limit.
+func (q *NagleQueue[T]) SetLimit(n int, policy OverflowPolicy) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.limit = n
+	q.policy = policy
+}
+
+// Len returns the number of messages waiting to be encoded.
+func (q *NagleQueue[T]) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.pending)
+}
+
+// Push queues msg. It fails with ErrBufferOverflow when the queue is full under
+// OverflowReject, and with ErrClosed once the queue or its wrapper is closed.
+func (q *NagleQueue[T]) Push(msg T) error {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return ErrClosed
+	}
+	dropped := 0
+	if q.limit > 0 && len(q.pending) >= q.limit {
+		switch q.policy {
+		case OverflowReject:
+			q.mutex.Unlock()
+			return ErrBufferOverflow
+		case OverflowDropNewest:
+			q.mutex.Unlock()
+			q.nw.countDropped(1)
+			return nil
+		case OverflowDropOldest:
+			dropped = len(q.pending) - q.limit + 1
+			clear(q.pending[:dropped])
+			q.pending = q.pending[dropped:]
+		case OverflowBlock:
+			q.mutex.Unlock()
+			if err := q.drain(); err != nil {
+				return err
+			}
+			q.mutex.Lock()
+		}
+	}
+	m := queuedMessage[T]{msg: msg}
+	if q.nw.ttl > 0 {
+		m.at = q.nw.clock.Now()
+	}
+	q.pending = append(q.pending, m)
+	first := len(q.pending) == 1
+	due := q.nw.maxWrites > 0 && len(q.pending) >= q.nw.maxWrites
+	q.mutex.Unlock()
+
+	if dropped > 0 {
+		q.nw.countDropped(dropped)
+	}
+	if due {
+		return q.drain()
+	}
+	if first {
+		q.nw.armProducersFlush()
+	}
+	return nil
+}
+
+// Close encodes the waiting messages into the wrapper and releases the queue, whose
+// later pushes fail with ErrClosed.
+func (q *NagleQueue[T]) Close() error {
+	nw := q.nw
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	q.mutex.Lock()
+	closed, pending := q.closed, q.pending
+	q.closed = true
+	q.pending = nil
+	q.mutex.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+	nw.removeStagerLocked(q)
+	return q.writeLocked(pending)
+}
+
+// drain encodes the waiting messages into the wrapper.
+func (q *NagleQueue[T]) drain() error {
+	q.nw.mutex.Lock()
+	defer q.nw.unlock()
+
+	if q.nw.closed {
+		return ErrClosed
+	}
+	return q.takeAndWriteLocked()
+}
+
+func (q *NagleQueue[T]) takeAndWriteLocked() error {
+	q.mutex.Lock()
+	pending := q.pending
+	q.pending = nil
+	q.mutex.Unlock()
+
+	return q.writeLocked(pending)
+}
+
+func (q *NagleQueue[T]) drainLocked() {
+	if err := q.takeAndWriteLocked(); err != nil && q.nw.asyncErr == nil {
+		q.nw.asyncErr = err
+	}
+}
+
+func (q *NagleQueue[T]) closeLocked() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.closed = true
+	q.pending = nil
+}
+
+// writeLocked encodes pending and writes each message to the wrapper, except for the
+// messages past WithMessageTTL, which are only counted. It returns the first error.
+func (q *NagleQueue[T]) writeLocked(pending []queuedMessage[T]) error {
+	nw := q.nw
+	var cutoff time.Time
+	if nw.ttl > 0 {
+		cutoff = nw.clock.Now().Add(-nw.ttl)
+	}
+	var first error
+	for _, m := range pending {
+		if m.at.Before(cutoff) {
+			nw.stats.ExpiredWrites++
+			if data, err := q.encode(m.msg); err == nil {
+				nw.stats.ExpiredBytes += int64(len(data))
+			}
+			continue
+		}
+		data, err := q.encode(m.msg)
+		if err == nil {
+			_, err = nw.writeLocked(data)
+		} else {
+			nw.log(slog.LevelWarn, "nagle: message encoding failed, message dropped", "error", err)
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// countDropped counts n messages discarded by a NagleQueue.
+func (nw *NagleWriter) countDropped(n int) {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	nw.stats.DroppedWrites += int64(n)
+}
@@ -0,0 +1,106 @@
+package nagle_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestNagleQueue_EncodesAtFlush(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(10*time.Millisecond), nagle.WithClock(clock))
+	defer nagleWriter.Close()
+
+	encoded := 0
+	queue := nagle.NewQueue(nagleWriter, func(n int) []byte {
+		encoded++
+		return []byte(strconv.Itoa(n))
+	})
+	for i := 1; i <= 3; i++ {
+		if err := queue.Push(i); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if encoded != 0 || queue.Len() != 3 {
+		t.Fatalf("expected 3 messages waiting unencoded, but got %d encoded and %d waiting", encoded, queue.Len())
+	}
+
+	// The flush timeout covers the queued messages
+	clock.Advance(10 * time.Millisecond)
+	if out.String() != "123" || encoded != 3 {
+		t.Fatalf("expected '123' after 3 encodings, but got: '%s' after %d", out.String(), encoded)
+	}
+	if stats := nagleWriter.Stats(); stats.Writes != 3 || stats.TimeoutFlushes != 1 {
+		t.Fatalf("expected 3 writes in 1 timeout flush, but got: %d and %d", stats.Writes, stats.TimeoutFlushes)
+	}
+}
+
+func TestNagleQueue_Dropped(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour), nagle.WithClock(clock),
+		nagle.WithMessageTTL(50*time.Millisecond))
+	defer nagleWriter.Close()
+
+	encoded := 0
+	queue := nagle.NewQueue(nagleWriter, func(s string) []byte {
+		encoded++
+		return []byte(s)
+	})
+	queue.SetLimit(2, nagle.OverflowDropOldest)
+	queue.Push("a")
+	queue.Push("b")
+	queue.Push("c")
+	clock.Advance(time.Second)
+	nagleWriter.Flush()
+	queue.Push("d")
+
+	// "a" is dropped by the limit without being encoded, and "b" and "c" by the TTL,
+	// encoded only to count their bytes
+	nagleWriter.Flush()
+	if out.String() != "d" || encoded != 3 {
+		t.Fatalf("expected 'd' after 3 encodings, but got: '%s' after %d", out.String(), encoded)
+	}
+	if stats := nagleWriter.Stats(); stats.DroppedWrites != 1 || stats.ExpiredWrites != 2 || stats.ExpiredBytes != 2 {
+		t.Fatalf("expected 1 dropped and 2 expired messages of 2 bytes, but got: %d, %d and %d", stats.DroppedWrites, stats.ExpiredWrites, stats.ExpiredBytes)
+	}
+
+	queue.SetLimit(1, nagle.OverflowReject)
+	queue.Push("e")
+	if err := queue.Push("f"); !errors.Is(err, nagle.ErrBufferOverflow) {
+		t.Fatalf("expected ErrBufferOverflow, but got: %v", err)
+	}
+	queue.Close()
+	if err := queue.Push("g"); !errors.Is(err, nagle.ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+	nagleWriter.Flush()
+	if out.String() != "de" {
+		t.Fatalf("expected 'de', but got: '%s'", out.String())
+	}
+}
+
+type point struct{ x, y byte }
+
+func (p point) MarshalBinary() ([]byte, error) {
+	return []byte{p.x, p.y}, nil
+}
+
+func TestNagleQueue_BinaryMarshaler(t *testing.T) {
+	var out bytes.Buffer
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour), nagle.WithMaxPendingWrites(2))
+	defer nagleWriter.Close()
+
+	queue := nagle.NewQueue[point](nagleWriter, nil)
+	queue.Push(point{1, 2})
+	queue.Push(point{3, 4})
+	if !bytes.Equal(out.Bytes(), []byte{1, 2, 3, 4}) {
+		t.Fatalf("expected the messages to be flushed by the count, but got: %v", out.Bytes())
+	}
+}
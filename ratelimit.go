@@ -0,0 +1,81 @@
+package nagle
+
+import (
+	"io"
+	"time"
+)
+
+// WithRateLimit paces the output with a token bucket refilled at bytesPerSec and
+// holding up to burst bytes: a flush waits for enough tokens before writing, and one
+// larger than burst is written in burst-sized pieces, so the underlying writer never
+// sees more than burst bytes at once. Waits happen while the wrapper lock is held, so
+// writers are held back along with the flush. A burst of zero or less allows one second
+// worth of data. Zero bytesPerSec, the default, disables pacing.
+func WithRateLimit(bytesPerSec int, burst int) Option {
+	return func(o *options) {
+		o.rateLimit = bytesPerSec
+		o.rateBurst = burst
+	}
+}
+
+// rateLimiter is a writer paced by a token bucket. Its writes are serialized by the wrapper lock.
+type rateLimiter struct {
+	w      io.Writer
+	clock  Clock
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(w io.Writer, clock Clock, bytesPerSec, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = bytesPerSec
+	}
+	return &rateLimiter{
+		w:      w,
+		clock:  clock,
+		rate:   float64(bytesPerSec),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+func (r *rateLimiter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		chunk := min(len(p), r.burst)
+		r.wait(chunk)
+		n, err := r.w.Write(p[:chunk])
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < chunk {
+			return total, io.ErrShortWrite
+		}
+		p = p[chunk:]
+	}
+	return total, nil
+}
+
+// wait blocks until n tokens are available and takes them.
+func (r *rateLimiter) wait(n int) {
+	r.refill()
+	if missing := float64(n) - r.tokens; missing > 0 {
+		timer := r.clock.NewTimer(time.Duration(missing / r.rate * float64(time.Second)))
+		<-timer.C()
+		r.refill()
+	}
+	r.tokens -= float64(n)
+}
+
+func (r *rateLimiter) refill() {
+	now := r.clock.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > float64(r.burst) {
+		r.tokens = float64(r.burst)
+	}
+	r.last = now
+}
@@ -0,0 +1,32 @@
+package nagle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithRateLimit(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(1000), WithFlushTimeout(time.Hour), WithRateLimit(10000, 100))
+	defer nagleWrapper.Close()
+
+	start := time.Now()
+	nagleWrapper.Write([]byte(strings.Repeat("x", 300)))
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The flush is split into burst-sized writes, the last two waiting for tokens
+	if len(mockRWC.writes) != 3 {
+		t.Fatalf("expected 3 writes, but got: %d", len(mockRWC.writes))
+	}
+	for _, w := range mockRWC.writes {
+		if len(w) > 100 {
+			t.Fatalf("expected writes of at most 100 bytes, but got: %d", len(w))
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Fatalf("expected the flush to be paced, but it took %v", elapsed)
+	}
+}
@@ -0,0 +1,126 @@
+package nagle
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// readFromChunkSize is the size of the chunks ReadFrom reads from its source when it
+// cannot read straight into the buffer.
+const readFromChunkSize = 32 << 10
+
+// chunkPool recycles the chunks used by ReadFrom, so io.Copy into a wrapper does not
+// allocate a copy buffer on every call.
+var chunkPool = sync.Pool{
+	New: func() any {
+		chunk := make([]byte, readFromChunkSize)
+		return &chunk
+	},
+}
+
+var _ io.ReaderFrom = (*NagleWriter)(nil)
+
+// ReadFrom implements io.ReaderFrom, so io.Copy into the wrapper reads r straight into
+// the spare room of the buffer, up to the buffer size, flushing at the usual thresholds.
+// Each read counts as one Write. These reads are made with the wrapper lock held, so a
+// read that blocks holds back timeout flushes and other writers until it returns. When
+// the data would not simply be appended to the buffer, as with a transform, producers,
+// reads large enough to be written around the buffer or the options that act on every
+// Write, r is read in chunks handed to Write instead, without holding the lock. It
+// returns the number of bytes read from r.
+func (nw *NagleWriter) ReadFrom(r io.Reader) (int64, error) {
+	chunk := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(chunk)
+
+	one := &singleRead{r: r}
+	var total int64
+	for {
+		n, direct, err := nw.readBufferedFrom(one, *chunk)
+		if !direct {
+			n, err = r.Read(*chunk)
+			if n > 0 {
+				var writeErr error
+				if n, writeErr = nw.Write((*chunk)[:n]); writeErr != nil {
+					return total + int64(n), writeErr
+				}
+			}
+		}
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// readBufferedFrom makes one read through one into the tail of the buffer and buffers
+// what it got like a Write. It reports false, without reading, when the read has to go
+// through chunk instead.
+func (nw *NagleWriter) readBufferedFrom(one *singleRead, chunk []byte) (int, bool, error) {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	buf, ok := nw.buffer.(*bytes.Buffer)
+	if !ok || !nw.readsDirectLocked(chunk) {
+		return 0, false, nil
+	}
+	if err := nw.writableLocked(); err != nil {
+		return 0, true, err
+	}
+
+	room := nw.bufferSize - buf.Len()
+	if room <= 0 {
+		room = readFromChunkSize
+	}
+	before := buf.Len()
+	buf.Grow(room)
+	// bytes.Buffer.ReadFrom reads into the buffer's own storage, saving the copy.
+	*one = singleRead{r: one.r, max: room}
+	buf.ReadFrom(one)
+	n := buf.Len() - before
+	if n == 0 {
+		return 0, true, one.err
+	}
+	nw.appendedLocked(buf.Bytes()[before:], before == 0)
+	nw.pendingWrites++
+	nw.stats.Writes++
+	if err := nw.triggerFlushLocked(before); err != nil {
+		return n, true, err
+	}
+	return n, true, one.err
+}
+
+// readsDirectLocked reports whether a read of ReadFrom can go straight into the buffer:
+// only when Write would simply append it, with no transform, producers or queues to
+// drain, no per-write framing, limits or bookkeeping, and no bypass of the buffer for chunk.
+func (nw *NagleWriter) readsDirectLocked(chunk []byte) bool {
+	if nw.transform != nil || len(nw.stagers) > 0 || nw.messagePrefix != FramePrefixNone || nw.messageMode || nw.tracksWrites() {
+		return false
+	}
+	if nw.memory != nil || nw.maxPendingBytes > 0 || nw.leaderDone != nil || nw.async != nil || nw.passThroughLocked() {
+		return false
+	}
+	return !nw.bypassLocked(chunk)
+}
+
+// singleRead makes a single read of up to max bytes from r, and then reports io.EOF to
+// end bytes.Buffer.ReadFrom. The error of the read is kept in err.
+type singleRead struct {
+	r    io.Reader
+	max  int
+	done bool
+	err  error
+}
+
+func (s *singleRead) Read(p []byte) (int, error) {
+	if s.done {
+		return 0, io.EOF
+	}
+	s.done = true
+	var n int
+	n, s.err = s.r.Read(p[:min(len(p), s.max)])
+	return n, nil
+}
@@ -0,0 +1,103 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_ReadFrom(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(64<<10), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	data := strings.Repeat("0123456789", 10000)
+	// Hide strings.Reader's WriteTo so io.Copy goes through ReadFrom
+	n, err := io.Copy(nagleWrapper, struct{ io.Reader }{strings.NewReader(data)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("expected %d bytes copied, but got: %d", len(data), n)
+	}
+
+	// Chunks are coalesced up to the buffer size
+	if len(mockRWC.writes) != 1 || len(mockRWC.writes[0]) < 64<<10 {
+		t.Fatalf("expected a single size-triggered write, but got %d writes", len(mockRWC.writes))
+	}
+	nagleWrapper.Flush()
+	if got := strings.Join(mockRWC.writes, ""); got != data {
+		t.Fatalf("expected data to be copied intact, got %d bytes", len(got))
+	}
+}
+
+// addrReader serves data in a single read and records the buffer it was read into.
+type addrReader struct {
+	data []byte
+	into []byte
+}
+
+func (r *addrReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	r.into = p
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestNagleWrapper_ReadFromIntoBuffer(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(64<<10), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	// The read lands in the buffer itself, not in a chunk copied into it
+	src := &addrReader{data: []byte("0123456789")}
+	if n, err := nagleWrapper.ReadFrom(src); err != nil || n != 10 {
+		t.Fatalf("expected 10 bytes read, but got: %d, %v", n, err)
+	}
+	buffered := nagleWrapper.buffer.(*bytes.Buffer).Bytes()
+	if string(buffered) != "0123456789" || &src.into[0] != &buffered[0] {
+		t.Fatalf("expected the data to be read straight into the buffer")
+	}
+
+	// A transform needs the chunk path
+	withTransform := New(&MockReadWriteCloser{}, WithBufferSize(64<<10), WithFlushTimeout(time.Hour),
+		WithTransform(func(batch []byte) ([]byte, error) { return batch, nil }))
+	defer withTransform.Close()
+	src = &addrReader{data: []byte("0123456789")}
+	if n, err := withTransform.ReadFrom(src); err != nil || n != 10 {
+		t.Fatalf("expected 10 bytes read, but got: %d, %v", n, err)
+	}
+	if len(src.into) != readFromChunkSize || withTransform.Buffered() != 10 {
+		t.Fatalf("expected a read into a chunk, but got a %d byte read", len(src.into))
+	}
+}
+
+func TestNagleWrapper_ReadFromError(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	readErr := errors.New("read failed")
+	src := io.MultiReader(bytes.NewReader([]byte("data")), &errReader{readErr})
+	n, err := nagleWrapper.ReadFrom(src)
+	if !errors.Is(err, readErr) {
+		t.Fatalf("expected %v, but got: %v", readErr, err)
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 bytes read before the error, but got: %d", n)
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,158 @@
+package nagle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// WithReconnect makes a flush or a read that fails with a connection error, such as a
+// reset or a broken pipe, dial a new stream with dial and carry on over it as
+// SwapUnderlying does, instead of failing: the part of the batch the old stream did not
+// take is written to the new one along with the data still buffered, and the read is
+// made again on it. Dials are retried as set by policy, with no limit when
+// policy.Attempts is zero; once they are used up, the error that started them is
+// returned as usual. The waits happen while the wrapper lock is held, like those of
+// WithFlushRetry, and stop when the wrapper is closed, which also cancels the context
+// given to dial. The old stream is closed once it has been replaced. Reads that end
+// with io.EOF are returned as is, since the peer closed the stream on purpose.
+func WithReconnect(dial func(context.Context) (io.ReadWriteCloser, error), policy RetryPolicy) Option {
+	return func(o *options) {
+		o.dial = dial
+		o.reconnect = policy
+	}
+}
+
+// reconnector dials the streams that replace a failed one under WithReconnect.
+type reconnector struct {
+	dial   func(context.Context) (io.ReadWriteCloser, error)
+	policy RetryPolicy
+	ctx    context.Context
+	cancel context.CancelFunc
+	// swap moves the wrapper to a dialed stream. It is replaced by the wrapper types
+	// that also read from the stream.
+	swap  func(io.ReadWriteCloser) error
+	count atomic.Int64
+}
+
+func newReconnector(nw *NagleWriter, o options) *reconnector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &reconnector{
+		dial:   o.dial,
+		policy: o.reconnect,
+		ctx:    ctx,
+		cancel: cancel,
+		swap: func(rwc io.ReadWriteCloser) error {
+			nw.rebaseLocked(rwc)
+			return nil
+		},
+	}
+}
+
+// reconnectWriter replaces w with a new stream when a write to it fails with a
+// connection error under WithReconnect.
+type reconnectWriter struct {
+	w  io.Writer
+	nw *NagleWriter
+}
+
+func (rw *reconnectWriter) next() *io.Writer { return &rw.w }
+
+func (rw *reconnectWriter) Write(p []byte) (int, error) {
+	total := 0
+	for {
+		// The swap points rw.w at the new stream.
+		n, err := rw.w.Write(p[total:])
+		total += n
+		if err == nil || !isConnError(err) || !rw.nw.redial(err) {
+			return total, err
+		}
+	}
+}
+
+// isConnError reports whether err means the stream is lost and a new one is needed.
+func isConnError(err error) bool {
+	return isFatal(err) && !errors.Is(err, io.EOF) && !errors.Is(err, ErrClosed)
+}
+
+// redial dials a new stream after cause, retrying as set by the policy, and moves the
+// wrapper to it. It is called with the writes to the stream held back, either by the
+// wrapper lock or by being the write in progress, and reports whether it succeeded.
+func (nw *NagleWriter) redial(cause error) bool {
+	rc := nw.reconnect
+	nw.log(slog.LevelWarn, "nagle: connection lost, reconnecting", "error", cause)
+	backoff := rc.policy.Backoff
+	for attempt := 1; rc.policy.Attempts == 0 || attempt <= rc.policy.Attempts; attempt++ {
+		if attempt > 1 {
+			if !rc.wait(nw.clock, backoff) {
+				return false
+			}
+			backoff *= 2
+			if rc.policy.MaxBackoff > 0 {
+				backoff = min(backoff, rc.policy.MaxBackoff)
+			}
+		}
+		rwc, err := rc.dial(rc.ctx)
+		if err == nil {
+			old := nw.base
+			if err = rc.swap(rwc); err == nil {
+				rc.count.Add(1)
+				nw.log(slog.LevelInfo, "nagle: reconnected", "attempt", attempt)
+				if c, ok := old.(io.Closer); ok {
+					c.Close()
+				}
+				return true
+			}
+			rwc.Close()
+		}
+		if rc.ctx.Err() != nil {
+			return false
+		}
+		nw.log(slog.LevelInfo, "nagle: reconnect failed", "attempt", attempt, "error", err)
+	}
+	return false
+}
+
+// wait waits for d, and reports whether it did so before the wrapper was closed.
+func (rc *reconnector) wait(clock Clock, d time.Duration) bool {
+	if d <= 0 {
+		return rc.ctx.Err() == nil
+	}
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-rc.ctx.Done():
+		return false
+	}
+}
+
+// reconnectRead moves the wrapper to a new stream after a read from src failed with err
+// under WithReconnect, unless another call already replaced src, and reports whether
+// the read should be made again. The data buffered meanwhile is flushed by the timer.
+func (nw *NagleWrapper) reconnectRead(src *readSource, err error) bool {
+	if nw.reconnect == nil || !isConnError(err) {
+		return false
+	}
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed {
+		return false
+	}
+	if nw.source.Load() != src {
+		return true
+	}
+	if !nw.redial(err) {
+		return false
+	}
+	if nw.pendingLocked() > 0 {
+		nw.armTimerLocked(0)
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package nagle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithReconnect(t *testing.T) {
+	fresh := &MockReadWriteCloser{}
+	dials := 0
+	dial := func(ctx context.Context) (io.ReadWriteCloser, error) {
+		dials++
+		if dials < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return fresh, nil
+	}
+	old := &FailingReadWriteCloser{err: syscall.ECONNRESET}
+	nagleWrapper := New(old, WithBufferSize(100), WithFlushTimeout(time.Hour), WithReconnect(dial, RetryPolicy{Attempts: 3}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fresh.String() != "0123" {
+		t.Fatalf("expected '0123' on the new stream, but got: '%s'", fresh.String())
+	}
+	if dials != 3 {
+		t.Fatalf("expected 3 dials, but got: %d", dials)
+	}
+	if _, err := old.MockReadWriteCloser.Read(nil); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected the old stream to be closed, but got: %v", err)
+	}
+	if stats := nagleWrapper.Stats(); stats.Reconnects != 1 {
+		t.Fatalf("expected 1 reconnect, but got: %d", stats.Reconnects)
+	}
+}
+
+func TestNagleWrapper_WithReconnectGivesUp(t *testing.T) {
+	dialErr := errors.New("connection refused")
+	dials := 0
+	dial := func(ctx context.Context) (io.ReadWriteCloser, error) {
+		dials++
+		return nil, dialErr
+	}
+	nagleWrapper := New(&FailingReadWriteCloser{err: syscall.EPIPE}, WithBufferSize(100), WithFlushTimeout(time.Hour), WithReconnect(dial, RetryPolicy{Attempts: 2}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, syscall.EPIPE) {
+		t.Fatalf("expected EPIPE, but got: %v", err)
+	}
+	if dials != 2 {
+		t.Fatalf("expected 2 dials, but got: %d", dials)
+	}
+}
+
+func TestNagleWrapper_WithReconnectRead(t *testing.T) {
+	fresh := &MockReadWriteCloser{}
+	fresh.Write([]byte("hello"))
+	dial := func(ctx context.Context) (io.ReadWriteCloser, error) {
+		return fresh, nil
+	}
+	old := &MockReadWriteCloser{}
+	old.Close()
+	nagleWrapper := New(old, WithBufferSize(100), WithFlushTimeout(time.Hour), WithReconnect(dial, RetryPolicy{}))
+	defer nagleWrapper.Close()
+
+	p := make([]byte, 10)
+	n, err := nagleWrapper.Read(p)
+	if err != nil || string(p[:n]) != "hello" {
+		t.Fatalf("expected to read 'hello' from the new stream, but got: '%s', %v", p[:n], err)
+	}
+
+	// The peer closing the stream is not a failure
+	if _, err := nagleWrapper.Read(p); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, but got: %v", err)
+	}
+}
+
+func TestNagleWrapper_WithReconnectClose(t *testing.T) {
+	dialed := make(chan struct{}, 1)
+	dial := func(ctx context.Context) (io.ReadWriteCloser, error) {
+		select {
+		case dialed <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	nagleWrapper := New(&FailingReadWriteCloser{err: syscall.ECONNRESET}, WithBufferSize(100), WithFlushTimeout(time.Hour), WithReconnect(dial, RetryPolicy{}))
+
+	nagleWrapper.Write([]byte("0123"))
+	flushed := make(chan error, 1)
+	go func() { flushed <- nagleWrapper.Flush() }()
+	<-dialed
+
+	// Close stops the reconnect that holds the lock
+	nagleWrapper.Close()
+	select {
+	case err := <-flushed:
+		if !errors.Is(err, syscall.ECONNRESET) {
+			t.Fatalf("expected ECONNRESET, but got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reconnect was not stopped by Close")
+	}
+}
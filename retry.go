@@ -0,0 +1,78 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how WithFlushRetry retries flush writes that fail with a
+// transient error.
+type RetryPolicy struct {
+	// Attempts is the number of retries made before the error is returned.
+	Attempts int
+	// Backoff is the wait before the first retry. It doubles with every retry.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between retries. Zero means no cap.
+	MaxBackoff time.Duration
+}
+
+// WithFlushRetry retries the writes of flushes that fail with a transient error, such
+// as a timeout, EINTR or EAGAIN, or that are cut short, waiting as set by policy. Only
+// the bytes not yet written are retried, so the buffered data stays intact and is
+// sent once. The waits happen while the wrapper lock is held, like those of
+// WithRateLimit. Once the attempts are used up the error is returned as usual.
+func WithFlushRetry(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// retryWriter retries the transient failures of writes to w under WithFlushRetry.
+type retryWriter struct {
+	w       io.Writer
+	clock   Clock
+	policy  RetryPolicy
+	logger  *slog.Logger
+	retries atomic.Int64
+}
+
+func (rw *retryWriter) Write(p []byte) (int, error) {
+	total := 0
+	backoff := rw.policy.Backoff
+	for attempt := 0; ; attempt++ {
+		n, err := rw.w.Write(p[total:])
+		total += n
+		if err == nil && total < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err == nil || attempt >= rw.policy.Attempts || !isTransient(err) {
+			return total, err
+		}
+
+		rw.retries.Add(1)
+		if rw.logger != nil {
+			rw.logger.Info("nagle: retrying flush write", "attempt", attempt+1, "backoff", backoff, "unsent", len(p)-total, "error", err)
+		}
+		if backoff > 0 {
+			<-rw.clock.NewTimer(backoff).C()
+		}
+		backoff *= 2
+		if rw.policy.MaxBackoff > 0 {
+			backoff = min(backoff, rw.policy.MaxBackoff)
+		}
+	}
+}
+
+// isTransient reports whether a write failing with err may succeed if retried.
+func isTransient(err error) bool {
+	if errors.Is(err, io.ErrShortWrite) || errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,79 @@
+package nagle
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// FlakyReadWriteCloser fails its first writes with err after writing half of the data.
+type FlakyReadWriteCloser struct {
+	MockReadWriteCloser
+	failures int
+	err      error
+}
+
+func (f *FlakyReadWriteCloser) Write(p []byte) (int, error) {
+	if f.failures > 0 {
+		f.failures--
+		n, _ := f.MockReadWriteCloser.Write(p[:len(p)/2])
+		return n, f.err
+	}
+	return f.MockReadWriteCloser.Write(p)
+}
+
+func TestNagleWrapper_FlushRetry(t *testing.T) {
+	mockRWC := &FlakyReadWriteCloser{failures: 2, err: os.ErrDeadlineExceeded}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithFlushRetry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01234567"))
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Only the bytes left over by each failure are sent again
+	if mockRWC.String() != "01234567" {
+		t.Fatalf("expected '01234567', but got: '%s'", mockRWC.String())
+	}
+	if stats := nagleWrapper.Stats(); stats.FlushRetries != 2 {
+		t.Fatalf("expected 2 flush retries, but got: %d", stats.FlushRetries)
+	}
+}
+
+func TestNagleWrapper_FlushRetryExhausted(t *testing.T) {
+	mockRWC := &FlakyReadWriteCloser{failures: 3, err: syscall.EAGAIN}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithFlushRetry(RetryPolicy{Attempts: 2}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01234567"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("expected EAGAIN, but got: %v", err)
+	}
+	// The rest stays buffered for the next flush
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "01234567" {
+		t.Fatalf("expected '01234567', but got: '%s'", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_FlushRetryPermanentError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	mockRWC := &FlakyReadWriteCloser{failures: 1, err: writeErr}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour),
+		WithFlushRetry(RetryPolicy{Attempts: 3}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, writeErr) {
+		t.Fatalf("expected %v, but got: %v", writeErr, err)
+	}
+	if stats := nagleWrapper.Stats(); stats.FlushRetries != 0 {
+		t.Fatalf("expected no flush retries, but got: %d", stats.FlushRetries)
+	}
+}
@@ -0,0 +1,179 @@
+package nagle
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// schedulerSlots is the number of slots in the timing wheel of a FlushScheduler.
+const schedulerSlots = 512
+
+// defaultSchedulerTick is the tick of a FlushScheduler created with no positive tick.
+const defaultSchedulerTick = time.Millisecond
+
+// FlushScheduler runs the flush timers of many wrappers on a hashed timing wheel
+// driven by a single ticker, with flushes executed by a fixed pool of workers.
+// Timers fire on tick boundaries, never earlier than requested, so the tick is the
+// resolution of every flush timeout scheduled on it. A flush that blocks on a slow
+// writer occupies a worker, so size the pool for the expected number of stuck peers.
+type FlushScheduler struct {
+	tick     time.Duration
+	mutex    sync.Mutex
+	slots    []*list.List
+	current  int
+	work     chan func()
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+var _ timerScheduler = (*FlushScheduler)(nil)
+
+// NewFlushScheduler creates a scheduler advancing every tick with the given number of flush workers.
+// A tick that is not positive is taken as 1ms, and fewer than one worker as one.
+func NewFlushScheduler(tick time.Duration, workers int) *FlushScheduler {
+	if tick <= 0 {
+		tick = defaultSchedulerTick
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	s := &FlushScheduler{
+		tick:  tick,
+		slots: make([]*list.List, schedulerSlots),
+		work:  make(chan func(), workers),
+		done:  make(chan struct{}),
+	}
+	for i := range s.slots {
+		s.slots[i] = list.New()
+	}
+
+	s.wg.Add(1 + workers)
+	go s.run()
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+// AfterFunc schedules f to run on a worker once d has elapsed.
+func (s *FlushScheduler) AfterFunc(d time.Duration, f func()) Timer {
+	t := &scheduledTimer{scheduler: s, fn: f}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.scheduleLocked(t, d)
+	return t
+}
+
+// Stop stops the ticker and the workers. Timers still pending never fire, so
+// wrappers using the scheduler should be closed first.
+func (s *FlushScheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+}
+
+func (s *FlushScheduler) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			close(s.work)
+			return
+		case <-ticker.C:
+			s.advance()
+		}
+	}
+}
+
+func (s *FlushScheduler) worker() {
+	defer s.wg.Done()
+	for fn := range s.work {
+		fn()
+	}
+}
+
+// advance moves the wheel one slot and hands the timers expiring there to the workers.
+func (s *FlushScheduler) advance() {
+	s.mutex.Lock()
+	s.current = (s.current + 1) % len(s.slots)
+	slot := s.slots[s.current]
+	var expired []func()
+	for e := slot.Front(); e != nil; {
+		next := e.Next()
+		t := e.Value.(*scheduledTimer)
+		if t.rounds > 0 {
+			t.rounds--
+		} else {
+			slot.Remove(e)
+			t.elem = nil
+			expired = append(expired, t.fn)
+		}
+		e = next
+	}
+	s.mutex.Unlock()
+
+	for _, fn := range expired {
+		select {
+		case s.work <- fn:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *FlushScheduler) scheduleLocked(t *scheduledTimer, d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	// The current tick is partly over, so wait one extra tick, on top of d rounded up
+	// to whole ticks, to never fire early.
+	ticks := int((d+s.tick-1)/s.tick) + 1
+	t.slot = (s.current + ticks) % len(s.slots)
+	t.rounds = (ticks - 1) / len(s.slots)
+	t.elem = s.slots[t.slot].PushBack(t)
+}
+
+// scheduledTimer is a Timer living in a FlushScheduler wheel slot.
+type scheduledTimer struct {
+	scheduler *FlushScheduler
+	fn        func()
+	elem      *list.Element
+	slot      int
+	rounds    int
+}
+
+func (t *scheduledTimer) C() <-chan time.Time {
+	return nil
+}
+
+func (t *scheduledTimer) Stop() bool {
+	t.scheduler.mutex.Lock()
+	defer t.scheduler.mutex.Unlock()
+
+	return t.stopLocked()
+}
+
+func (t *scheduledTimer) Reset(d time.Duration) bool {
+	t.scheduler.mutex.Lock()
+	defer t.scheduler.mutex.Unlock()
+
+	active := t.stopLocked()
+	t.scheduler.scheduleLocked(t, d)
+	return active
+}
+
+func (t *scheduledTimer) stopLocked() bool {
+	if t.elem == nil {
+		return false
+	}
+	t.scheduler.slots[t.slot].Remove(t.elem)
+	t.elem = nil
+	return true
+}
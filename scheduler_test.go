@@ -0,0 +1,119 @@
+package nagle
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlushScheduler_AfterFunc(t *testing.T) {
+	s := NewFlushScheduler(time.Millisecond, 2)
+	defer s.Stop()
+
+	start := time.Now()
+	fired := make(chan time.Duration, 1)
+	s.AfterFunc(20*time.Millisecond, func() { fired <- time.Since(start) })
+
+	var stoppedFired atomic.Bool
+	stopped := s.AfterFunc(10*time.Millisecond, func() { stoppedFired.Store(true) })
+	if !stopped.Stop() {
+		t.Fatal("expected Stop to report an active timer")
+	}
+
+	select {
+	case elapsed := <-fired:
+		if elapsed < 20*time.Millisecond {
+			t.Fatalf("timer fired early after %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+	if stoppedFired.Load() {
+		t.Fatal("stopped timer fired")
+	}
+}
+
+func TestFlushScheduler_Defaults(t *testing.T) {
+	s := NewFlushScheduler(0, 0)
+	defer s.Stop()
+
+	if s.tick != defaultSchedulerTick || cap(s.work) != 1 {
+		t.Fatalf("expected a %v tick and 1 worker, but got: %v and %d", defaultSchedulerTick, s.tick, cap(s.work))
+	}
+	fired := make(chan struct{}, 1)
+	s.AfterFunc(time.Millisecond, func() { fired <- struct{}{} })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+}
+
+func TestFlushScheduler_LongTimeout(t *testing.T) {
+	// The ticker never fires on its own, so the wheel is driven by hand
+	s := NewFlushScheduler(time.Hour, 1)
+	defer s.Stop()
+
+	// Three full turns of the wheel
+	fired := make(chan struct{}, 1)
+	s.AfterFunc(schedulerSlots*3*time.Hour, func() { fired <- struct{}{} })
+
+	for i := 0; i < schedulerSlots*3; i++ {
+		s.advance()
+	}
+	select {
+	case <-fired:
+		t.Fatal("timer fired early")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.advance()
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+}
+
+func TestFlushScheduler_FractionalTimeout(t *testing.T) {
+	// The ticker never fires on its own, so the wheel is driven by hand
+	s := NewFlushScheduler(time.Hour, 1)
+	defer s.Stop()
+
+	// Two advances may be just over one tick away, short of the 1.5 ticks requested
+	fired := make(chan struct{}, 1)
+	s.AfterFunc(90*time.Minute, func() { fired <- struct{}{} })
+	s.advance()
+	s.advance()
+	select {
+	case <-fired:
+		t.Fatal("timer fired early")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	s.advance()
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+}
+
+func TestNagleWrapper_WithScheduler(t *testing.T) {
+	s := NewFlushScheduler(time.Millisecond, 2)
+	defer s.Stop()
+
+	wrappers := make([]*NagleWrapper, 50)
+	for i := range wrappers {
+		wrappers[i] = New(&MockReadWriteCloser{}, WithBufferSize(100), WithFlushTimeout(5*time.Millisecond), WithScheduler(s))
+		wrappers[i].Write([]byte("01234"))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	for i, w := range wrappers {
+		if stats := w.Stats(); stats.TimeoutFlushes != 1 {
+			t.Fatalf("wrapper %d: expected 1 timeout flush, got %d", i, stats.TimeoutFlushes)
+		}
+		w.Close()
+	}
+}
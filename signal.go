@@ -0,0 +1,148 @@
+package nagle
+
+import (
+	"io"
+	"sync"
+)
+
+// FlushSignal flushes a set of wrappers together every time an external event fires,
+// such as the tick of a time.Ticker or the end of a batch announced by a coordinator,
+// giving many connections a shared "end of batch" boundary.
+type FlushSignal struct {
+	mutex   sync.Mutex
+	members map[*NagleWriter]struct{}
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// FlushOn starts a FlushSignal that flushes its wrappers each time a value is received
+// from ch, until ch is closed or Stop is called. The wrappers are flushed concurrently,
+// and the next value is not received before all of them are done. Corked wrappers are
+// skipped, and errors are reported like those of timeout flushes.
+func FlushOn[T any](ch <-chan T) *FlushSignal {
+	s := &FlushSignal{members: make(map[*NagleWriter]struct{}), stop: make(chan struct{})}
+	go runFlushSignal(s, ch)
+	return s
+}
+
+// Option returns the option that adds the wrappers created with it to s.
+func (s *FlushSignal) Option() Option {
+	return func(o *options) {
+		o.signals = append(o.signals, s)
+	}
+}
+
+// Add adds w, a wrapper created by this package, to the wrappers flushed by s. It is
+// removed again when it is closed.
+func (s *FlushSignal) Add(w io.Writer) {
+	nw := nagleWriterOf(w)
+	if nw == nil {
+		return
+	}
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	if !nw.closed {
+		nw.signals = append(nw.signals, s)
+		s.add(nw)
+	}
+}
+
+// Remove stops s from flushing w.
+func (s *FlushSignal) Remove(w io.Writer) {
+	if nw := nagleWriterOf(w); nw != nil {
+		s.remove(nw)
+	}
+}
+
+// Len returns the number of wrappers flushed by s.
+func (s *FlushSignal) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.members)
+}
+
+// Stop stops s. The wrappers are left as they are.
+func (s *FlushSignal) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *FlushSignal) add(nw *NagleWriter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.members[nw] = struct{}{}
+}
+
+func (s *FlushSignal) remove(nw *NagleWriter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.members, nw)
+}
+
+// fire flushes every wrapper of s concurrently and waits for them.
+func (s *FlushSignal) fire() {
+	s.mutex.Lock()
+	members := make([]*NagleWriter, 0, len(s.members))
+	for nw := range s.members {
+		members = append(members, nw)
+	}
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, nw := range members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nw.signalFlush()
+		}()
+	}
+	wg.Wait()
+}
+
+// runFlushSignal receives the events of ch for s.
+func runFlushSignal[T any](s *FlushSignal, ch <-chan T) {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+			s.fire()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// signalFlush flushes the buffer for a FlushSignal.
+func (nw *NagleWriter) signalFlush() {
+	nw.mutex.Lock()
+	if nw.closed || nw.writeClosed || nw.corked || nw.pendingLocked() == 0 {
+		nw.unlock()
+		return
+	}
+
+	err := nw.autoFlushLocked(FlushTriggerSignal)
+	if err != nil {
+		nw.asyncErr = err
+	}
+	onError := nw.onError
+	nw.unlock()
+
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
+
+// leaveSignalsLocked removes a closed wrapper from its FlushSignals.
+func (nw *NagleWriter) leaveSignalsLocked() {
+	for _, s := range nw.signals {
+		s.remove(nw)
+	}
+	nw.signals = nil
+}
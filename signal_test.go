@@ -0,0 +1,61 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlushOn(t *testing.T) {
+	events := make(chan struct{})
+	signal := FlushOn(events)
+	defer signal.Stop()
+
+	first := &MockReadWriteCloser{}
+	second := &MockReadWriteCloser{}
+	w1 := New(first, WithBufferSize(100), WithFlushTimeout(time.Hour), signal.Option())
+	w2 := New(second, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	signal.Add(w2)
+	if signal.Len() != 2 {
+		t.Fatalf("expected 2 wrappers, but got: %d", signal.Len())
+	}
+
+	w1.Write([]byte("ab"))
+	w2.Write([]byte("cd"))
+	// The next send waits for the flushes started by this one
+	events <- struct{}{}
+	events <- struct{}{}
+	if first.String() != "ab" || second.String() != "cd" {
+		t.Fatalf("expected both wrappers flushed, but got: '%s' and '%s'", first.String(), second.String())
+	}
+	if stats := w1.Stats(); stats.SignalFlushes != 1 {
+		t.Fatalf("expected 1 signal flush, but got: %d", stats.SignalFlushes)
+	}
+
+	// Closed wrappers leave the signal
+	w1.Close()
+	signal.Remove(w2)
+	if signal.Len() != 0 {
+		t.Fatalf("expected no wrappers, but got: %d", signal.Len())
+	}
+	w2.Close()
+}
+
+func TestFlushOn_Ticker(t *testing.T) {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	signal := FlushOn(ticker.C)
+	defer signal.Stop()
+
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), signal.Option())
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("ab"))
+	deadline := time.Now().Add(time.Second)
+	for mockRWC.String() != "ab" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the ticker to flush the wrapper")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,15 @@
+package nagle
+
+import "time"
+
+// WithSoftBufferSize adds a soft threshold below the buffer size: once n bytes are
+// buffered the flush is scheduled delay after the last Write instead of after the flush
+// timeout, which catches the writes that trail it, like a body following its header,
+// without waiting the full timeout. The buffer size stays the hard threshold that
+// flushes at once. These flushes are reported as timeout flushes. Zero disables it.
+func WithSoftBufferSize(n int, delay time.Duration) Option {
+	return func(o *options) {
+		o.softSize = n
+		o.softDelay = delay
+	}
+}
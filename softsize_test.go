@@ -0,0 +1,42 @@
+package nagle_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestNagleWriter_WithSoftBufferSize(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour),
+		nagle.WithClock(clock), nagle.WithSoftBufferSize(8, time.Millisecond))
+	defer nagleWriter.Close()
+
+	// Below the soft size the flush timeout applies
+	nagleWriter.Write([]byte("head"))
+	clock.Advance(time.Millisecond)
+	if out.Len() != 0 {
+		t.Fatalf("expected nothing flushed, but got: '%s'", out.String())
+	}
+
+	// Past it, the write that trails the header is still caught
+	nagleWriter.Write([]byte("header"))
+	nagleWriter.Write([]byte("body"))
+	if out.Len() != 0 {
+		t.Fatalf("expected nothing flushed before the delay, but got: '%s'", out.String())
+	}
+	clock.Advance(time.Millisecond)
+	if out.String() != "headheaderbody" {
+		t.Fatalf("expected a flush after the short delay, but got: '%s'", out.String())
+	}
+
+	// The buffer size still flushes at once
+	nagleWriter.Write(bytes.Repeat([]byte("x"), 100))
+	if out.Len() != 114 {
+		t.Fatalf("expected a size flush, but got %d bytes", out.Len())
+	}
+}
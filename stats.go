@@ -0,0 +1,266 @@
+package nagle
+
+import (
+	"bytes"
+	"time"
+)
+
+// FlushTrigger identifies what caused a flush.
+type FlushTrigger int
+
+const (
+	// FlushTriggerSize is a flush caused by the buffer reaching its size threshold.
+	FlushTriggerSize FlushTrigger = iota
+	// FlushTriggerTimeout is a flush caused by the flush timeout expiring.
+	FlushTriggerTimeout
+	// FlushTriggerExplicit is a flush requested by calling Flush.
+	FlushTriggerExplicit
+	// FlushTriggerClose is the final flush performed by Close.
+	FlushTriggerClose
+	// FlushTriggerDelimiter is a flush caused by buffering the delimiter set with WithFlushDelimiter.
+	FlushTriggerDelimiter
+	// FlushTriggerCount is a flush caused by buffering the number of writes set with WithMaxPendingWrites.
+	FlushTriggerCount
+	// FlushTriggerIdle is a flush sent at once under WithAckGating because nothing was in flight.
+	FlushTriggerIdle
+	// FlushTriggerAck is a flush of the data held back under WithAckGating until an acknowledgement.
+	FlushTriggerAck
+	// FlushTriggerRead is a flush caused by Read returning data under WithFlushOnRead.
+	FlushTriggerRead
+	// FlushTriggerKeepAlive is a flush of the keepalive payload sent under WithKeepAlive.
+	FlushTriggerKeepAlive
+	// FlushTriggerSignal is a flush caused by the event source of a FlushSignal.
+	FlushTriggerSignal
+)
+
+// String returns the lowercase name of the trigger.
+func (t FlushTrigger) String() string {
+	switch t {
+	case FlushTriggerSize:
+		return "size"
+	case FlushTriggerTimeout:
+		return "timeout"
+	case FlushTriggerExplicit:
+		return "explicit"
+	case FlushTriggerClose:
+		return "close"
+	case FlushTriggerDelimiter:
+		return "delimiter"
+	case FlushTriggerCount:
+		return "count"
+	case FlushTriggerIdle:
+		return "idle"
+	case FlushTriggerAck:
+		return "ack"
+	case FlushTriggerRead:
+		return "read"
+	case FlushTriggerKeepAlive:
+		return "keepalive"
+	case FlushTriggerSignal:
+		return "signal"
+	default:
+		return "unknown"
+	}
+}
+
+// Stats holds counters describing how a wrapper has coalesced writes.
+type Stats struct {
+	// Writes is the number of Write calls whose data was accepted into the buffer.
+	Writes int64
+	// BytesWritten is the total number of bytes accepted into the buffer.
+	BytesWritten int64
+	// BytesFlushed is the total number of bytes written to the underlying stream.
+	BytesFlushed int64
+	// Buffered is the number of bytes currently awaiting flush.
+	Buffered int
+	// MaxBuffered is the highest number of bytes held in the buffer at once.
+	MaxBuffered int
+	// Capacity is the number of bytes the buffer can hold without growing. It is zero
+	// for the segment buffer of WithVectoredFlush, which does not copy writes.
+	Capacity int
+	// SizeFlushes is the number of flushes triggered by the buffer size threshold.
+	SizeFlushes int64
+	// TimeoutFlushes is the number of flushes triggered by the flush timeout.
+	TimeoutFlushes int64
+	// ExplicitFlushes is the number of flushes requested by calling Flush.
+	ExplicitFlushes int64
+	// CloseFlushes is the number of flushes performed by Close.
+	CloseFlushes int64
+	// DelimiterFlushes is the number of flushes triggered by the flush delimiter.
+	DelimiterFlushes int64
+	// CountFlushes is the number of flushes triggered by the pending writes limit.
+	CountFlushes int64
+	// IdleFlushes is the number of flushes sent at once under WithAckGating.
+	IdleFlushes int64
+	// AckFlushes is the number of flushes sent under WithAckGating when an acknowledgement arrived.
+	AckFlushes int64
+	// ReadFlushes is the number of flushes triggered by Read under WithFlushOnRead.
+	ReadFlushes int64
+	// KeepAliveFlushes is the number of flushes made to send a keepalive under WithKeepAlive.
+	KeepAliveFlushes int64
+	// SignalFlushes is the number of flushes caused by a FlushSignal.
+	SignalFlushes int64
+	// KeepAlives is the number of keepalive payloads buffered under WithKeepAlive.
+	KeepAlives int64
+	// DirectWrites is the number of writes that bypassed the buffer, such as WriteNoDelay
+	// calls made with nothing buffered.
+	DirectWrites int64
+	// UrgentWrites is the number of WriteUrgent calls.
+	UrgentWrites int64
+	// FlushRetries is the number of writes retried under WithFlushRetry.
+	FlushRetries int64
+	// DroppedWrites is the number of writes discarded under the drop policies of
+	// WithOverflowPolicy, and of messages discarded under those of NagleQueue.SetLimit.
+	DroppedWrites int64
+	// DroppedBytes is the number of bytes in the writes counted by DroppedWrites.
+	DroppedBytes int64
+	// ExpiredWrites is the number of writes discarded at flush time under WithMessageTTL.
+	ExpiredWrites int64
+	// ExpiredBytes is the number of bytes in the writes counted by ExpiredWrites.
+	ExpiredBytes int64
+	// TLSRecords is the estimated number of TLS records the flushes were sent as, under WithTLSRecords.
+	TLSRecords int64
+	// TLSBytesSaved is the estimated record overhead saved by coalescing the writes, under WithTLSRecords.
+	TLSBytesSaved int64
+	// Reconnects is the number of streams dialed to replace a failed one under WithReconnect.
+	Reconnects int64
+	// QueueDepth is the number of batches waiting for the writer goroutine of WithAsyncFlush.
+	QueueDepth int
+	// FlushSizes counts the flushes by the number of bytes they wrote.
+	FlushSizes SizeHistogram
+	// QueueTimes counts the flushes by how long the first byte they wrote had waited in
+	// the buffer. Writes sent without being buffered count as not having waited.
+	QueueTimes QueueHistogram
+}
+
+// Flushes returns the total number of flushes that wrote data to the underlying stream.
+func (s Stats) Flushes() int64 {
+	return s.SizeFlushes + s.TimeoutFlushes + s.ExplicitFlushes + s.CloseFlushes + s.DelimiterFlushes + s.CountFlushes + s.IdleFlushes + s.AckFlushes + s.ReadFlushes + s.KeepAliveFlushes + s.SignalFlushes
+}
+
+// AverageFlushSize returns the mean number of bytes per flush, i.e. the average coalesced write size.
+func (s Stats) AverageFlushSize() float64 {
+	flushes := s.Flushes()
+	if flushes == 0 {
+		return 0
+	}
+	return float64(s.BytesFlushed) / float64(flushes)
+}
+
+// add adds the counters of o to s, keeping the highest MaxBuffered.
+func (s *Stats) add(o Stats) {
+	s.Writes += o.Writes
+	s.BytesWritten += o.BytesWritten
+	s.BytesFlushed += o.BytesFlushed
+	s.Buffered += o.Buffered
+	s.MaxBuffered = max(s.MaxBuffered, o.MaxBuffered)
+	s.Capacity += o.Capacity
+	s.SizeFlushes += o.SizeFlushes
+	s.TimeoutFlushes += o.TimeoutFlushes
+	s.ExplicitFlushes += o.ExplicitFlushes
+	s.CloseFlushes += o.CloseFlushes
+	s.DelimiterFlushes += o.DelimiterFlushes
+	s.CountFlushes += o.CountFlushes
+	s.IdleFlushes += o.IdleFlushes
+	s.AckFlushes += o.AckFlushes
+	s.ReadFlushes += o.ReadFlushes
+	s.KeepAliveFlushes += o.KeepAliveFlushes
+	s.SignalFlushes += o.SignalFlushes
+	s.KeepAlives += o.KeepAlives
+	s.DirectWrites += o.DirectWrites
+	s.UrgentWrites += o.UrgentWrites
+	s.FlushRetries += o.FlushRetries
+	s.DroppedWrites += o.DroppedWrites
+	s.DroppedBytes += o.DroppedBytes
+	s.ExpiredWrites += o.ExpiredWrites
+	s.ExpiredBytes += o.ExpiredBytes
+	s.TLSRecords += o.TLSRecords
+	s.TLSBytesSaved += o.TLSBytesSaved
+	s.Reconnects += o.Reconnects
+	s.QueueDepth += o.QueueDepth
+	s.FlushSizes.add(o.FlushSizes)
+	s.QueueTimes.add(o.QueueTimes)
+}
+
+func (s *Stats) record(trigger FlushTrigger, n int64, queued time.Duration) {
+	if n == 0 {
+		return
+	}
+	s.BytesFlushed += n
+	s.FlushSizes.observe(n)
+	s.QueueTimes.observe(queued)
+	switch trigger {
+	case FlushTriggerSize:
+		s.SizeFlushes++
+	case FlushTriggerTimeout:
+		s.TimeoutFlushes++
+	case FlushTriggerExplicit:
+		s.ExplicitFlushes++
+	case FlushTriggerClose:
+		s.CloseFlushes++
+	case FlushTriggerDelimiter:
+		s.DelimiterFlushes++
+	case FlushTriggerCount:
+		s.CountFlushes++
+	case FlushTriggerIdle:
+		s.IdleFlushes++
+	case FlushTriggerAck:
+		s.AckFlushes++
+	case FlushTriggerRead:
+		s.ReadFlushes++
+	case FlushTriggerKeepAlive:
+		s.KeepAliveFlushes++
+	case FlushTriggerSignal:
+		s.SignalFlushes++
+	}
+}
+
+// Stats returns a snapshot of the wrapper's counters.
+func (nw *NagleWriter) Stats() Stats {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	stats := nw.stats
+	if !nw.closed {
+		stats.Buffered = nw.pendingLocked()
+		if buf, ok := nw.buffer.(*bytes.Buffer); ok {
+			stats.Capacity = buf.Cap()
+		}
+	}
+	if nw.async != nil {
+		nw.async.addStats(&stats)
+	}
+	if nw.retry != nil {
+		stats.FlushRetries = nw.retry.retries.Load()
+	}
+	if nw.records != nil {
+		nw.records.addTLSStats(&stats)
+	}
+	if nw.reconnect != nil {
+		stats.Reconnects = nw.reconnect.count.Load()
+	}
+	return stats
+}
+
+// Buffered returns the number of bytes awaiting flush, like bufio.Writer.Buffered.
+func (nw *NagleWriter) Buffered() int {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	if nw.closed {
+		return 0
+	}
+	return nw.pendingLocked()
+}
+
+// Available returns how many more bytes can be buffered before the size trigger fires,
+// like bufio.Writer.Available.
+func (nw *NagleWriter) Available() int {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	if nw.closed {
+		return 0
+	}
+	return max(nw.bufferSize-nw.buffer.Len(), 0)
+}
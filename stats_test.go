@@ -0,0 +1,65 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_Stats(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(10), WithFlushTimeout(20*time.Millisecond))
+
+	nagleWrapper.Write([]byte("01234"))
+	nagleWrapper.Write([]byte("56789"))
+	nagleWrapper.Write([]byte("ab"))
+	nagleWrapper.Flush()
+	nagleWrapper.Write([]byte("c"))
+	time.Sleep(60 * time.Millisecond)
+	nagleWrapper.Write([]byte("d"))
+
+	stats := nagleWrapper.Stats()
+	if stats.Writes != 5 {
+		t.Fatalf("expected 5 writes, got %d", stats.Writes)
+	}
+	if stats.BytesWritten != 14 {
+		t.Fatalf("expected 14 bytes written, got %d", stats.BytesWritten)
+	}
+	if stats.BytesFlushed != 13 {
+		t.Fatalf("expected 13 bytes flushed, got %d", stats.BytesFlushed)
+	}
+	if stats.Buffered != 1 {
+		t.Fatalf("expected 1 byte buffered, got %d", stats.Buffered)
+	}
+	if stats.MaxBuffered != 10 {
+		t.Fatalf("expected max buffered 10, got %d", stats.MaxBuffered)
+	}
+	if stats.SizeFlushes != 1 || stats.ExplicitFlushes != 1 || stats.TimeoutFlushes != 1 {
+		t.Fatalf("unexpected flush counts: %+v", stats)
+	}
+
+	nagleWrapper.Close()
+	stats = nagleWrapper.Stats()
+	if stats.CloseFlushes != 1 {
+		t.Fatalf("expected 1 close flush, got %d", stats.CloseFlushes)
+	}
+	if avg := stats.AverageFlushSize(); avg != 3.5 {
+		t.Fatalf("expected average flush size 3.5, got %v", avg)
+	}
+}
+
+func TestNagleWrapper_BufferedAvailable(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithBufferSize(10), WithFlushTimeout(time.Hour))
+
+	if nagleWrapper.Buffered() != 0 || nagleWrapper.Available() != 10 {
+		t.Fatalf("expected 0 buffered and 10 available, got %d and %d", nagleWrapper.Buffered(), nagleWrapper.Available())
+	}
+	nagleWrapper.Write([]byte("0123"))
+	if nagleWrapper.Buffered() != 4 || nagleWrapper.Available() != 6 {
+		t.Fatalf("expected 4 buffered and 6 available, got %d and %d", nagleWrapper.Buffered(), nagleWrapper.Available())
+	}
+
+	nagleWrapper.Close()
+	if nagleWrapper.Buffered() != 0 || nagleWrapper.Available() != 0 {
+		t.Fatalf("expected nothing buffered nor available after close, got %d and %d", nagleWrapper.Buffered(), nagleWrapper.Available())
+	}
+}
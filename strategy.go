@@ -0,0 +1,37 @@
+package nagle
+
+import "time"
+
+// Strategy decides when buffered data is flushed, in place of the buffer size threshold
+// and the flush timeout, for policies the options do not cover. It is called with the
+// wrapper lock held, from one goroutine at a time, and must not call back into the
+// wrapper. Triggers that are set apart, such as WithFlushDelimiter or
+// WithMaxPendingWrites, keep working alongside it.
+type Strategy interface {
+	// OnWrite is told that a write of writeLen bytes took the buffer to bufLen bytes,
+	// and reports whether the buffer is to be flushed now. Under WithDoubleBuffer it is
+	// also called with writeLen 0 once a write made with the lock released returns, to
+	// decide on the data buffered meanwhile.
+	OnWrite(bufLen, writeLen int) bool
+	// NextDeadline returns when the data still buffered after a write is to be flushed
+	// at the latest. The zero time leaves it to the flush timeout.
+	NextDeadline() time.Time
+}
+
+// WithStrategy hands the flush decisions to s. Flushes it asks for are reported as
+// size flushes, and those made at its deadline as timeout flushes. Writes of at least
+// the buffer size are buffered like the others instead of being written directly.
+func WithStrategy(s Strategy) Option {
+	return func(o *options) {
+		o.strategy = s
+	}
+}
+
+// sizeFlushDueLocked reports whether buffering the writeLen bytes just appended calls
+// for a size flush.
+func (nw *NagleWriter) sizeFlushDueLocked(writeLen int) bool {
+	if nw.strategy != nil {
+		return nw.strategy.OnWrite(nw.buffer.Len(), writeLen)
+	}
+	return nw.buffer.Len() >= nw.bufferSize
+}
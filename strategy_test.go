@@ -0,0 +1,60 @@
+package nagle_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+// powerOfTwoStrategy flushes when the buffer holds a power of two of at least 8 bytes,
+// and at the latest 50ms after the first buffered byte.
+type powerOfTwoStrategy struct {
+	clock *naglefake.Clock
+	start time.Time
+}
+
+func (s *powerOfTwoStrategy) OnWrite(bufLen, writeLen int) bool {
+	if bufLen == writeLen {
+		s.start = s.clock.Now()
+	}
+	return bufLen >= 8 && bufLen&(bufLen-1) == 0
+}
+
+func (s *powerOfTwoStrategy) NextDeadline() time.Time {
+	return s.start.Add(50 * time.Millisecond)
+}
+
+func TestNagleWriter_WithStrategy(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(4), nagle.WithFlushTimeout(time.Hour), nagle.WithClock(clock),
+		nagle.WithStrategy(&powerOfTwoStrategy{clock: clock}))
+	defer nagleWriter.Close()
+
+	// The buffer size no longer triggers flushes
+	nagleWriter.Write([]byte("0123"))
+	nagleWriter.Write([]byte("45"))
+	if out.Len() != 0 {
+		t.Fatalf("expected nothing flushed, but got: '%s'", out.String())
+	}
+	nagleWriter.Write([]byte("67"))
+	if out.String() != "01234567" {
+		t.Fatalf("expected a flush at 8 bytes, but got: '%s'", out.String())
+	}
+
+	// The deadline counts from the first write, however many follow
+	nagleWriter.Write([]byte("a"))
+	clock.Advance(30 * time.Millisecond)
+	nagleWriter.Write([]byte("b"))
+	clock.Advance(20 * time.Millisecond)
+	if out.String() != "01234567ab" {
+		t.Fatalf("expected a flush at the deadline, but got: '%s'", out.String())
+	}
+
+	if stats := nagleWriter.Stats(); stats.SizeFlushes != 1 || stats.TimeoutFlushes != 1 {
+		t.Fatalf("expected 1 size and 1 timeout flush, but got: %+v", stats)
+	}
+}
@@ -0,0 +1,69 @@
+package nagle
+
+import (
+	"hash/crc32"
+	"runtime/debug"
+)
+
+// WithStrictChecks makes the wrapper check at run time, at some cost, that it is used
+// as intended, for development builds and tests. It reports writes after Close or
+// CloseWrite, slices passed to WriteOwned that change before they are flushed, flushes
+// of more than the WithMaxPendingBytes or WithMaxBufferSize limit, and concurrent Read
+// calls. Misuse makes the wrapper panic or, when WithLogger is given, is logged at the
+// error level together with the stack trace.
+func WithStrictChecks() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// ownedSlice is a slice queued by WriteOwned and its checksum when it was queued.
+type ownedSlice struct {
+	data []byte
+	sum  uint32
+}
+
+// violation reports misuse found under WithStrictChecks.
+func (nw *NagleWriter) violation(msg string) {
+	msg = "nagle: " + msg
+	if nw.logger == nil {
+		panic(msg)
+	}
+	nw.logger.Error(msg, "stack", string(debug.Stack()))
+}
+
+// recordOwnedLocked remembers a slice queued without a copy so checkFlushLocked can
+// tell whether it changed.
+func (nw *NagleWriter) recordOwnedLocked(data []byte) {
+	nw.owned = append(nw.owned, ownedSlice{data: data, sum: crc32.ChecksumIEEE(data)})
+}
+
+// checkFlushLocked checks the buffer about to be flushed under WithStrictChecks.
+func (nw *NagleWriter) checkFlushLocked() {
+	// Unsent bytes put back by a short write may take the buffer past the limit.
+	if nw.maxPendingBytes > 0 && nw.buffer.Len() > nw.maxPendingBytes && !nw.partial {
+		nw.violation("flush exceeds the pending bytes limit")
+	}
+	owned := nw.owned
+	nw.owned = nil
+	for _, slice := range owned {
+		if crc32.ChecksumIEEE(slice.data) != slice.sum {
+			nw.violation("slice passed to WriteOwned was modified before it was flushed")
+			break
+		}
+	}
+}
+
+// beginRead marks a Read in progress under WithStrictChecks, reporting whether it was
+// the only one. endRead must be called when it did.
+func (nw *NagleWrapper) beginRead() bool {
+	if nw.reading.CompareAndSwap(false, true) {
+		return true
+	}
+	nw.violation("concurrent Read calls")
+	return false
+}
+
+func (nw *NagleWrapper) endRead() {
+	nw.reading.Store(false)
+}
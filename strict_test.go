@@ -0,0 +1,78 @@
+package nagle
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// expectViolation fails the test unless fn panics with a message containing msg.
+func expectViolation(t *testing.T, msg string, fn func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if s, ok := r.(string); !ok || !strings.Contains(s, msg) {
+			t.Fatalf("expected a panic with '%s', but got: %v", msg, r)
+		}
+	}()
+	fn()
+}
+
+func TestNagleWrapper_StrictWriteAfterClose(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithStrictChecks())
+	nagleWrapper.Close()
+
+	expectViolation(t, "write after Close", func() {
+		nagleWrapper.Write([]byte("0123"))
+	})
+}
+
+func TestNagleConn_StrictWriteOwnedMutation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nagleConn := NewConn(conn, WithBufferSize(100), WithFlushTimeout(time.Hour), WithVectoredFlush(), WithStrictChecks())
+	defer nagleConn.Close()
+
+	owned := []byte("0123")
+	nagleConn.WriteOwned(owned)
+	copy(owned, "xxxx")
+	expectViolation(t, "WriteOwned was modified", func() {
+		nagleConn.Flush()
+	})
+}
+
+func TestNagleWrapper_StrictLogsConcurrentRead(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, nil))
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithStrictChecks(), WithLogger(logger))
+	defer nagleWrapper.Close()
+
+	// A Read in progress, as another goroutine would leave it
+	nagleWrapper.reading.Store(true)
+	nagleWrapper.Read(make([]byte, 4))
+	if !strings.Contains(out.String(), `level=ERROR msg="nagle: concurrent Read calls" stack=`) {
+		t.Fatalf("expected the violation to be logged with its stack, but got: %s", out.String())
+	}
+}
@@ -0,0 +1,148 @@
+package nagle
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+)
+
+var errNotConn = errors.New("nagle: a NagleConn can only be moved to a net.Conn")
+
+// readSource is the stream reads come from, swapped as a whole by SwapUnderlying so a
+// Read never sees the stream of one connection with the read-ahead buffer of another.
+type readSource struct {
+	rwc    io.ReadWriteCloser
+	reader *bufio.Reader
+}
+
+func newReadSource(rwc io.ReadWriteCloser, size int) *readSource {
+	src := &readSource{rwc: rwc}
+	if size > 0 {
+		src.reader = bufio.NewReaderSize(rwc, size)
+	}
+	return src
+}
+
+// layer is implemented by the writers newWriter stacks on top of the underlying stream.
+type layer interface {
+	next() *io.Writer
+}
+
+func (dw *deadlineWriter) next() *io.Writer { return &dw.w }
+func (c *recordCounter) next() *io.Writer   { return &c.w }
+func (r *retryWriter) next() *io.Writer     { return &r.w }
+func (l *rateLimiter) next() *io.Writer     { return &l.w }
+func (c *chunkWriter) next() *io.Writer     { return &c.w }
+func (f *frameWriter) next() *io.Writer     { return &f.w }
+func (s *syncWriter) next() *io.Writer      { return &s.w }
+
+// noSync stands in for the file of a syncWriter once the stream is no longer a file.
+type noSync struct{}
+
+func (noSync) Sync() error { return nil }
+
+// SwapUnderlying moves the wrapper to rwc, for reconnect logic above a flaky transport.
+// The data still buffered, including the rest of a batch the old stream only took part
+// of, is flushed to rwc, and later flushes and reads use rwc too; batches already handed
+// to the old stream, such as those queued by WithAsyncFlush, are written there first.
+// The error of the flush is returned, with the data kept for the next one.
+//
+// The old stream is not closed, and a Read already waiting on it keeps doing so until
+// the caller closes it. Data left in its read-ahead buffer is dropped, and so is the
+// deadline set with SetReadDeadline. Done and Err start over, so a fatal error of the old
+// stream no longer shows through them. Layers such as WithWriteDeadline, WithFlushRetry and
+// WithSyncPolicy keep their settings and apply to rwc from now on.
+func (nw *NagleWrapper) SwapUnderlying(rwc io.ReadWriteCloser) error {
+	return nw.swapUnderlying(rwc, nw.swapLocked)
+}
+
+// SwapUnderlying is SwapUnderlying of NagleWrapper for connections: rwc must be a
+// net.Conn, which LocalAddr, RemoteAddr and the deadline methods refer to afterwards.
+// TCP_NODELAY is set on it unless disabled with WithTCPNoDelay.
+func (nc *NagleConn) SwapUnderlying(rwc io.ReadWriteCloser) error {
+	return nc.swapUnderlying(rwc, nc.swapLocked)
+}
+
+func (nw *NagleWrapper) swapUnderlying(rwc io.ReadWriteCloser, swap func(io.ReadWriteCloser) error) error {
+	nw.mutex.Lock()
+	defer nw.unlock()
+
+	nw.waitLeaderLocked()
+	if nw.closed || nw.writeClosed {
+		return ErrClosed
+	}
+	if err := swap(rwc); err != nil {
+		return err
+	}
+
+	// Errors of the old stream are not reported against the new one.
+	nw.takeAsyncErrLocked()
+	nw.nested = false
+	_, err := nw.flushLocked(FlushTriggerExplicit)
+	return err
+}
+
+// swapLocked moves the writes and the reads of the wrapper to rwc.
+func (nw *NagleWrapper) swapLocked(rwc io.ReadWriteCloser) error {
+	nw.rebaseLocked(rwc)
+	size := 0
+	if src := nw.source.Load(); src.reader != nil {
+		size = src.reader.Size()
+	}
+	nw.source.Store(newReadSource(rwc, size))
+	nw.deadlines.Store(nil)
+	return nil
+}
+
+func (nc *NagleConn) swapLocked(rwc io.ReadWriteCloser) error {
+	conn, ok := rwc.(net.Conn)
+	if !ok {
+		return errNotConn
+	}
+	nc.NagleWrapper.swapLocked(rwc)
+	if nc.noDelay {
+		nc.setCloserLocked(setNoDelay(conn, nc.closer))
+	}
+	return nil
+}
+
+// rebaseLocked points the innermost layer of the writer chain, and the closer, at rwc,
+// and starts Done over.
+func (nw *NagleWriter) rebaseLocked(rwc io.ReadWriteCloser) {
+	next := &nw.w
+	for {
+		l, ok := (*next).(layer)
+		if !ok {
+			break
+		}
+		switch l := l.(type) {
+		case *deadlineWriter:
+			l.conn, _ = rwc.(deadlineSetter)
+			l.closer = rwc
+		case *syncWriter:
+			l.mutex.Lock()
+			if file, ok := rwc.(syncer); ok {
+				l.file = file
+			} else {
+				l.file = noSync{}
+			}
+			l.mutex.Unlock()
+		}
+		next = l.next()
+	}
+	*next = rwc
+	nw.base = rwc
+	if sc, ok := nw.closer.(syncCloser); ok {
+		nw.setCloserLocked(syncCloser{s: sc.s, closer: rwc})
+	} else {
+		nw.setCloserLocked(rwc)
+	}
+	if _, ok := nw.buffer.(*segmentBuffer); ok && !supportsWritev(nw.w) {
+		// Segments would go out in a Write each to a stream without writev.
+		buf := newBatchBuffer(nw.bufferSize, nw.prealloc)
+		buf.Write(nw.buffer.Bytes())
+		nw.buffer = buf
+	}
+	nw.doneState.reset()
+}
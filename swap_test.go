@@ -0,0 +1,103 @@
+package nagle
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_SwapUnderlying(t *testing.T) {
+	old := &MockReadWriteCloser{}
+	old.Close()
+	nagleWrapper := New(old, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("012"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected ErrClosedPipe, but got: %v", err)
+	}
+	if nagleWrapper.Err() == nil {
+		t.Fatal("expected Done to be closed by the failing stream")
+	}
+
+	// The data the old stream refused goes out on the new one
+	fresh := &MockReadWriteCloser{}
+	if err := nagleWrapper.SwapUnderlying(fresh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fresh.String() != "012" {
+		t.Fatalf("expected '012' on the new stream, but got: '%s'", fresh.String())
+	}
+	if err := nagleWrapper.Err(); err != nil {
+		t.Fatalf("expected Err to start over, but got: %v", err)
+	}
+
+	nagleWrapper.Write([]byte("345"))
+	nagleWrapper.Flush()
+	p := make([]byte, 10)
+	n, err := nagleWrapper.Read(p)
+	if err != nil || string(p[:n]) != "012345" {
+		t.Fatalf("expected to read '012345' from the new stream, but got: '%s', %v", p[:n], err)
+	}
+	if nagleWrapper.Unwrap() != fresh {
+		t.Fatal("expected Unwrap to return the new stream")
+	}
+
+	nagleWrapper.Close()
+	if _, err := fresh.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected Close to close the new stream, but got: %v", err)
+	}
+	if err := nagleWrapper.SwapUnderlying(&MockReadWriteCloser{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+}
+
+func TestNagleWrapper_SwapUnderlyingShortWrite(t *testing.T) {
+	nagleWrapper := New(&ShortWriteReadWriteCloser{limit: 2}, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123456789"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected ErrShortWrite, but got: %v", err)
+	}
+
+	// Only the rest of the batch is sent again
+	fresh := &MockReadWriteCloser{}
+	if err := nagleWrapper.SwapUnderlying(fresh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fresh.String() != "23456789" {
+		t.Fatalf("expected '23456789' on the new stream, but got: '%s'", fresh.String())
+	}
+}
+
+func TestNagleConn_SwapUnderlying(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleConn.Close()
+
+	if err := nagleConn.SwapUnderlying(&MockReadWriteCloser{}); !errors.Is(err, errNotConn) {
+		t.Fatalf("expected errNotConn, but got: %v", err)
+	}
+
+	newClient, newServer := net.Pipe()
+	defer newServer.Close()
+	nagleConn.Write([]byte("hello"))
+	done := make(chan error, 1)
+	go func() { done <- nagleConn.SwapUnderlying(newClient) }()
+
+	p := make([]byte, 10)
+	n, err := newServer.Read(p)
+	if err != nil || string(p[:n]) != "hello" {
+		t.Fatalf("expected 'hello' on the new connection, but got: '%s', %v", p[:n], err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nagleConn.NetConn() != newClient || nagleConn.LocalAddr() != newClient.LocalAddr() {
+		t.Fatal("expected the connection methods to refer to the new connection")
+	}
+}
@@ -0,0 +1,145 @@
+package nagle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// SyncPolicy selects when WithSyncPolicy syncs the underlying file to stable storage.
+type SyncPolicy struct {
+	every    bool
+	interval time.Duration
+}
+
+var (
+	// SyncNever leaves writing the data back to the operating system, as without
+	// WithSyncPolicy.
+	SyncNever = SyncPolicy{}
+	// SyncEveryFlush syncs after every flush, so the data of a flush is durable once
+	// the flush returns, at the cost of a sync per batch.
+	SyncEveryFlush = SyncPolicy{every: true}
+)
+
+// SyncInterval syncs at most once every d: a flush syncs if the last sync is at least
+// d old, and otherwise the sync is made in the background when it is, so flushed data
+// is durable within d.
+func SyncInterval(d time.Duration) SyncPolicy {
+	if d <= 0 {
+		return SyncEveryFlush
+	}
+	return SyncPolicy{interval: d}
+}
+
+// WithSyncPolicy calls Sync on the underlying writer, such as an *os.File, after
+// flushing as selected by policy, which makes the wrapper a coalescing log appender
+// with bounded data loss. Writers without a Sync method are not synced. A failed sync
+// fails the flush it follows, while the error of a background sync is returned by the
+// next flush. Close syncs whatever was flushed since the last sync.
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(o *options) {
+		o.syncPolicy = policy
+	}
+}
+
+// syncer is implemented by files and other writers that can be synced to storage.
+type syncer interface {
+	Sync() error
+}
+
+// syncWriter syncs file after the writes to w as selected by policy. Background syncs
+// run on their own timer, so its state has a lock of its own.
+type syncWriter struct {
+	w      io.Writer
+	file   syncer
+	clock  Clock
+	policy SyncPolicy
+	mutex  sync.Mutex
+	last   time.Time
+	dirty  bool
+	timer  Timer
+	armed  bool
+	err    error
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.err; err != nil {
+		s.err = nil
+		return 0, err
+	}
+	n, err := s.w.Write(p)
+	if n == 0 {
+		return n, err
+	}
+	s.dirty = true
+	now := s.clock.Now()
+	if s.policy.every || now.Sub(s.last) >= s.policy.interval {
+		if syncErr := s.syncLocked(now); err == nil {
+			err = syncErr
+		}
+	} else if !s.armed {
+		s.armed = true
+		wait := s.last.Add(s.policy.interval).Sub(now)
+		if s.timer == nil {
+			s.timer = s.clock.AfterFunc(wait, s.background)
+		} else {
+			s.timer.Reset(wait)
+		}
+	}
+	return n, err
+}
+
+func (s *syncWriter) syncLocked(now time.Time) error {
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.last = now
+	s.dirty = false
+	return nil
+}
+
+// background makes the sync postponed by SyncInterval.
+func (s *syncWriter) background() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.armed {
+		return
+	}
+	s.armed = false
+	if s.dirty {
+		if err := s.syncLocked(s.clock.Now()); err != nil {
+			s.err = err
+		}
+	}
+}
+
+// syncCloser syncs what was flushed since the last sync before closing the writer.
+type syncCloser struct {
+	s      *syncWriter
+	closer io.Closer
+}
+
+func (c syncCloser) Close() error {
+	s := c.s
+	s.mutex.Lock()
+	if s.armed {
+		s.armed = false
+		s.timer.Stop()
+	}
+	err := s.err
+	if s.dirty && err == nil {
+		err = s.syncLocked(s.clock.Now())
+	}
+	s.mutex.Unlock()
+
+	if c.closer != nil {
+		if closeErr := c.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package nagle_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+// syncRecorder is a writer with a Sync method that records how much data it made durable.
+type syncRecorder struct {
+	bytes.Buffer
+	synced int
+	syncs  int
+	err    error
+}
+
+func (s *syncRecorder) Sync() error {
+	if s.err != nil {
+		return s.err
+	}
+	s.syncs++
+	s.synced = s.Len()
+	return nil
+}
+
+func TestNagleWriter_SyncEveryFlush(t *testing.T) {
+	out := &syncRecorder{}
+	nagleWriter := nagle.NewWriter(out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour),
+		nagle.WithSyncPolicy(nagle.SyncEveryFlush))
+
+	nagleWriter.Write([]byte("0123"))
+	if out.syncs != 0 {
+		t.Fatalf("expected buffered data to not be synced, but got %d syncs", out.syncs)
+	}
+	if err := nagleWriter.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.synced != 4 {
+		t.Fatalf("expected 4 bytes synced after the flush, but got: %d", out.synced)
+	}
+
+	// A failed sync fails the flush
+	syncErr := errors.New("sync failed")
+	out.err = syncErr
+	nagleWriter.Write([]byte("4567"))
+	if err := nagleWriter.Flush(); !errors.Is(err, syncErr) {
+		t.Fatalf("expected %v, but got: %v", syncErr, err)
+	}
+}
+
+func TestNagleWriter_SyncInterval(t *testing.T) {
+	out := &syncRecorder{}
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour),
+		nagle.WithClock(clock), nagle.WithSyncPolicy(nagle.SyncInterval(time.Second)))
+
+	nagleWriter.Write([]byte("01"))
+	nagleWriter.Flush()
+	if out.syncs != 1 {
+		t.Fatalf("expected the first flush to sync, but got %d syncs", out.syncs)
+	}
+
+	// Flushes within the interval are synced together once it is over
+	clock.Advance(100 * time.Millisecond)
+	nagleWriter.Write([]byte("23"))
+	nagleWriter.Flush()
+	nagleWriter.Write([]byte("45"))
+	nagleWriter.Flush()
+	if out.syncs != 1 {
+		t.Fatalf("expected no sync within the interval, but got %d syncs", out.syncs)
+	}
+	clock.Advance(900 * time.Millisecond)
+	if out.syncs != 2 || out.synced != 6 {
+		t.Fatalf("expected 6 bytes synced by a second sync, but got: %d by %d", out.synced, out.syncs)
+	}
+
+	// Close syncs what was flushed since
+	clock.Advance(100 * time.Millisecond)
+	nagleWriter.Write([]byte("67"))
+	nagleWriter.Flush()
+	if err := nagleWriter.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.syncs != 3 || out.synced != 8 {
+		t.Fatalf("expected 8 bytes synced on close, but got: %d by %d", out.synced, out.syncs)
+	}
+}
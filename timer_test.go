@@ -0,0 +1,55 @@
+package nagle
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingClock is the real clock with a count of the resets of its timers.
+type countingClock struct {
+	realClock
+	resets atomic.Int64
+}
+
+func (c *countingClock) AfterFunc(d time.Duration, f func()) Timer {
+	return countingTimer{c.realClock.AfterFunc(d, f), c}
+}
+
+type countingTimer struct {
+	Timer
+	clock *countingClock
+}
+
+func (t countingTimer) Reset(d time.Duration) bool {
+	t.clock.resets.Add(1)
+	return t.Timer.Reset(d)
+}
+
+func TestNagleWrapper_LazyTimer(t *testing.T) {
+	clock := &countingClock{}
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(1000), WithFlushTimeout(20*time.Millisecond), WithClock(clock))
+	defer nagleWrapper.Close()
+
+	// Writes that push the deadline back leave the armed timer alone
+	for i := 0; i < 100; i++ {
+		nagleWrapper.Write([]byte("0"))
+	}
+	if resets := clock.resets.Load(); resets != 0 {
+		t.Fatalf("expected no timer resets, but got: %d", resets)
+	}
+
+	// The timer fires early, sleeps for the rest, and the data is flushed on time
+	time.Sleep(100 * time.Millisecond)
+	if len(mockRWC.String()) != 100 {
+		t.Fatalf("expected 100 bytes flushed, but got: %d", len(mockRWC.String()))
+	}
+
+	// After a flush the next write arms the timer again
+	nagleWrapper.Write([]byte("1"))
+	time.Sleep(100 * time.Millisecond)
+	if len(mockRWC.String()) != 101 {
+		t.Fatalf("expected 101 bytes flushed, but got: %d", len(mockRWC.String()))
+	}
+}
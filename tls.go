@@ -0,0 +1,48 @@
+package nagle
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// TLSMaxRecordSize is the largest plaintext a TLS record carries.
+const TLSMaxRecordSize = 16384
+
+// TLSRecordOverhead estimates the bytes a TLS record adds to its plaintext: the record
+// header, and the explicit nonce and tag of AES-GCM under TLS 1.2.
+const TLSRecordOverhead = 29
+
+// WithTLSRecords tunes the wrapper for a *tls.Conn, where every underlying write is
+// sent as at least one record that costs TLSRecordOverhead bytes: the size trigger
+// fires at TLSMaxRecordSize, so flushes fill whole records, and Stats estimates the
+// records sent and the overhead coalescing saved, in TLSRecords and TLSBytesSaved. The
+// estimates assume full-sized records; crypto/tls starts a connection, and resumes it
+// after an idle period, with smaller ones. WithBufferSize given afterwards still
+// overrides the size.
+func WithTLSRecords() Option {
+	return func(o *options) {
+		o.tlsRecords = true
+		o.bufferSize = TLSMaxRecordSize
+	}
+}
+
+// recordCounter counts the TLS records the writes to w are sent as.
+type recordCounter struct {
+	w       io.Writer
+	records atomic.Int64
+}
+
+func (c *recordCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		c.records.Add(int64((n + TLSMaxRecordSize - 1) / TLSMaxRecordSize))
+	}
+	return n, err
+}
+
+// addTLSStats fills in the TLS record estimates of WithTLSRecords. Without coalescing
+// every write would have been a record of its own.
+func (c *recordCounter) addTLSStats(stats *Stats) {
+	stats.TLSRecords = c.records.Load()
+	stats.TLSBytesSaved = max(stats.Writes-stats.TLSRecords, 0) * TLSRecordOverhead
+}
@@ -0,0 +1,30 @@
+package nagle
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithTLSRecords(t *testing.T) {
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithFlushTimeout(time.Hour), WithTLSRecords())
+	if nagleWriter.BufferSize() != TLSMaxRecordSize {
+		t.Fatalf("expected buffer size %d, but got: %d", TLSMaxRecordSize, nagleWriter.BufferSize())
+	}
+
+	data := make([]byte, 100)
+	for i := 0; i < 200; i++ {
+		nagleWriter.Write(data)
+	}
+	nagleWriter.Close()
+
+	// The size flush of 16400 bytes takes two records, and the final one another
+	stats := nagleWriter.Stats()
+	if stats.TLSRecords != 3 {
+		t.Fatalf("expected 3 records, but got: %d", stats.TLSRecords)
+	}
+	if stats.TLSBytesSaved != 197*TLSRecordOverhead {
+		t.Fatalf("expected %d bytes saved, but got: %d", 197*TLSRecordOverhead, stats.TLSBytesSaved)
+	}
+}
@@ -0,0 +1,120 @@
+package nagle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// WithTransform applies fn to every flushed batch, such as compressing it, and writes
+// the result instead. Coalescing before transforming lets fn work on whole batches,
+// which compresses much better than transforming each write. fn must not retain
+// batch after returning. If fn fails, the batch stays buffered and the error is
+// returned by the flush.
+func WithTransform(fn func(batch []byte) ([]byte, error)) Option {
+	return func(o *options) {
+		o.transform = fn
+	}
+}
+
+// GzipTransform returns a transform for WithTransform that compresses each batch into
+// its own gzip member. A concatenation of members is a valid gzip stream, so the peer
+// can decompress the whole stream with a single gzip.Reader.
+func GzipTransform(level int) func([]byte) ([]byte, error) {
+	return func(batch []byte) ([]byte, error) {
+		var out bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&out, level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(batch); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+}
+
+// pendingLocked returns the number of bytes waiting to reach the underlying writer,
+// including transformed output left over by a short write.
+func (nw *NagleWriter) pendingLocked() int {
+	return nw.buffer.Len() + len(nw.encoded)
+}
+
+// writeTransformedLocked sends data through the transform straight to the underlying
+// writer, ahead of the buffer. Once part of the output is written, the rest is kept and
+// sent before the next batch, as in flushTransformedLocked, and all of data counts as
+// written; otherwise none of it does.
+func (nw *NagleWriter) writeTransformedLocked(data []byte) (int, error) {
+	out, err := nw.transform(data)
+	if err != nil {
+		return 0, err
+	}
+	n, err := nw.w.Write(out)
+	if err == nil && n < len(out) {
+		err = io.ErrShortWrite
+	}
+	nw.checkFatal(err)
+	nw.stats.BytesFlushed += int64(n)
+	if n == 0 {
+		return 0, err
+	}
+	nw.markSentLocked()
+	if n < len(out) {
+		if nw.pendingLocked() == 0 {
+			nw.pendingWrites = 1
+			nw.armTimerLocked(nw.flushDelayLocked())
+		}
+		nw.encoded = append([]byte(nil), out[n:]...)
+		nw.partial = true
+	}
+	return len(data), err
+}
+
+// flushTransformedLocked is flushLocked for wrappers with a transform. Transformed
+// output that is not fully written is kept and sent before the next batch.
+func (nw *NagleWriter) flushTransformedLocked(trigger FlushTrigger) (int, error) {
+	total := 0
+	for nw.pendingLocked() > 0 {
+		out := nw.encoded
+		fresh := len(out) == 0
+		if fresh {
+			var err error
+			out, err = nw.transform(nw.buffer.Bytes())
+			if err != nil {
+				return total, err
+			}
+		}
+
+		start := nw.flushStartLocked()
+		queued := nw.queuedLocked()
+		n, err := nw.w.Write(out)
+		if err == nil && n < len(out) {
+			err = io.ErrShortWrite
+		}
+		nw.checkFatal(err)
+		total += n
+		nw.stats.record(trigger, int64(n), queued)
+		nw.queueFlushEventLocked(trigger, out, n, start, err)
+		if fresh {
+			// out may alias the buffer, so copy what is left before reusing it.
+			nw.buffer.Reset()
+			nw.encoded = append([]byte(nil), out[n:]...)
+		} else {
+			nw.encoded = nw.encoded[n:]
+		}
+		if len(nw.encoded) == 0 {
+			nw.encoded = nil
+		}
+		nw.partial = len(nw.encoded) > 0
+		if err != nil {
+			return total, err
+		}
+	}
+
+	nw.pendingWrites = 0
+	nw.disarmTimerLocked()
+	return total, nil
+}
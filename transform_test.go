@@ -0,0 +1,141 @@
+package nagle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_WithTransform(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithTransform(func(batch []byte) ([]byte, error) {
+		return append(append([]byte("["), batch...), ']'), nil
+	}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01"))
+	nagleWrapper.Write([]byte("23"))
+	nagleWrapper.Flush()
+	nagleWrapper.Write([]byte("45"))
+	nagleWrapper.Flush()
+
+	if len(mockRWC.writes) != 2 || mockRWC.writes[0] != "[0123]" || mockRWC.writes[1] != "[45]" {
+		t.Fatalf("expected writes '[0123]' and '[45]', got %q", mockRWC.writes)
+	}
+}
+
+func TestNagleWrapper_WithTransformError(t *testing.T) {
+	transformErr := errors.New("transform failed")
+	fail := true
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithTransform(func(batch []byte) ([]byte, error) {
+		if fail {
+			return nil, transformErr
+		}
+		return batch, nil
+	}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, transformErr) {
+		t.Fatalf("expected %v, but got: %v", transformErr, err)
+	}
+
+	// The batch stays buffered after a failed transform
+	fail = false
+	if err := nagleWrapper.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRWC.String() != "0123" {
+		t.Fatalf("expected buffer to contain '0123', but got: %s", mockRWC.String())
+	}
+}
+
+func TestNagleWrapper_WithTransformShortWrite(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 3}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithTransform(func(batch []byte) ([]byte, error) {
+		return batch, nil
+	}))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("01234"))
+	if err := nagleWrapper.Flush(); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected ErrShortWrite, but got: %v", err)
+	}
+
+	// The leftover output is sent before the next batch
+	nagleWrapper.Write([]byte("56"))
+	mockRWC.limit = 100
+	nagleWrapper.Flush()
+	if mockRWC.String() != "0123456" {
+		t.Fatalf("expected buffer to contain '0123456', but got: %s", mockRWC.String())
+	}
+}
+
+func TestGzipTransform(t *testing.T) {
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithBufferSize(8), WithFlushTimeout(time.Hour), WithTransform(GzipTransform(gzip.BestSpeed)))
+
+	for i := 0; i < 10; i++ {
+		nagleWriter.Write([]byte("hello "))
+	}
+	nagleWriter.Close()
+
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != string(bytes.Repeat([]byte("hello "), 10)) {
+		t.Fatalf("unexpected decompressed data: '%s'", data)
+	}
+}
+
+func TestGzipTransform_DirectWrites(t *testing.T) {
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithBufferSize(100), WithFlushTimeout(time.Hour), WithTransform(GzipTransform(gzip.BestSpeed)))
+	nagleWriter.Write([]byte("buffered "))
+	nagleWriter.WriteUrgent([]byte("urgent "))
+	nagleWriter.Flush()
+	nagleWriter.WriteNoDelay([]byte("nodelay"))
+	nagleWriter.Close()
+
+	// Urgent and no-delay writes are compressed like flushed batches, so the stream
+	// holds nothing but gzip members
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "urgent buffered nodelay" {
+		t.Fatalf("unexpected decompressed data: '%s'", data)
+	}
+}
+
+func TestNagleWrapper_WithTransformUrgentShortWrite(t *testing.T) {
+	mockRWC := &ShortWriteReadWriteCloser{limit: 3}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour), WithTransform(func(batch []byte) ([]byte, error) {
+		return batch, nil
+	}))
+	defer nagleWrapper.Close()
+
+	// The leftover output of the urgent write is sent before the buffered data
+	nagleWrapper.Write([]byte("ab"))
+	if n, err := nagleWrapper.WriteUrgent([]byte("01234")); n != 5 || !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("expected 5 bytes and ErrShortWrite, but got: %d, %v", n, err)
+	}
+	mockRWC.limit = 100
+	nagleWrapper.Flush()
+	if mockRWC.String() != "01234ab" {
+		t.Fatalf("expected '01234ab', but got: '%s'", mockRWC.String())
+	}
+}
@@ -0,0 +1,78 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrWouldBlock is returned by TryWrite when the data cannot be taken without waiting.
+var ErrWouldBlock = errors.New("nagle: write would block")
+
+// TryWrite is like Write but never waits: it buffers data only when the wrapper is
+// idle and there is room for it under WithMaxPendingBytes and the MemoryLimiter, and
+// returns ErrWouldBlock otherwise, with nothing buffered. It never writes to the
+// underlying writer either; the flushes its data triggers run in the background,
+// reported as timeout flushes, so a later TryWrite may find room again. It is meant
+// for latency-sensitive loops that must not stall, which fall back to Write or drop
+// the data.
+func (nw *NagleWriter) TryWrite(data []byte) (int, error) {
+	if !nw.mutex.TryLock() {
+		return 0, ErrWouldBlock
+	}
+	defer nw.unlock()
+
+	if nw.leaderDone != nil {
+		return 0, ErrWouldBlock
+	}
+	if err := nw.writableLocked(); err != nil {
+		return 0, err
+	}
+	framed, _ := nw.frameMessageLocked(data)
+
+	full := nw.maxPendingBytes > 0 && nw.buffer.Len()+len(framed) > nw.maxPendingBytes
+	if nw.messageMode && nw.buffer.Len() > 0 && nw.buffer.Len()+len(framed) > nw.bufferSize {
+		// The buffered messages have to go out before this one is added.
+		full = true
+	}
+	if full {
+		if !nw.corked && nw.buffer.Len() > 0 {
+			nw.armTimerLocked(0)
+		}
+		return 0, ErrWouldBlock
+	}
+	if nw.memory != nil {
+		nw.syncMemoryLocked()
+		if ok, _ := nw.memory.acquire(len(framed)); !ok {
+			return 0, ErrWouldBlock
+		}
+		nw.reserved += len(framed)
+	}
+
+	before := nw.buffer.Len()
+	nw.appendLocked(framed)
+	nw.pendingWrites++
+	nw.stats.Writes++
+
+	if !nw.corked && nw.tryFlushDueLocked(before, framed) {
+		nw.armTimerLocked(0)
+		return len(data), nil
+	}
+	nw.observeWriteLocked()
+	nw.armTimerLocked(nw.flushDelayLocked())
+	return len(data), nil
+}
+
+// tryFlushDueLocked reports whether buffering data from offset before onwards fired
+// one of the triggers that Write acts on straight away.
+func (nw *NagleWriter) tryFlushDueLocked(before int, data []byte) bool {
+	if nw.delimiter != nil {
+		start := max(before-len(nw.delimiter)+1, 0)
+		if bytes.Contains(nw.buffer.Bytes()[start:], nw.delimiter) {
+			return true
+		}
+	}
+	if nw.sizeFlushDueLocked(len(data)) {
+		return true
+	}
+	return nw.maxWrites > 0 && nw.pendingWrites >= nw.maxWrites
+}
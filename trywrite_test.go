@@ -0,0 +1,55 @@
+package nagle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_TryWrite(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(4), WithFlushTimeout(time.Hour), WithMaxPendingBytes(6))
+	defer nagleWrapper.Close()
+
+	if n, err := nagleWrapper.TryWrite([]byte("01")); n != 2 || err != nil {
+		t.Fatalf("expected to write 2 bytes, but got: %d, %v", n, err)
+	}
+
+	// Filling the buffer does not flush in the caller, but right after it in the background
+	if _, err := nagleWrapper.TryWrite([]byte("23")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for mockRWC.String() != "0123" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected '0123' to be flushed, but got: '%s'", mockRWC.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Data over the limit is refused without being buffered
+	if _, err := nagleWrapper.TryWrite([]byte("0123456")); !errors.Is(err, ErrWouldBlock) {
+		t.Fatalf("expected ErrWouldBlock, but got: %v", err)
+	}
+	if stats := nagleWrapper.Stats(); stats.Buffered != 0 || stats.Writes != 2 {
+		t.Fatalf("expected nothing buffered after 2 writes, but got: %d after %d", stats.Buffered, stats.Writes)
+	}
+}
+
+func TestNagleWrapper_TryWriteBusy(t *testing.T) {
+	nagleWrapper := New(&MockReadWriteCloser{}, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	// A call holding the lock, such as a flush to a slow writer, makes it give up at once
+	nagleWrapper.mutex.Lock()
+	_, err := nagleWrapper.TryWrite([]byte("0123"))
+	nagleWrapper.mutex.Unlock()
+	if !errors.Is(err, ErrWouldBlock) {
+		t.Fatalf("expected ErrWouldBlock, but got: %v", err)
+	}
+
+	nagleWrapper.Close()
+	if _, err := nagleWrapper.TryWrite([]byte("0123")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package nagle
+
+import (
+	"log/slog"
+	"time"
+)
+
+// WithMessageTTL discards, instead of sending, the buffered writes that have waited
+// longer than ttl by the time they are flushed, for real-time feeds where late data
+// must not be sent at all. Whole writes are discarded, never the rest of one that was
+// partly sent, and they are counted in Stats. Zero, the default, keeps every write.
+func WithMessageTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// expireLocked discards the buffered writes older than the WithMessageTTL limit.
+func (nw *NagleWriter) expireLocked() {
+	if nw.ttl <= 0 {
+		return
+	}
+	keep := nw.keptWritesLocked()
+	cutoff := nw.clock.Now().Add(-nw.ttl)
+	count, size := 0, 0
+	for _, w := range nw.writes[keep:] {
+		if !w.at.Before(cutoff) {
+			break
+		}
+		count++
+		size += w.size
+	}
+	if count == 0 {
+		return
+	}
+	nw.removeWritesLocked(keep, count, size)
+	nw.stats.ExpiredWrites += int64(count)
+	nw.stats.ExpiredBytes += int64(size)
+	nw.log(slog.LevelDebug, "nagle: expired writes dropped", "writes", count, "bytes", size)
+}
@@ -0,0 +1,51 @@
+package nagle_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jaracil/nagle"
+	"github.com/jaracil/nagle/naglefake"
+)
+
+func TestNagleWriter_WithMessageTTL(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(time.Hour),
+		nagle.WithClock(clock), nagle.WithMessageTTL(100*time.Millisecond))
+	defer nagleWriter.Close()
+
+	nagleWriter.Write([]byte("aa"))
+	clock.Advance(60 * time.Millisecond)
+	nagleWriter.Write([]byte("bb"))
+	clock.Advance(60 * time.Millisecond)
+	// Only the write that waited too long is discarded
+	nagleWriter.Flush()
+	if out.String() != "bb" {
+		t.Fatalf("expected 'bb', but got: '%s'", out.String())
+	}
+
+	if stats := nagleWriter.Stats(); stats.ExpiredWrites != 1 || stats.ExpiredBytes != 2 || stats.BytesFlushed != 2 {
+		t.Fatalf("expected 1 write of 2 bytes expired, but got: %+v", stats)
+	}
+}
+
+func TestNagleWriter_WithMessageTTLTimeout(t *testing.T) {
+	var out bytes.Buffer
+	clock := naglefake.NewClock(time.Unix(0, 0))
+	nagleWriter := nagle.NewWriter(&out, nagle.WithBufferSize(100), nagle.WithFlushTimeout(200*time.Millisecond),
+		nagle.WithClock(clock), nagle.WithMessageTTL(100*time.Millisecond))
+	defer nagleWriter.Close()
+
+	// The timeout flush finds nothing left to send
+	nagleWriter.Write([]byte("aa"))
+	nagleWriter.Write([]byte("bb"))
+	clock.Advance(200 * time.Millisecond)
+	if out.Len() != 0 {
+		t.Fatalf("expected nothing sent, but got: '%s'", out.String())
+	}
+	if stats := nagleWriter.Stats(); stats.ExpiredWrites != 2 || stats.Buffered != 0 || stats.Flushes() != 0 {
+		t.Fatalf("expected 2 writes expired and no flush, but got: %+v", stats)
+	}
+}
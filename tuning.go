@@ -0,0 +1,38 @@
+package nagle
+
+import "time"
+
+// SetBufferSize changes the number of buffered bytes that triggers a flush. Data
+// already buffered is not flushed right away; the new threshold applies from the next Write.
+func (nw *NagleWriter) SetBufferSize(n int) {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	nw.bufferSize = n
+}
+
+// BufferSize returns the number of buffered bytes that triggers a flush.
+func (nw *NagleWriter) BufferSize() int {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	return nw.bufferSize
+}
+
+// SetFlushTimeout changes how long buffered data may wait before it is flushed.
+// A flush that is already scheduled keeps its deadline; the new timeout applies
+// from the next Write. With WithAdaptiveTimeout it replaces the fallback timeout.
+func (nw *NagleWriter) SetFlushTimeout(d time.Duration) {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	nw.flushTimeout = d
+}
+
+// FlushTimeout returns how long buffered data may wait before it is flushed.
+func (nw *NagleWriter) FlushTimeout() time.Duration {
+	nw.mutex.Lock()
+	defer nw.mutex.Unlock()
+
+	return nw.flushTimeout
+}
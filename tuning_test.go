@@ -0,0 +1,49 @@
+package nagle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNagleWrapper_SetBufferSize(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(8), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.Write([]byte("0123"))
+	nagleWrapper.SetBufferSize(4)
+	if got := nagleWrapper.BufferSize(); got != 4 {
+		t.Fatalf("expected buffer size 4, but got: %d", got)
+	}
+
+	// Buffered data waits for the next write
+	if len(mockRWC.writes) != 0 {
+		t.Fatalf("expected no writes after SetBufferSize, but got: %q", mockRWC.writes)
+	}
+
+	nagleWrapper.Write([]byte("4"))
+	if len(mockRWC.writes) != 1 || mockRWC.writes[0] != "01234" {
+		t.Fatalf("expected a single write of '01234', but got: %q", mockRWC.writes)
+	}
+}
+
+func TestNagleWrapper_SetFlushTimeout(t *testing.T) {
+	mockRWC := &RecordingReadWriteCloser{}
+	nagleWrapper := New(mockRWC, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleWrapper.Close()
+
+	nagleWrapper.SetFlushTimeout(10 * time.Millisecond)
+	if got := nagleWrapper.FlushTimeout(); got != 10*time.Millisecond {
+		t.Fatalf("expected flush timeout 10ms, but got: %v", got)
+	}
+
+	nagleWrapper.Write([]byte("data"))
+	time.Sleep(50 * time.Millisecond)
+
+	nagleWrapper.mutex.Lock()
+	writes := len(mockRWC.writes)
+	nagleWrapper.mutex.Unlock()
+	if writes != 1 {
+		t.Fatalf("expected 1 write after the new timeout, but got: %d", writes)
+	}
+}
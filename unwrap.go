@@ -0,0 +1,41 @@
+package nagle
+
+import (
+	"io"
+	"net"
+)
+
+// Unwrap returns the underlying stream, for transport specific methods the wrapper does
+// not expose. Writing to it directly bypasses the buffer, so call Flush first to keep
+// the data in order.
+func (nw *NagleWrapper) Unwrap() io.ReadWriteCloser {
+	return nw.source.Load().rwc
+}
+
+// NetConn returns the underlying connection, like tls.Conn.NetConn. The same caveat
+// as for Unwrap applies to writing to it.
+func (nc *NagleConn) NetConn() net.Conn {
+	return nc.source.Load().rwc.(net.Conn)
+}
+
+// UnwrapAs looks for a T in the chain of streams under v, following the Unwrap method
+// of wrappers and the NetConn method of NagleConn and tls.Conn, and returns the first
+// one found. For example UnwrapAs[*net.TCPConn](nc) reaches the TCP connection under a
+// NagleConn even across a TLS layer.
+func UnwrapAs[T any](v any) (T, bool) {
+	for v != nil {
+		if t, ok := v.(T); ok {
+			return t, true
+		}
+		switch u := v.(type) {
+		case interface{ Unwrap() io.ReadWriteCloser }:
+			v = u.Unwrap()
+		case interface{ NetConn() net.Conn }:
+			v = u.NetConn()
+		default:
+			v = nil
+		}
+	}
+	var zero T
+	return zero, false
+}
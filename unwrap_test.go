@@ -0,0 +1,49 @@
+package nagle
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNagleWrapper_Unwrap(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := New(mockRWC)
+	defer nagleWrapper.Close()
+
+	if nagleWrapper.Unwrap() != mockRWC {
+		t.Fatalf("expected Unwrap to return the wrapped stream")
+	}
+	if got, ok := UnwrapAs[*MockReadWriteCloser](New(nagleWrapper)); !ok || got != mockRWC {
+		t.Fatalf("expected UnwrapAs to reach the innermost stream, but got: %v, %v", got, ok)
+	}
+	if _, ok := UnwrapAs[*net.TCPConn](nagleWrapper); ok {
+		t.Fatalf("expected UnwrapAs to find no TCP connection")
+	}
+}
+
+func TestNagleConn_UnwrapAs(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inner := NewConn(conn)
+	nagleConn := NewConn(inner)
+	defer nagleConn.Close()
+
+	if nagleConn.NetConn() != inner {
+		t.Fatalf("expected NetConn to return the wrapped conn")
+	}
+	tcp, ok := UnwrapAs[*net.TCPConn](nagleConn)
+	if !ok || tcp != conn {
+		t.Fatalf("expected to reach the TCP connection, but got: %v", tcp)
+	}
+	if err := tcp.SetKeepAlive(true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package nagle
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithWriteDeadline bounds every write a flush makes to the underlying stream by d,
+// so a hung peer turns into an error instead of a flush blocked forever. When the
+// stream has a SetWriteDeadline method, as net.Conn does, the deadline is set before
+// each write, which replaces any deadline set by the caller, and a timeout leaves the
+// unwritten bytes buffered for the next flush. Otherwise a watchdog closes the stream
+// once d has passed, and the flush fails with ErrFlushTimeout.
+func WithWriteDeadline(d time.Duration) Option {
+	return func(o *options) {
+		o.writeDeadline = d
+	}
+}
+
+// deadlineSetter is implemented by streams with write deadlines, such as net.Conn.
+type deadlineSetter interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// deadlineWriter bounds the writes to w under WithWriteDeadline. Its writes are
+// serialized by the wrapper lock.
+type deadlineWriter struct {
+	w      io.Writer
+	conn   deadlineSetter
+	closer io.Closer
+	clock  Clock
+	d      time.Duration
+}
+
+func newDeadlineWriter(w io.Writer, clock Clock, d time.Duration) *deadlineWriter {
+	conn, _ := w.(deadlineSetter)
+	closer, _ := w.(io.Closer)
+	return &deadlineWriter{w: w, conn: conn, closer: closer, clock: clock, d: d}
+}
+
+// interruptOnDoneLocked makes the writes to the underlying stream fail once ctx is done,
+// for FlushContext. The function returned stops it, and reports whether ctx ended first.
+func (nw *NagleWriter) interruptOnDoneLocked(ctx context.Context) func() bool {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	conn, _ := nw.base.(deadlineSetter)
+	closer, _ := nw.base.(io.Closer)
+	// mutex keeps the stream from being interrupted once the flush is over.
+	var mutex sync.Mutex
+	finished, fired, deadline := false, false, false
+	stop := context.AfterFunc(ctx, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if finished {
+			return
+		}
+		fired = true
+		if conn != nil && conn.SetWriteDeadline(time.Unix(1, 0)) == nil {
+			deadline = true
+		} else if closer != nil {
+			closer.Close()
+		}
+	})
+	return func() bool {
+		stop()
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		finished = true
+		if deadline {
+			conn.SetWriteDeadline(time.Time{})
+		}
+		return fired
+	}
+}
+
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	// Deadlines are wall clock times, so they do not come from the wrapper's clock.
+	if dw.conn != nil && dw.conn.SetWriteDeadline(time.Now().Add(dw.d)) == nil {
+		return dw.w.Write(p)
+	}
+
+	var expired atomic.Bool
+	watchdog := dw.clock.AfterFunc(dw.d, func() {
+		expired.Store(true)
+		if dw.closer != nil {
+			dw.closer.Close()
+		}
+	})
+	n, err := dw.w.Write(p)
+	watchdog.Stop()
+	if expired.Load() {
+		return n, ErrFlushTimeout
+	}
+	return n, err
+}
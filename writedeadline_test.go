@@ -0,0 +1,96 @@
+package nagle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNagleConn_WithWriteDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client, WithBufferSize(2), WithFlushTimeout(time.Hour), WithWriteDeadline(20*time.Millisecond))
+	defer nagleConn.Close()
+
+	// Nobody reads, so the flush runs into the deadline set on the conn
+	_, err := nagleConn.Write([]byte("ab"))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected %v, but got: %v", os.ErrDeadlineExceeded, err)
+	}
+	if nagleConn.Buffered() != 2 {
+		t.Fatalf("expected the data to stay buffered, but got: %d", nagleConn.Buffered())
+	}
+
+	// A timeout is not fatal: the next flush gets a fresh deadline
+	go io.ReadFull(server, make([]byte, 2))
+	if err := nagleConn.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNagleWriter_WithWriteDeadlineWatchdog(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	nagleWriter := NewWriter(pw, WithBufferSize(2), WithFlushTimeout(time.Hour), WithWriteDeadline(20*time.Millisecond))
+
+	// Without write deadlines the stuck write is unblocked by closing the stream
+	_, err := nagleWriter.Write([]byte("ab"))
+	if !errors.Is(err, ErrFlushTimeout) || !errors.Is(err, ErrFlushFailed) {
+		t.Fatalf("expected %v, but got: %v", ErrFlushTimeout, err)
+	}
+	select {
+	case <-nagleWriter.Done():
+	default:
+		t.Fatalf("expected the writer to be done after the watchdog fired")
+	}
+	if _, err := pw.Write([]byte("c")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected the stream to be closed, but got: %v", err)
+	}
+}
+
+func TestNagleConn_FlushContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	nagleConn := NewConn(client, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	defer nagleConn.Close()
+
+	// Nobody reads, so the flush is interrupted when ctx expires
+	nagleConn.Write([]byte("ab"))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := nagleConn.FlushContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, but got: %v", context.DeadlineExceeded, err)
+	}
+	if nagleConn.Buffered() != 2 {
+		t.Fatalf("expected the data to stay buffered, but got: %d", nagleConn.Buffered())
+	}
+	if err := nagleConn.FlushContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v for a done ctx, but got: %v", context.DeadlineExceeded, err)
+	}
+
+	// The deadline is cleared, so a later flush goes through
+	go io.ReadFull(server, make([]byte, 2))
+	if err := nagleConn.FlushContext(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNagleWriter_FlushContextWatchdog(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	nagleWriter := NewWriter(pw, WithBufferSize(100), WithFlushTimeout(time.Hour))
+	nagleWriter.Write([]byte("ab"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := nagleWriter.FlushContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, but got: %v", context.Canceled, err)
+	}
+	if _, err := pw.Write([]byte("c")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected the stream to be closed, but got: %v", err)
+	}
+}
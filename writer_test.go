@@ -0,0 +1,100 @@
+package nagle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MockWriteCloser mocks an io.WriteCloser for testing purposes.
+type MockWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (m *MockWriteCloser) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestNagleWriter_Writer(t *testing.T) {
+	var out bytes.Buffer
+	nagleWriter := NewWriter(&out, WithBufferSize(10), WithFlushTimeout(time.Hour))
+
+	nagleWriter.Write([]byte("01234"))
+	if out.String() != "" {
+		t.Fatalf("expected buffer to be empty, but got: %s", out.String())
+	}
+
+	// Without an underlying Closer, Close only flushes
+	if err := nagleWriter.Close(); err != nil {
+		t.Fatalf("unexpected error on close: %v", err)
+	}
+	if out.String() != "01234" {
+		t.Fatalf("expected buffer to contain '01234', but got: %s", out.String())
+	}
+	if _, err := nagleWriter.Write([]byte("more data")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+}
+
+func TestNagleWriter_WriteCloser(t *testing.T) {
+	mockWC := &MockWriteCloser{}
+	nagleWriter := NewWriter(mockWC, WithBufferSize(10), WithFlushTimeout(time.Hour))
+
+	nagleWriter.Write([]byte("01234"))
+	if err := nagleWriter.Close(); err != nil {
+		t.Fatalf("unexpected error on close: %v", err)
+	}
+	if mockWC.String() != "01234" {
+		t.Fatalf("expected buffer to contain '01234', but got: %s", mockWC.String())
+	}
+	if !mockWC.closed {
+		t.Fatal("expected underlying writer to be closed")
+	}
+}
+
+func TestNagleWrapper_FromReadWriter(t *testing.T) {
+	var out bytes.Buffer
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("request"), &out}
+	nagleWrapper := NewFromReadWriter(rw, WithBufferSize(10), WithFlushTimeout(time.Hour))
+
+	data := make([]byte, 7)
+	if _, err := io.ReadFull(nagleWrapper, data); err != nil || string(data) != "request" {
+		t.Fatalf("expected to read 'request', but got: '%s', %v", data, err)
+	}
+	nagleWrapper.Write([]byte("01234"))
+
+	// Without an underlying Closer, Close only flushes
+	if err := nagleWrapper.Close(); err != nil {
+		t.Fatalf("unexpected error on close: %v", err)
+	}
+	if out.String() != "01234" {
+		t.Fatalf("expected buffer to contain '01234', but got: %s", out.String())
+	}
+	if _, err := nagleWrapper.Write([]byte("more data")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, but got: %v", err)
+	}
+}
+
+func TestNagleWrapper_FromReadWriteCloser(t *testing.T) {
+	mockRWC := &MockReadWriteCloser{}
+	nagleWrapper := NewFromReadWriter(mockRWC, WithBufferSize(10), WithFlushTimeout(time.Hour))
+
+	nagleWrapper.Write([]byte("01234"))
+	if err := nagleWrapper.Close(); err != nil {
+		t.Fatalf("unexpected error on close: %v", err)
+	}
+	if mockRWC.String() != "01234" {
+		t.Fatalf("expected buffer to contain '01234', but got: %s", mockRWC.String())
+	}
+	if _, err := mockRWC.Write(nil); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatal("expected underlying stream to be closed")
+	}
+}
@@ -0,0 +1,44 @@
+package nagle
+
+import "io"
+
+var _ io.WriterTo = (*NagleWrapper)(nil)
+
+// WriteTo implements io.WriterTo, so io.Copy out of the wrapper moves the data held in
+// the read-ahead buffer and then copies the rest of the stream without an intermediate
+// buffer when it can: when the underlying stream or w can do the copy themselves, such
+// as two *net.TCPConn on Linux using splice, the copy is handed to them. Like io.Copy it
+// reads until EOF, which closes Done.
+func (nw *NagleWrapper) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	var err error
+	src := nw.source.Load()
+	switch r := nw.deadlines.Load(); {
+	case r != nil:
+		// Reads must go through the goroutine that enforces the deadline.
+		chunk := chunkPool.Get().(*[]byte)
+		n, err = io.CopyBuffer(w, struct{ io.Reader }{r}, *chunk)
+		chunkPool.Put(chunk)
+	case src.reader != nil:
+		n, err = src.reader.WriteTo(w)
+	default:
+		n, err = copyStream(w, src.rwc)
+	}
+	if err == nil {
+		nw.fail(io.EOF)
+	}
+	return n, nw.checkFatal(err)
+}
+
+// copyStream copies src to w, letting either side do the copy when it knows how.
+func copyStream(w io.Writer, src io.Reader) (int64, error) {
+	if wt, ok := src.(io.WriterTo); ok {
+		return wt.WriteTo(w)
+	}
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	chunk := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(chunk)
+	return io.CopyBuffer(w, src, *chunk)
+}
@@ -0,0 +1,70 @@
+package nagle
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNagleWrapper_WriteTo(t *testing.T) {
+	data := strings.Repeat("0123456789", 1000)
+
+	for _, readBuffer := range []int{0, 64} {
+		mockRWC := &MockReadWriteCloser{}
+		mockRWC.buffer.WriteString(data)
+		nagleWrapper := New(mockRWC, WithReadBuffer(readBuffer))
+
+		// Part of the stream is already in the read-ahead buffer
+		head := make([]byte, 5)
+		io.ReadFull(nagleWrapper, head)
+
+		var out bytes.Buffer
+		n, err := io.Copy(&out, nagleWrapper)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != int64(len(data)-5) || string(head)+out.String() != data {
+			t.Fatalf("expected the rest of the stream to be copied, got %d bytes", n)
+		}
+		select {
+		case <-nagleWrapper.Done():
+		default:
+			t.Fatal("expected Done to be closed after copying to EOF")
+		}
+	}
+}
+
+func TestNagleConn_WriteToTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	data := strings.Repeat("0123456789", 10000)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(data))
+		conn.Close()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nagleConn := NewConn(conn)
+	defer nagleConn.Close()
+
+	var out bytes.Buffer
+	if _, err := io.Copy(&out, nagleConn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != data {
+		t.Fatalf("expected %d bytes, but got: %d", len(data), out.Len())
+	}
+}